@@ -0,0 +1,96 @@
+package activity
+
+import (
+	"sync"
+	"time"
+
+	"github.com/FederationOfFathers/dashboard/db"
+	"go.uber.org/zap"
+)
+
+// Sources of activity
+const (
+	SourceMessage     = "message"
+	SourceVoice       = "voice"
+	SourceInteraction = "interaction"
+	SourceAPI         = "api"
+)
+
+var DB *db.DB
+var Logger *zap.Logger
+
+// FlushInterval is how often recorded activity is written to the database
+var FlushInterval = time.Minute
+
+type discordKey struct {
+	discordID string
+	hour      time.Time
+	source    string
+}
+
+var lock sync.Mutex
+var pending = map[db.ActivityKey]int{}
+var pendingDiscord = map[discordKey]int{}
+
+// Record notes activity by a member. It is written to the database in batches
+func Record(memberID int, source string) {
+	if memberID < 1 {
+		return
+	}
+	key := db.ActivityKey{MemberID: memberID, Hour: time.Now().Truncate(time.Hour), Source: source}
+	lock.Lock()
+	pending[key]++
+	lock.Unlock()
+}
+
+// RecordDiscord notes activity by a Discord user. It is matched to a member when it is written
+func RecordDiscord(discordID string, source string) {
+	if discordID == "" {
+		return
+	}
+	key := discordKey{discordID: discordID, hour: time.Now().Truncate(time.Hour), source: source}
+	lock.Lock()
+	pendingDiscord[key]++
+	lock.Unlock()
+}
+
+// Mind starts writing recorded activity to the database every FlushInterval
+func Mind() {
+	go func() {
+		for range time.Tick(FlushInterval) {
+			Flush()
+		}
+	}()
+}
+
+// Flush writes all recorded activity to the database
+func Flush() {
+	lock.Lock()
+	counts, discordCounts := pending, pendingDiscord
+	pending, pendingDiscord = map[db.ActivityKey]int{}, map[discordKey]int{}
+	lock.Unlock()
+
+	if len(discordCounts) > 0 {
+		var ids []string
+		var seen = map[string]bool{}
+		for k := range discordCounts {
+			if !seen[k.discordID] {
+				seen[k.discordID] = true
+				ids = append(ids, k.discordID)
+			}
+		}
+		members, err := DB.MemberIDsByDiscordIDs(ids)
+		if err != nil {
+			Logger.Error("unable to match discord activity to members", zap.Error(err))
+		}
+		for k, count := range discordCounts {
+			if memberID, ok := members[k.discordID]; ok {
+				counts[db.ActivityKey{MemberID: memberID, Hour: k.hour, Source: k.source}] += count
+			}
+		}
+	}
+
+	if err := DB.RecordActivity(counts, time.Now()); err != nil {
+		Logger.Error("unable to record member activity", zap.Int("rows", len(counts)), zap.Error(err))
+	}
+}
@@ -0,0 +1,26 @@
+package activity
+
+import "testing"
+
+func TestRecordBatches(t *testing.T) {
+	Record(1, SourceAPI)
+	Record(1, SourceAPI)
+	Record(1, SourceMessage)
+	Record(0, SourceAPI)
+	RecordDiscord("123", SourceVoice)
+	RecordDiscord("", SourceVoice)
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(pending) != 2 {
+		t.Fatalf("expected 2 pending member rows, got %d", len(pending))
+	}
+	for k, count := range pending {
+		if k.Source == SourceAPI && count != 2 {
+			t.Errorf("expected 2 api requests to be batched, got %d", count)
+		}
+	}
+	if len(pendingDiscord) != 1 {
+		t.Errorf("expected 1 pending discord row, got %d", len(pendingDiscord))
+	}
+}
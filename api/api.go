@@ -3,7 +3,6 @@ package api
 import (
 	"crypto/md5"
 	"crypto/sha1"
-	"fmt"
 	"net/http"
 	"os"
 
@@ -69,6 +68,5 @@ func Run() {
 }
 
 func NotImplemented(w http.ResponseWriter, e *http.Request) {
-	w.WriteHeader(http.StatusNotImplemented)
-	fmt.Fprint(w, "Not Implemented")
+	writeError(w, http.StatusNotImplemented, "not_implemented", "Not Implemented")
 }
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/FederationOfFathers/dashboard/messaging"
+	"go.uber.org/zap"
+)
+
+const auditMaxPerPage = 100
+
+type auditResponse struct {
+	Entries []*db.AuditLog `json:"entries"`
+	Total   int            `json:"total"`
+	Page    int            `json:"page"`
+	PerPage int            `json:"perPage"`
+}
+
+func auditJSON(v interface{}) json.RawMessage {
+	if v == nil {
+		return json.RawMessage("null")
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		Logger.Error("unable to encode audit value", zap.Any("value", v), zap.Error(err))
+		return json.RawMessage("null")
+	}
+	return buf
+}
+
+// auditEntry starts an audit log entry for an action the actor takes in the request
+func auditEntry(r *http.Request, actor *db.Member) *db.AuditLog {
+	return &db.AuditLog{
+		ActorID:   actor.ID,
+		IP:        r.RemoteAddr,
+		UserAgent: r.UserAgent(),
+		Method:    r.Method,
+		URI:       r.RequestURI,
+	}
+}
+
+// recordAudit records actor changing target's data from before to after, and mirrors it to the mod log.
+// Changes members make to their own data are not privileged and are not recorded
+func recordAudit(r *http.Request, actor *db.Member, target *db.Member, action string, before, after interface{}) {
+	if actor.ID == target.ID {
+		return
+	}
+	entry := auditEntry(r, actor)
+	entry.TargetID = target.ID
+	entry.Action = action
+	entry.Before = auditJSON(before)
+	entry.After = auditJSON(after)
+	if err := DB.RecordAudit(entry); err != nil {
+		Logger.Error("unable to record audit log", zap.Any("entry", entry), zap.Error(err))
+		return
+	}
+	go messaging.SendAuditMessage(entry, actor, target)
+}
+
+// auditTime parses a unix timestamp query parameter
+func auditTime(r *http.Request, name string) (time.Time, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return time.Time{}, true
+	}
+	ts, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(ts, 0), true
+}
+
+func init() {
+	Router.Path("/api/v1/admin/audit").Methods("GET").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			if requireAdmin(w, r) == nil {
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+
+			var filter = db.AuditFilter{
+				Action:  r.URL.Query().Get("action"),
+				Page:    1,
+				PerPage: 50,
+			}
+			for name, dst := range map[string]*int{
+				"actor":   &filter.ActorID,
+				"target":  &filter.TargetID,
+				"page":    &filter.Page,
+				"perPage": &filter.PerPage,
+			} {
+				if v := r.URL.Query().Get(name); v != "" {
+					i, err := strconv.Atoi(v)
+					if err != nil || i < 1 {
+						writeBadRequest(w, "invalid_"+name, name+" must be a positive number")
+						return
+					}
+					*dst = i
+				}
+			}
+			if filter.PerPage > auditMaxPerPage {
+				filter.PerPage = auditMaxPerPage
+			}
+			var ok bool
+			if filter.Since, ok = auditTime(r, "since"); !ok {
+				writeBadRequest(w, "invalid_since", "since must be a unix timestamp")
+				return
+			}
+			if filter.Until, ok = auditTime(r, "until"); !ok {
+				writeBadRequest(w, "invalid_until", "until must be a unix timestamp")
+				return
+			}
+
+			entries, total, err := DB.AuditLogs(filter)
+			if err != nil {
+				Logger.Error("querying audit log", zap.Any("filter", filter), zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+			json.NewEncoder(w).Encode(auditResponse{
+				Entries: entries,
+				Total:   total,
+				Page:    filter.Page,
+				PerPage: filter.PerPage,
+			})
+		},
+	))
+	docRouteMethod("/api/v1/admin/audit", methodDocEntry{
+		Method:      "GET",
+		Description: "List privileged actions admins have taken on other members, newest first",
+		Auth:        authAdmin,
+		OptionalParams: []methodParams{
+			{Name: "actor", Type: "integer", Description: "Only actions taken by this member id"},
+			{Name: "target", Type: "integer", Description: "Only actions taken on this member id"},
+			{Name: "action", Description: "Only this action", Values: []string{"member.update", "meta.set", "meta.delete", "stream.set", "stream.delete"}},
+			{Name: "since", Type: "integer", Description: "Only actions at or after this unix timestamp"},
+			{Name: "until", Type: "integer", Description: "Only actions before this unix timestamp"},
+			{Name: "page", Type: "integer", Description: "Page number, starting at 1"},
+			{Name: "perPage", Type: "integer", Description: "Entries per page, at most 100. Defaults to 50"},
+		},
+		Response: auditResponse{},
+	})
+}
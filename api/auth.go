@@ -4,12 +4,14 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/FederationOfFathers/dashboard/bot"
+	"github.com/FederationOfFathers/dashboard/db"
 	"go.uber.org/zap"
 )
 
@@ -33,8 +35,19 @@ func init() {
 			http.Redirect(w, r, "https://ui.fofgaming.com/", http.StatusTemporaryRedirect)
 			return
 		}
-		w.WriteHeader(http.StatusForbidden)
-		w.Write([]byte("Invalid link. Please get another"))
+		writeForbidden(w, "invalid_login_link", "Invalid link. Please get another")
+	})
+	docRouteMethod("/api/v0/login", methodDocEntry{
+		Method:      "GET",
+		Description: "Logs a user into the API by slack id. Linked to from slack messages for users",
+		Auth:        authNone,
+		RequiredParams: []methodParams{
+			{Name: "w", Description: "Slack user id"},
+			{Name: "t", Description: "Mini auth token"},
+		},
+		OptionalParams: []methodParams{
+			{Name: "r", Description: "Set to 0 to respond with \"ok\" instead of redirecting to the UI", Values: []string{"0"}},
+		},
 	})
 
 	// v1 using member id
@@ -52,8 +65,19 @@ func init() {
 			http.Redirect(w, r, "https://ui.fofgaming.com/", http.StatusTemporaryRedirect)
 			return
 		}
-		w.WriteHeader(http.StatusForbidden)
-		w.Write([]byte("Invalid link. Please get another"))
+		writeForbidden(w, "invalid_login_link", "Invalid link. Please get another")
+	})
+	docRouteMethod("/api/v1/login", methodDocEntry{
+		Method:      "GET",
+		Description: "Logs a member into the API by member id",
+		Auth:        authNone,
+		RequiredParams: []methodParams{
+			{Name: "w", Type: "integer", Description: "Member id"},
+			{Name: "t", Description: "Mini auth token"},
+		},
+		OptionalParams: []methodParams{
+			{Name: "r", Description: "Set to 0 to respond with \"ok\" instead of redirecting to the UI", Values: []string{"0"}},
+		},
 	})
 	Router.Path("/api/v0/logout").Methods("GET").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.SetCookie(w, &http.Cookie{
@@ -66,23 +90,36 @@ func init() {
 	})
 }
 
-func requireAdmin(w http.ResponseWriter, r *http.Request) error {
+// requestMember returns the logged in member. If there isn't one an error response is written and nil returned
+func requestMember(w http.ResponseWriter, r *http.Request) *db.Member {
 	id := getMemberID(r)
-	member, err := DB.MemberByDiscordID(id)
-	if err != nil {
-		Logger.Error("could not find this user", zap.String("id", id), zap.Error(err))
-		w.WriteHeader(http.StatusInternalServerError)
-		return err
+	member, err := DB.MemberByAny(id)
+	if errors.Is(err, db.ErrNotFound) {
+		writeForbidden(w, "unknown_member", "you are not logged in as a known member")
+		return nil
+	} else if err != nil {
+		Logger.Error("could not find the logged in member", zap.String("id", id), zap.Error(err))
+		writeInternalError(w)
+		return nil
+	}
+	return member
+}
+
+// requireAdmin returns the logged in member if they are an admin. Otherwise an error response is written and nil returned
+func requireAdmin(w http.ResponseWriter, r *http.Request) *db.Member {
+	member := requestMember(w, r)
+	if member == nil {
+		return nil
 	}
-	if admin, err := bot.IsUserIDAdmin(member.Discord); err != nil {
-		Logger.Error("error determining admin status", zap.Error(err))
-		w.WriteHeader(http.StatusInternalServerError)
-		return err
+	if admin, err := bot.IsUserIDAdmin(member.Discord); err != nil && err != bot.ErrUsernameNotFound {
+		Logger.Error("error determining admin status", zap.Int("member", member.ID), zap.Error(err))
+		writeInternalError(w)
+		return nil
 	} else if !admin {
-		w.WriteHeader(http.StatusForbidden)
-		return fmt.Errorf("error")
+		writeForbidden(w, "admin_required", "only admins may do that")
+		return nil
 	}
-	return nil
+	return member
 }
 
 // GenerateValidAuthTokens generates all possible valid auth tokens for right now.
@@ -171,7 +208,7 @@ func getSlackUserID(r *http.Request) string {
 func getMemberID(r *http.Request) string {
 	auth := requestAuth(r)
 	id := auth["memberid"]
-	if id != "" {
+	if id != "" && id != "0" {
 		return id
 	}
 
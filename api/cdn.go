@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/FederationOfFathers/dashboard/imagecache"
+	"go.uber.org/zap"
+)
+
+// ImageCache serves /api/v0/cdn. The route answers 404 while it is nil
+var ImageCache *imagecache.Cache
+
+// cdnImageURL returns the upstream image a cdn request is for. It is either in the url query parameter or in the
+// path, where a missing scheme means https. The router collapses the double slash after a scheme in paths
+func cdnImageURL(r *http.Request) string {
+	if u := r.URL.Query().Get("url"); u != "" {
+		return u
+	}
+	rest := strings.TrimPrefix(r.URL.Path, "/api/v0/cdn/")
+	for _, scheme := range []string{"https:/", "http:/"} {
+		if strings.HasPrefix(rest, scheme) {
+			return scheme + "/" + strings.TrimLeft(strings.TrimPrefix(rest, scheme), "/")
+		}
+	}
+	return "https://" + strings.TrimLeft(rest, "/")
+}
+
+func init() {
+	Router.PathPrefix("/api/v0/cdn/").Methods("GET", "HEAD").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ImageCache == nil {
+			writeError(w, http.StatusNotFound, "cdn_disabled", "the image cache is not configured")
+			return
+		}
+		source := cdnImageURL(r)
+		img, err := ImageCache.Get(r.Context(), source, r.URL.Query().Get("size"))
+		switch {
+		case errors.Is(err, imagecache.ErrNotAllowed):
+			writeForbidden(w, "host_not_allowed", "images may not be fetched from there")
+			return
+		case errors.Is(err, imagecache.ErrVariant):
+			writeBadRequest(w, "invalid_size", "size must be thumbnail or card")
+			return
+		case errors.Is(err, imagecache.ErrNotImage):
+			writeError(w, http.StatusUnsupportedMediaType, "not_an_image", "the upstream file is not a supported image")
+			return
+		case errors.Is(err, imagecache.ErrTooLarge):
+			writeError(w, http.StatusBadGateway, "image_too_large", "the upstream image is too large")
+			return
+		case err != nil:
+			Logger.Warn("unable to fetch image", zap.String("url", source), zap.Error(err))
+			writeError(w, http.StatusBadGateway, "upstream_unavailable", "the image could not be fetched")
+			return
+		}
+		f, err := os.Open(img.Path)
+		if err != nil {
+			Logger.Error("unable to open cached image", zap.String("path", img.Path), zap.Error(err))
+			writeInternalError(w)
+			return
+		}
+		defer f.Close()
+		w.Header().Set("Content-Type", img.ContentType)
+		w.Header().Set("ETag", img.ETag)
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		http.ServeContent(w, r, "", img.Fetched, f)
+	})
+	docRouteMethod("/api/v0/cdn/", methodDocEntry{
+		Method: "GET",
+		Description: "Serve game art or a stream thumbnail from an allowed host through the on disk image cache. " +
+			"The image URL follows the prefix, with or without its scheme, or is given in the url parameter. " +
+			"Responses carry an ETag and may be cached for a day",
+		Auth: authNone,
+		OptionalParams: []methodParams{
+			{Name: "url", Description: "The upstream image URL, for URLs with a query string"},
+			{Name: "size", Description: "Scale the image down to fit this size", Values: []string{"thumbnail", "card"}},
+		},
+	})
+	docRouteMethod("/api/v0/cdn/", methodDocEntry{
+		Method:      "HEAD",
+		Description: "Check an image in the cache, as GET without the body",
+		Auth:        authNone,
+	})
+}
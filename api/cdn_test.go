@@ -0,0 +1,19 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCDNImageURL(t *testing.T) {
+	for path, expected := range map[string]string{
+		"/api/v0/cdn/https:/store-images.s-microsoft.com/image/apps.1": "https://store-images.s-microsoft.com/image/apps.1",
+		"/api/v0/cdn/http:/i.ytimg.com/vi/x/mqdefault.jpg":             "http://i.ytimg.com/vi/x/mqdefault.jpg",
+		"/api/v0/cdn/i.ytimg.com/vi/x/mqdefault.jpg":                   "https://i.ytimg.com/vi/x/mqdefault.jpg",
+		"/api/v0/cdn/?url=https%3A%2F%2Fi.ytimg.com%2Fa.jpg%3F123":     "https://i.ytimg.com/a.jpg?123",
+	} {
+		if got := cdnImageURL(httptest.NewRequest("GET", path, nil)); got != expected {
+			t.Errorf("%s: expected %s, got %s", path, expected, got)
+		}
+	}
+}
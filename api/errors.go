@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/FederationOfFathers/dashboard/db"
+	"go.uber.org/zap"
+)
+
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// errorResponse is the body of every error response from the API
+type errorResponse struct {
+	Error apiError `json:"error"`
+}
+
+// writeError writes an error response with the given status, machine readable code and human readable message
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: apiError{Code: code, Message: message}})
+}
+
+func writeBadRequest(w http.ResponseWriter, code string, message string) {
+	writeError(w, http.StatusBadRequest, code, message)
+}
+
+func writeForbidden(w http.ResponseWriter, code string, message string) {
+	writeError(w, http.StatusForbidden, code, message)
+}
+
+// writeInternalError hides the details of err from the client. Log it before calling this
+func writeInternalError(w http.ResponseWriter) {
+	writeError(w, http.StatusInternalServerError, "internal_error", "something went wrong, please try again later")
+}
+
+// writeDBError writes a typed error from the db package with the matching status. Any other error
+// is logged as msg and written as an internal error
+func writeDBError(w http.ResponseWriter, err error, msg string, fields ...zap.Field) {
+	var dbErr *db.Error
+	if errors.As(err, &dbErr) {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			writeError(w, http.StatusNotFound, dbErr.Code, dbErr.Message)
+			return
+		case errors.Is(err, db.ErrConflict):
+			writeError(w, http.StatusConflict, dbErr.Code, dbErr.Message)
+			return
+		case errors.Is(err, db.ErrForbidden):
+			writeError(w, http.StatusForbidden, dbErr.Code, dbErr.Message)
+			return
+		case errors.Is(err, db.ErrInvalid):
+			writeError(w, http.StatusBadRequest, dbErr.Code, dbErr.Message)
+			return
+		}
+	}
+	Logger.Error(msg, append(fields, zap.Error(err))...)
+	writeInternalError(w)
+}
+
+// decodeJSON decodes the request body into v, writing a 400 response when it cannot
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeBadRequest(w, "invalid_json", "unable to decode the request body: "+err.Error())
+		return false
+	}
+	return true
+}
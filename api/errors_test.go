@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/FederationOfFathers/dashboard/db"
+	"go.uber.org/zap"
+)
+
+func TestWriteDBError(t *testing.T) {
+	Logger = zap.NewNop()
+	for _, test := range []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{&db.Error{Kind: db.ErrNotFound, Code: "event_not_found"}, http.StatusNotFound, "event_not_found"},
+		{&db.Error{Kind: db.ErrConflict, Code: "event_full"}, http.StatusConflict, "event_full"},
+		{&db.Error{Kind: db.ErrForbidden, Code: "not_event_host"}, http.StatusForbidden, "not_event_host"},
+		{fmt.Errorf("wrapped: %w", &db.Error{Kind: db.ErrNotFound, Code: "member_not_found"}), http.StatusNotFound, "member_not_found"},
+		{fmt.Errorf("connection refused"), http.StatusInternalServerError, "internal_error"},
+	} {
+		w := httptest.NewRecorder()
+		writeDBError(w, test.err, "test")
+		if w.Code != test.status {
+			t.Errorf("%v: expected status %d, got %d", test.err, test.status, w.Code)
+		}
+		var body errorResponse
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("%v: error response is not JSON: %s", test.err, err)
+		}
+		if body.Error.Code != test.code {
+			t.Errorf("%v: expected code %q, got %q", test.err, test.code, body.Error.Code)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"sort"
@@ -59,7 +60,7 @@ func init() {
 			channels, err := DB.EventChannels()
 			if err != nil {
 				Logger.Error("could not get channels", zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
+				writeInternalError(w)
 				return
 			}
 			for i := range channels {
@@ -70,7 +71,7 @@ func init() {
 			events, err := DB.Events()
 			if err != nil {
 				Logger.Error("could not get events", zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
+				writeInternalError(w)
 				return
 			}
 
@@ -91,45 +92,43 @@ func init() {
 			json.NewEncoder(w).Encode(eventsResponse)
 		},
 	))
+	docRouteMethod("/api/v1/events", methodDocEntry{
+		Method:      "GET",
+		Description: "List events grouped by the Discord channel they are in, keyed by channel id",
+		Response:    map[string]*EventsResponseChannel{},
+	})
 
 	// Create an event, needs when (time), where(channel id), title, and member from request
 	Router.Path("/api/v1/events/create").Methods("POST").Handler(authenticated(
 		func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Content-Type", "application/json")
 
-			decoder := json.NewDecoder(r.Body)
 			var data EventCreateRequestBody
-
-			if err := decoder.Decode(&data); err != nil {
-				Logger.Error("Unable to decode body", zap.Error(err))
+			if !decodeJSON(w, r, &data) {
+				return
 			}
 			// convert time
 			timestamp, err := strconv.Atoi(data.When)
 			if err != nil {
-				Logger.Error("bad timestamp", zap.String("when", data.When))
-				w.WriteHeader(http.StatusInternalServerError)
+				writeBadRequest(w, "invalid_timestamp", "when must be a unix timestamp")
 				return
 			}
 
-			id := getMemberID(r)
-			mid, err := strconv.Atoi(id)
-			if err != nil {
-				w.WriteHeader(http.StatusForbidden)
-				return
-			}
 			// get the member
-			member, err := DB.MemberByID(mid)
-
-			if err != nil {
-				Logger.Error("couldn't find a member", zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
+			member := requestMember(w, r)
+			if member == nil {
 				return
 			}
 
 			// get channel in DB
 			eventChannel, err := DB.EventChannelByChannelID(data.Where)
-			if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeBadRequest(w, "unknown_channel", "events cannot be created in that channel")
+				return
+			} else if err != nil {
 				Logger.Error("Invalid event channel", zap.String("channel_id", data.Where), zap.Error(err))
+				writeInternalError(w)
+				return
 			}
 
 			// build the event
@@ -149,7 +148,7 @@ func init() {
 			// save the event
 			if err := event.Save(); err != nil {
 				Logger.Error("could not save the event", zap.Any("event", event), zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
+				writeInternalError(w)
 				return
 			}
 
@@ -159,6 +158,12 @@ func init() {
 			json.NewEncoder(w).Encode(event)
 		},
 	))
+	docRouteMethod("/api/v1/events/create", methodDocEntry{
+		Method:      "POST",
+		Description: "Create an event hosted by the logged in member and announce it in Discord",
+		Request:     EventCreateRequestBody{},
+		Response:    db.Event{},
+	})
 
 	// Join an event
 	Router.Path("/api/v1/events/{eventID}/join").Methods("POST").Handler(authenticated(
@@ -166,37 +171,31 @@ func init() {
 
 			w.Header().Set("Content-Type", "application/json")
 
-			decoder := json.NewDecoder(r.Body)
 			vars := mux.Vars(r)
 			var data EventJoinRequestBody
-
-			if err := decoder.Decode(&data); err != nil {
-				Logger.Error("Unable to decode body", zap.Error(err))
+			if !decodeJSON(w, r, &data) {
+				return
 			}
-
-			id := getMemberID(r)
-			mid, err := strconv.Atoi(id)
-			if err != nil {
-				w.WriteHeader(http.StatusForbidden)
+			if data.Type != db.EventMemberTypeMember && data.Type != db.EventMemberTypeAlt {
+				writeBadRequest(w, "invalid_member_type", fmt.Sprintf("type must be %d (member) or %d (alt)", db.EventMemberTypeMember, db.EventMemberTypeAlt))
 				return
 			}
+
 			// member
-			member, err := DB.MemberByID(mid)
-			if err != nil {
-				Logger.Error("could not get a valid member", zap.Error(err))
+			member := requestMember(w, r)
+			if member == nil {
+				return
 			}
 
 			//event
 			eventID, err := strconv.Atoi(vars["eventID"])
 			if err != nil {
-				Logger.Error("invalid eventID", zap.String("eventID", vars["eventID"]), zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
+				writeBadRequest(w, "invalid_event_id", "eventID must be a number")
 				return
 			}
 			event, err := DB.EventByID(eventID)
 			if err != nil {
-				Logger.Error("unable to find event", zap.Int("eventID", eventID), zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
+				writeDBError(w, err, "unable to find event", zap.Int("eventID", eventID))
 				return
 			}
 
@@ -204,12 +203,12 @@ func init() {
 			event.Members, err = DB.EventMembers(event)
 			if err != nil {
 				Logger.Error("could not load event members", zap.Any("event", event), zap.Error(err))
+				writeInternalError(w)
+				return
 			}
-			event.Members = append(event.Members, &db.EventMember{MemberID: member.ID, Type: data.Type})
-
-			if err := event.Save(); err != nil {
-				Logger.Error("unable to save event", zap.Any("event", event), zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
+			if err := event.Join(member.ID, data.Type); err != nil {
+				writeDBError(w, err, "unable to save event", zap.Any("event", event))
+				return
 			}
 
 			go messaging.SendJoinEventMessage(event, member)
@@ -218,6 +217,11 @@ func init() {
 
 		},
 	))
+	docRouteMethod("/api/v1/events/{eventID}/join", methodDocEntry{
+		Method:      "POST",
+		Description: "Join an event as the logged in member and announce it in Discord",
+		Request:     EventJoinRequestBody{},
+	})
 
 	// Leave an event
 	Router.Path("/api/v1/events/leave").Methods("POST").Handler(authenticated(
@@ -225,106 +229,59 @@ func init() {
 
 			w.Header().Set("Content-Type", "application/json")
 
-			decoder := json.NewDecoder(r.Body)
-			//vars := mux.Vars(r)
 			var data EventLeaveRequestBody
-
-			if err := decoder.Decode(&data); err != nil {
-				Logger.Error("Unable to decode body", zap.Error(err))
-			}
-
-			id := getMemberID(r)
-			mid, err := strconv.Atoi(id)
-			if err != nil {
-				w.WriteHeader(http.StatusForbidden)
+			if !decodeJSON(w, r, &data) {
 				return
 			}
 
 			// logged in member
-			member, err := DB.MemberByID(mid)
-			if err != nil {
-				Logger.Error("could not get a valid member", zap.Error(err))
-				w.WriteHeader(http.StatusForbidden)
-				return
-			}
-
-			eMember, err := DB.EventMemberByID(data.Member)
-			if err != nil {
-				Logger.Error("unable to delete event member", zap.Uint("member id", data.Member), zap.Error(err))
-				w.WriteHeader(http.StatusBadRequest)
+			member := requestMember(w, r)
+			if member == nil {
 				return
 			}
 
-			if member.ID != eMember.MemberID {
-				w.WriteHeader(http.StatusForbidden)
+			if err := DB.LeaveEvent(data.Member, member.ID); err != nil {
+				writeDBError(w, err, "unable to delete event member", zap.Uint("member id", data.Member))
 				return
 			}
 
-			DB.DeleteEventMemberByID(data.Member)
-
 			w.WriteHeader(http.StatusOK)
 
 		},
 	))
+	docRouteMethod("/api/v1/events/leave", methodDocEntry{
+		Method:      "POST",
+		Description: "Give up a slot the logged in member holds in an event",
+		Request:     EventLeaveRequestBody{},
+	})
 
 	// Delete event
 	Router.Path("/api/v1/events/{eventID}").Methods("DELETE").Handler(authenticated(
 		func(w http.ResponseWriter, r *http.Request) {
 			vars := mux.Vars(r)
 
-			id := getMemberID(r)
-			mid, err := strconv.Atoi(id)
-			if err != nil {
-				w.WriteHeader(http.StatusForbidden)
-				return
-			}
 			// get member
-			member, err := DB.MemberByID(mid)
-			if err != nil {
-				Logger.Error("invalid member", zap.String("memberid", id))
-				w.WriteHeader(http.StatusForbidden)
+			member := requestMember(w, r)
+			if member == nil {
 				return
 			}
 
 			//get event
 			eventID, err := strconv.Atoi(vars["eventID"])
 			if err != nil {
-				Logger.Error("invalid eventID", zap.String("eventID", vars["eventID"]), zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
-				return
-			}
-			event, err := DB.EventByID(eventID)
-			if err != nil {
-				Logger.Error("unable to find", zap.Int("eventID", eventID), zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
-				return
-			}
-
-			members, err := DB.EventMembers(event)
-			if err != nil {
-				Logger.Error("unable to find event members", zap.Any("event", event), zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
+				writeBadRequest(w, "invalid_event_id", "eventID must be a number")
 				return
 			}
-
-			isOwner := false
-			for _, eM := range members {
-				if eM.MemberID == member.ID && eM.Type == db.EventMemberTypeHost {
-					isOwner = true
-					break
-				}
-			}
-
-			if isOwner {
-				Logger.Info("Deleting event", zap.Any("event", event))
-				DB.DeleteEvent(*event)
-			} else {
-				Logger.Debug("bad delete request from user", zap.Int("id", member.ID), zap.Any("event", event))
-				w.WriteHeader(http.StatusForbidden)
+			if err := DB.DeleteEventAsMember(eventID, member.ID); err != nil {
+				writeDBError(w, err, "unable to delete event", zap.Int("eventID", eventID))
 				return
 			}
 		},
 	))
+	docRouteMethod("/api/v1/events/{eventID}", methodDocEntry{
+		Method:      "DELETE",
+		Description: "Delete an event hosted by the logged in member",
+	})
 
 	// get the channels
 	Router.Path("/api/v1/events/channels").Methods("GET").Handler(authenticated(
@@ -333,7 +290,8 @@ func init() {
 			eventChannels, err := DB.EventChannels()
 			if err != nil {
 				Logger.Error("unable to get event channels", zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
+				writeInternalError(w)
+				return
 			}
 
 			// sort the channel names
@@ -346,4 +304,9 @@ func init() {
 			json.NewEncoder(w).Encode(eventChannels)
 		},
 	))
+	docRouteMethod("/api/v1/events/channels", methodDocEntry{
+		Method:      "GET",
+		Description: "List the Discord channels events can be created in",
+		Response:    []db.EventChannel{},
+	})
 }
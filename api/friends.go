@@ -0,0 +1,159 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/FederationOfFathers/dashboard/roster"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type friendResult struct {
+	memberRestricted
+	// Mutual is true when the friend has the requester on their list too
+	Mutual bool `json:"mutual"`
+}
+
+// friendMembers returns the members with the given ids as the viewer may see them
+func (v *profileViewer) friendMembers(ids []int) ([]*db.Member, error) {
+	members, err := DB.MembersByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	return v.visibleMembers(members)
+}
+
+// friendTarget returns the member in the memberID path variable. Otherwise an error response is written and nil returned
+func friendTarget(w http.ResponseWriter, r *http.Request) *db.Member {
+	memberID, err := strconv.Atoi(mux.Vars(r)["memberID"])
+	if err != nil {
+		writeBadRequest(w, "invalid_member_id", "memberID must be a number")
+		return nil
+	}
+	member, err := DB.MemberByID(memberID)
+	if err != nil {
+		writeDBError(w, err, "member lookup", zap.Int("memberID", memberID))
+		return nil
+	}
+	return member
+}
+
+func init() {
+	Router.Path("/api/v1/friends").Methods("GET").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			viewer := requestViewer(w, r)
+			if viewer == nil {
+				return
+			}
+			members, err := viewer.friendMembers(roster.Get(viewer.member.ID))
+			if err != nil {
+				Logger.Error("listing friends", zap.Int("member", viewer.member.ID), zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+			var rval = make([]friendResult, 0, len(members))
+			for _, member := range members {
+				rval = append(rval, friendResult{
+					memberRestricted: memberToMemberRestricted(member),
+					Mutual:           roster.IsFriend(member.ID, viewer.member.ID),
+				})
+			}
+			json.NewEncoder(w).Encode(rval)
+		},
+	))
+	docRouteMethod("/api/v1/friends", methodDocEntry{
+		Method:      "GET",
+		Description: "List the logged in member's friends by name, and whether each has the member on their list too",
+		Response:    []friendResult{},
+	})
+
+	Router.Path("/api/v1/friends/{memberID}").Methods("PUT").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			member := requestMember(w, r)
+			if member == nil {
+				return
+			}
+			friend := friendTarget(w, r)
+			if friend == nil {
+				return
+			}
+			if friend.ID == member.ID {
+				writeBadRequest(w, "invalid_friend", "you cannot add yourself as a friend")
+				return
+			}
+			if friend.AnonymizedAt != nil || friend.DepartedAt != nil {
+				writeBadRequest(w, "invalid_friend", "only current members may be added as friends")
+				return
+			}
+			if err := roster.Set(member.ID, friend.ID, true); err != nil {
+				Logger.Error("adding friend", zap.Int("member", member.ID), zap.Int("friend", friend.ID), zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+		},
+	))
+	docRouteMethod("/api/v1/friends/{memberID}", methodDocEntry{
+		Method:         "PUT",
+		Description:    "Add a member to the logged in member's friends",
+		RequiredParams: []methodParams{{Name: "memberID", Type: "integer", Description: "Member id"}},
+	})
+
+	Router.Path("/api/v1/friends/{memberID}").Methods("DELETE").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			member := requestMember(w, r)
+			if member == nil {
+				return
+			}
+			friendID, err := strconv.Atoi(mux.Vars(r)["memberID"])
+			if err != nil {
+				writeBadRequest(w, "invalid_member_id", "memberID must be a number")
+				return
+			}
+			if err := roster.Set(member.ID, friendID, false); err != nil {
+				Logger.Error("removing friend", zap.Int("member", member.ID), zap.Int("friend", friendID), zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+		},
+	))
+	docRouteMethod("/api/v1/friends/{memberID}", methodDocEntry{
+		Method:         "DELETE",
+		Description:    "Remove a member from the logged in member's friends",
+		RequiredParams: []methodParams{{Name: "memberID", Type: "integer", Description: "Member id"}},
+	})
+
+	Router.Path("/api/v1/friends/mutual/{memberID}").Methods("GET").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			viewer := requestViewer(w, r)
+			if viewer == nil {
+				return
+			}
+			other := friendTarget(w, r)
+			if other == nil {
+				return
+			}
+			members, err := viewer.friendMembers(roster.Mutual(viewer.member.ID, other.ID))
+			if err != nil {
+				Logger.Error("listing mutual friends", zap.Int("member", viewer.member.ID), zap.Int("other", other.ID), zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+			var rval = make([]memberRestricted, 0, len(members))
+			for _, member := range members {
+				rval = append(rval, memberToMemberRestricted(member))
+			}
+			json.NewEncoder(w).Encode(rval)
+		},
+	))
+	docRouteMethod("/api/v1/friends/mutual/{memberID}", methodDocEntry{
+		Method:         "GET",
+		Description:    "List the members on both the logged in member's friends and another member's friends",
+		RequiredParams: []methodParams{{Name: "memberID", Type: "integer", Description: "Member id"}},
+		Response:       []memberRestricted{},
+	})
+}
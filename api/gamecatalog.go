@@ -0,0 +1,308 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type gameForm struct {
+	Name      string             `json:"name"`
+	Image     string             `json:"image"`
+	RoleID    string             `json:"roleID"`
+	AutoRole  bool               `json:"autoRole"`
+	ChannelID string             `json:"channelID"`
+	Platforms []*db.GamePlatform `json:"platforms"`
+	Aliases   []string           `json:"aliases"`
+}
+
+type gameMergeRequest struct {
+	From int `json:"from"`
+	Into int `json:"into"`
+}
+
+// apply copies the form onto a game, which marks it reviewed. Platforms are left alone when the form has none
+func (f gameForm) apply(g *db.Game) {
+	g.PendingReview = false
+	g.Name = f.Name
+	g.Image = f.Image
+	g.RoleID = f.RoleID
+	g.AutoRole = f.AutoRole
+	g.ChannelID = f.ChannelID
+	g.Aliases = f.Aliases
+	g.Platforms = f.Platforms
+}
+
+// gameFromPath returns the game in the gameID path variable. Otherwise an error response is written and nil returned
+func gameFromPath(w http.ResponseWriter, r *http.Request) *db.Game {
+	gameID, err := strconv.Atoi(mux.Vars(r)["gameID"])
+	if err != nil {
+		writeBadRequest(w, "invalid_game_id", "gameID must be a number")
+		return nil
+	}
+	game, err := DB.GameByID(gameID)
+	if err != nil {
+		writeDBError(w, err, "game lookup", zap.Int("gameID", gameID))
+		return nil
+	}
+	return game
+}
+
+func init() {
+	Router.Path("/api/v1/games").Methods("GET").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			limit := 25
+			if v := r.URL.Query().Get("limit"); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 1 || n > 100 {
+					writeBadRequest(w, "invalid_limit", "limit must be a number from 1 to 100")
+					return
+				}
+				limit = n
+			}
+			games, err := DB.SearchGames(r.URL.Query().Get("q"), limit)
+			if err != nil {
+				Logger.Error("searching games", zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+			json.NewEncoder(w).Encode(games)
+		},
+	))
+	docRouteMethod("/api/v1/games", methodDocEntry{
+		Method:      "GET",
+		Description: "Search the games catalog by name or alias. Exact matches come first, then the rest by name",
+		OptionalParams: []methodParams{
+			{Name: "q", Description: "Matches anywhere in a game's name or aliases"},
+			{Name: "limit", Type: "integer", Description: "How many games to return, up to 100. Defaults to 25"},
+		},
+		Response: []db.Game{},
+	})
+
+	// registered ahead of /api/v1/games/{gameID}, which would otherwise match it
+	Router.Path("/api/v1/games/trends").Methods("GET").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			week := time.Now()
+			if v := r.URL.Query().Get("week"); v != "" {
+				t, err := time.Parse("2006-01-02", v)
+				if err != nil {
+					writeBadRequest(w, "invalid_week", "week must be a date like 2006-01-02")
+					return
+				}
+				week = t
+			}
+			limit, ok := queryPositiveInt(w, r, "limit", 10)
+			if !ok {
+				return
+			}
+			trends, err := DB.GameTrends(week, limit)
+			if err != nil {
+				Logger.Error("game trends", zap.Time("week", week), zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(trends)
+		},
+	))
+	docRouteMethod("/api/v1/games/trends", methodDocEntry{
+		Method: "GET",
+		Description: "List the games most members played in a week, and those whose player counts rose and fell most " +
+			"from the week before. Weeks start on Monday, UTC, and are snapshotted hourly",
+		OptionalParams: []methodParams{
+			{Name: "week", Description: "Any day in the week, as 2006-01-02. Defaults to this week"},
+			{Name: "limit", Type: "integer", Description: "How many games to list in each of top, rising and falling. Defaults to 10"},
+		},
+		Response: db.GameTrends{},
+	})
+
+	Router.Path("/api/v1/games/{gameID}").Methods("GET").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			game := gameFromPath(w, r)
+			if game == nil {
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(game)
+		},
+	))
+	docRouteMethod("/api/v1/games/{gameID}", methodDocEntry{
+		Method:         "GET",
+		Description:    "Get a game with its platforms, aliases and Discord links",
+		RequiredParams: []methodParams{{Name: "gameID", Type: "integer", Description: "Game id"}},
+		Response:       db.Game{},
+	})
+
+	Router.Path("/api/v1/admin/games").Methods("POST").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			defer r.Body.Close()
+			admin := requireAdmin(w, r)
+			if admin == nil {
+				return
+			}
+			var form gameForm
+			if !decodeJSON(w, r, &form) {
+				return
+			}
+			var game db.Game
+			form.apply(&game)
+			if err := DB.SaveGame(&game); err != nil {
+				writeDBError(w, err, "creating game", zap.String("name", form.Name))
+				return
+			}
+			Logger.Info("created game", zap.Int("game", game.ID), zap.String("name", game.Name), zap.Int("admin", admin.ID))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(game)
+		},
+	))
+	docRouteMethod("/api/v1/admin/games", methodDocEntry{
+		Method:      "POST",
+		Description: "Add a game to the catalog. Its name and aliases must not be used by another game",
+		Auth:        authAdmin,
+		Request:     gameForm{},
+		Response:    db.Game{},
+	})
+
+	Router.Path("/api/v1/admin/games/duplicates").Methods("GET").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			if requireAdmin(w, r) == nil {
+				return
+			}
+			duplicates, err := DB.DuplicateGames()
+			if err != nil {
+				Logger.Error("listing duplicate games", zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(duplicates)
+		},
+	))
+	docRouteMethod("/api/v1/admin/games/duplicates", methodDocEntry{
+		Method:      "GET",
+		Description: "List the names used by more than one game, usually one per platform, as candidates for merging",
+		Auth:        authAdmin,
+		Response:    []db.GameDuplicate{},
+	})
+
+	Router.Path("/api/v1/admin/games/review").Methods("GET").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			if requireAdmin(w, r) == nil {
+				return
+			}
+			games, err := DB.PendingGames()
+			if err != nil {
+				Logger.Error("listing games to review", zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(games)
+		},
+	))
+	docRouteMethod("/api/v1/admin/games/review", methodDocEntry{
+		Method:      "GET",
+		Description: "List games added automatically from what members are playing. Saving a game marks it reviewed, and merging one into an existing game turns its name into an alias",
+		Auth:        authAdmin,
+		Response:    []db.Game{},
+	})
+
+	Router.Path("/api/v1/admin/games/merge").Methods("POST").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			defer r.Body.Close()
+			admin := requireAdmin(w, r)
+			if admin == nil {
+				return
+			}
+			var req gameMergeRequest
+			if !decodeJSON(w, r, &req) {
+				return
+			}
+			if req.From == 0 || req.Into == 0 {
+				writeBadRequest(w, "missing_parameter", "from and into are required")
+				return
+			}
+			game, err := DB.MergeGames(req.From, req.Into)
+			if err != nil {
+				writeDBError(w, err, "merging games", zap.Int("from", req.From), zap.Int("into", req.Into))
+				return
+			}
+			Logger.Info("merged games", zap.Int("from", req.From), zap.Int("into", req.Into), zap.Int("admin", admin.ID))
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(game)
+		},
+	))
+	docRouteMethod("/api/v1/admin/games/merge", methodDocEntry{
+		Method:      "POST",
+		Description: "Merge one game into another. Players, platforms and aliases move over, the merged game's name becomes an alias, and the merged game is deleted",
+		Auth:        authAdmin,
+		Request:     gameMergeRequest{},
+		Response:    db.Game{},
+	})
+
+	Router.Path("/api/v1/admin/games/{gameID}").Methods("PUT").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			defer r.Body.Close()
+			admin := requireAdmin(w, r)
+			if admin == nil {
+				return
+			}
+			game := gameFromPath(w, r)
+			if game == nil {
+				return
+			}
+			var form gameForm
+			if !decodeJSON(w, r, &form) {
+				return
+			}
+			form.apply(game)
+			if err := DB.SaveGame(game); err != nil {
+				writeDBError(w, err, "updating game", zap.Int("game", game.ID))
+				return
+			}
+			Logger.Info("updated game", zap.Int("game", game.ID), zap.Int("admin", admin.ID))
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(game)
+		},
+	))
+	docRouteMethod("/api/v1/admin/games/{gameID}", methodDocEntry{
+		Method:         "PUT",
+		Description:    "Replace a game's name, image, Discord links and aliases. Its platforms are replaced too when any are given",
+		Auth:           authAdmin,
+		RequiredParams: []methodParams{{Name: "gameID", Type: "integer", Description: "Game id"}},
+		Request:        gameForm{},
+		Response:       db.Game{},
+	})
+
+	Router.Path("/api/v1/admin/games/{gameID}").Methods("DELETE").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			admin := requireAdmin(w, r)
+			if admin == nil {
+				return
+			}
+			gameID, err := strconv.Atoi(mux.Vars(r)["gameID"])
+			if err != nil {
+				writeBadRequest(w, "invalid_game_id", "gameID must be a number")
+				return
+			}
+			if err := DB.DeleteGame(gameID); err != nil {
+				writeDBError(w, err, "deleting game", zap.Int("game", gameID))
+				return
+			}
+			Logger.Info("deleted game", zap.Int("game", gameID), zap.Int("admin", admin.ID))
+		},
+	))
+	docRouteMethod("/api/v1/admin/games/{gameID}", methodDocEntry{
+		Method:         "DELETE",
+		Description:    "Delete a game from the catalog along with the record of who played it",
+		Auth:           authAdmin,
+		RequiredParams: []methodParams{{Name: "gameID", Type: "integer", Description: "Game id"}},
+	})
+}
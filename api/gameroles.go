@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/FederationOfFathers/dashboard/bot"
+	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type gameRoleForm struct {
+	RoleID   string `json:"roleID"`
+	AutoRole bool   `json:"autoRole"`
+}
+
+func init() {
+	Router.Path("/api/v1/admin/games/{gameID}/role").Methods("PUT").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			defer r.Body.Close()
+			admin := requireAdmin(w, r)
+			if admin == nil {
+				return
+			}
+			gameID, err := strconv.Atoi(mux.Vars(r)["gameID"])
+			if err != nil {
+				writeBadRequest(w, "invalid_game_id", "gameID must be a number")
+				return
+			}
+			var form gameRoleForm
+			if !decodeJSON(w, r, &form) {
+				return
+			}
+			game, err := DB.SetGameRole(gameID, form.RoleID, form.AutoRole)
+			if err != nil {
+				writeDBError(w, err, "setting game role", zap.Int("game", gameID))
+				return
+			}
+			Logger.Info("set game role", zap.Int("game", game.ID), zap.String("role", game.RoleID), zap.Bool("auto", game.AutoRole), zap.Int("admin", admin.ID))
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(game)
+		},
+	))
+	docRouteMethod("/api/v1/admin/games/{gameID}/role", methodDocEntry{
+		Method: "PUT",
+		Description: "Link a game to a Discord role. With autoRole the role is given to members who play the game, " +
+			"unless they set noGameRoles. An empty roleID unlinks it",
+		Auth:           authAdmin,
+		RequiredParams: []methodParams{{Name: "gameID", Type: "integer", Description: "Game id"}},
+		Request:        gameRoleForm{},
+		Response:       db.Game{},
+	})
+
+	Router.Path("/api/v1/admin/gameroles").Methods("GET").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			if requireAdmin(w, r) == nil {
+				return
+			}
+			report, err := bot.RunGameRoles(true)
+			if err == bot.ErrNotConnected {
+				writeError(w, http.StatusServiceUnavailable, "discord_unavailable", "the bot is not connected to Discord")
+				return
+			}
+			if err != nil {
+				Logger.Error("planning game roles", zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(report)
+		},
+	))
+	docRouteMethod("/api/v1/admin/gameroles", methodDocEntry{
+		Method:      "GET",
+		Description: "Report the game roles the next run would give and take away, without changing anything",
+		Auth:        authAdmin,
+		Response:    bot.GameRoleReport{},
+	})
+
+	Router.Path("/api/v1/admin/gameroles").Methods("POST").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			admin := requireAdmin(w, r)
+			if admin == nil {
+				return
+			}
+			report, err := bot.RunGameRoles(false)
+			if err == bot.ErrNotConnected {
+				writeError(w, http.StatusServiceUnavailable, "discord_unavailable", "the bot is not connected to Discord")
+				return
+			}
+			if err != nil {
+				Logger.Error("updating game roles", zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+			Logger.Info("updated game roles", zap.Int("changes", len(report.Changes)), zap.Int("admin", admin.ID))
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(report)
+		},
+	))
+	docRouteMethod("/api/v1/admin/gameroles", methodDocEntry{
+		Method: "POST",
+		Description: "Give and take away game roles now, even while the hourly job is a dry run, and report the changes. " +
+			"Changes Discord refused are listed as not applied",
+		Auth:     authAdmin,
+		Response: bot.GameRoleReport{},
+	})
+}
@@ -2,35 +2,35 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/FederationOfFathers/dashboard/imagecache"
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
 
-type gamePlatform int
+type gameInfo struct {
+	ID           int                `json:"id"`
+	Name         string             `json:"name"`
+	Image        string             `json:"image"`
+	Platform     int                `json:"platform"`
+	PlatformID   int                `json:"platform_id"`
+	PlatformInfo *db.GamingPlatform `json:"platformInfo,omitempty"`
+}
 
-func (g gamePlatform) name() string {
-	if s, ok := map[int]string{
-		1: "Xbox360",
-		2: "XboxOne",
-		3: "PC",
-		4: "iOS",
-		5: "Android",
-		6: "Mobile",
-		7: "GearVR",
-		8: "Kindle",
-	}[int(g)]; ok {
-		return s
-	}
-	return "Unknown"
+type gamePlayer struct {
+	Slack  string    `json:"slack_id"`
+	Played time.Time `json:"played"`
 }
 
-func (g gamePlatform) MarshalJSON() ([]byte, error) {
-	return []byte(g.name()), nil
+type gamePlayers struct {
+	Game    gameInfo     `json:"game"`
+	Players []gamePlayer `json:"players"`
 }
 
 func getPicforGameName(name string) string {
@@ -50,7 +50,7 @@ func getPicforGameName(name string) string {
 		if image == "" {
 			continue
 		}
-		if platform == 2 {
+		if platform == db.PlatformXboxOne {
 			return image
 		}
 		if p == 0 || p > platform {
@@ -61,86 +61,81 @@ func getPicforGameName(name string) string {
 	return rval
 }
 
+// topGames ranks the games members seen in the last 30 days played in the last days, with a cdn image for each
+func topGames(days, limit int) ([]*db.TopGame, error) {
+	now := time.Now()
+	games, err := DB.TopGames(now.AddDate(0, 0, -days), now.AddDate(0, 0, -30), limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, game := range games {
+		if image := getPicforGameName(game.Name); image != "" {
+			game.Image = imagecache.Href(image, "")
+		}
+	}
+	return games, nil
+}
+
 func init() {
 	Router.Path("/api/v0/games/player/{id}/{days}.json").Methods("GET").Handler(authenticated(
 		func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Content-Type", "text/json")
-			days, _ := strconv.Atoi(mux.Vars(r)["days"])
-			user := mux.Vars(r)["id"]
-			rows, err := DB.Raw(
-				strings.Join([]string{
-					"SELECT g.id, g.platform, g.platform_id, g.name, g.image, mg.played",
-					"FROM membergames mg",
-					"JOIN games g ON (mg.game = g.id)",
-					"JOIN members m ON (mg.member = m.id)",
-					"WHERE m.slack = ?",
-					"AND mg.played >= DATE_SUB(NOW(), INTERVAL ? DAY)",
-				}, " "),
-				user,
-				days,
-			).Rows()
+			days, err := strconv.Atoi(mux.Vars(r)["days"])
 			if err != nil {
-				Logger.Error("querying player games", zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
+				writeBadRequest(w, "invalid_days", "days must be a number")
 				return
 			}
-			defer rows.Close()
-			var rval = []struct {
-				ID         int       `json:"id"`
-				Platform   int       `json:"platform"`
-				PlatformID int       `json:"platform_id"`
-				Name       string    `json:"name"`
-				Image      string    `json:"image"`
-				Played     time.Time `json:"played"`
-			}{}
-			for rows.Next() {
-				var row = struct {
-					ID         int       `json:"id"`
-					Platform   int       `json:"platform"`
-					PlatformID int       `json:"platform_id"`
-					Name       string    `json:"name"`
-					Image      string    `json:"image"`
-					Played     time.Time `json:"played"`
-				}{}
-				err := rows.Scan(
-					&row.ID,
-					&row.Platform,
-					&row.PlatformID,
-					&row.Name,
-					&row.Image,
-					&row.Played,
-				)
-				if err != nil {
-					Logger.Error("Error scanning", zap.Error(err))
-					continue
-				}
-				rval = append(rval, row)
+			member, err := DB.MemberByAny(mux.Vars(r)["id"])
+			if errors.Is(err, db.ErrNotFound) {
+				// unknown players have always had an empty list here
+				json.NewEncoder(w).Encode([]*db.PlayedGame{})
+				return
+			} else if err != nil {
+				writeDBError(w, err, "player lookup", zap.String("id", mux.Vars(r)["id"]))
+				return
+			}
+			rval, err := DB.MemberPlayedGames(member.ID, time.Now().AddDate(0, 0, -days))
+			if err != nil {
+				Logger.Error("querying player games", zap.Error(err))
+				writeInternalError(w)
+				return
 			}
 			json.NewEncoder(w).Encode(rval)
 		}))
+	docRouteMethod("/api/v0/games/player/{id}/{days}.json", methodDocEntry{
+		Method:      "GET",
+		Description: "List the games a player has played recently, from Discord presence and their Steam library",
+		RequiredParams: []methodParams{
+			{Name: "id", Description: "Member id or Slack id of the player"},
+			{Name: "days", Type: "integer", Description: "How many days back to look"},
+		},
+		Response: []db.PlayedGame{},
+	})
 	Router.Path("/api/v0/games/played/{game}/{days}.json").Methods("GET").Handler(authenticated(
 		func(w http.ResponseWriter, r *http.Request) {
-			id, _ := strconv.Atoi(mux.Vars(r)["game"])
-			var game = struct {
-				ID         int    `json:"id"`
-				Name       string `json:"name"`
-				Image      string `json:"image"`
-				Platform   int    `json:"platform"`
-				PlatformID int    `json:"platform_id"`
-			}{}
-			err := DB.Raw("SELECT id,name,image,platform,platform_id FROM games WHERE id=? LIMIT 1", id).Row().Scan(
-				&game.ID,
-				&game.Name,
-				&game.Image,
-				&game.Platform,
-				&game.PlatformID,
-			)
+			id, err := strconv.Atoi(mux.Vars(r)["game"])
+			if err != nil {
+				writeBadRequest(w, "invalid_game", "game must be a number")
+				return
+			}
+			d, err := strconv.Atoi(mux.Vars(r)["days"])
 			if err != nil {
-				Logger.Error("eror querying game", zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
+				writeBadRequest(w, "invalid_days", "days must be a number")
 				return
 			}
-			d, _ := strconv.Atoi(mux.Vars(r)["days"])
+			found, err := DB.GameByID(id)
+			if err != nil {
+				writeDBError(w, err, "querying game", zap.Int("game", id))
+				return
+			}
+			var game = gameInfo{
+				ID:           found.ID,
+				Name:         found.Name,
+				Image:        found.Image,
+				Platform:     found.Platform,
+				PlatformID:   found.PlatformID,
+				PlatformInfo: found.PlatformInfo,
+			}
 			rows, err := DB.Raw(strings.Join([]string{
 				"SELECT slack,played",
 				"FROM members m",
@@ -155,31 +150,16 @@ func init() {
 			).Rows()
 			if err != nil {
 				Logger.Error("Error querying", zap.String("uri", r.URL.RawPath), zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
+				writeInternalError(w)
 				return
 			}
 			w.Header().Set("Content-Type", "application/json")
-			var rval = struct {
-				Game struct {
-					ID         int    `json:"id"`
-					Name       string `json:"name"`
-					Image      string `json:"image"`
-					Platform   int    `json:"platform"`
-					PlatformID int    `json:"platform_id"`
-				} `json:"game"`
-				Players []struct {
-					Slack  string    `json:"slack_id"`
-					Played time.Time `json:"played"`
-				} `json:"players"`
-			}{
+			var rval = gamePlayers{
 				Game: game,
 			}
 			defer rows.Close()
 			for rows.Next() {
-				var row struct {
-					Slack  string    `json:"slack_id"`
-					Played time.Time `json:"played"`
-				}
+				var row gamePlayer
 				err := rows.Scan(&row.Slack, &row.Played)
 				if err != nil {
 					Logger.Error("Error scanning", zap.String("uri", r.URL.RawPath), zap.Error(err))
@@ -189,50 +169,44 @@ func init() {
 			}
 			json.NewEncoder(w).Encode(rval)
 		}))
+	docRouteMethod("/api/v0/games/played/{game}/{days}.json", methodDocEntry{
+		Method:      "GET",
+		Description: "List the active members who have played a game recently",
+		RequiredParams: []methodParams{
+			{Name: "game", Type: "integer", Description: "Game id"},
+			{Name: "days", Type: "integer", Description: "How many days back to look"},
+		},
+		Response: gamePlayers{},
+	})
 	Router.Path("/api/v0/games/played/top/{days}/{number}.json").Methods("GET").Handler(authenticated(
 		func(w http.ResponseWriter, r *http.Request) {
-			n, _ := strconv.Atoi(mux.Vars(r)["number"])
-			d, _ := strconv.Atoi(mux.Vars(r)["days"])
-			rows, err := DB.Raw(
-				"SELECT g.id, g.name, COUNT(mg.member) as players "+
-					"FROM membergames mg JOIN games g ON( mg.game = g.id ) JOIN members m ON( mg.member = m.id) "+
-					"WHERE mg.played > DATE_SUB(NOW(), INTERVAL ? DAY) "+
-					"AND m.seen > (UNIX_TIMESTAMP(DATE_SUB(NOW(), INTERVAL 30 DAY))) "+
-					"GROUP BY g.id ORDER BY players DESC LIMIT ?",
-				d,
-				n,
-			).Rows()
+			n, err := strconv.Atoi(mux.Vars(r)["number"])
+			if err != nil {
+				writeBadRequest(w, "invalid_number", "number must be a number")
+				return
+			}
+			d, err := strconv.Atoi(mux.Vars(r)["days"])
+			if err != nil {
+				writeBadRequest(w, "invalid_days", "days must be a number")
+				return
+			}
+			rval, err := topGames(d, n)
 			if err != nil {
 				Logger.Error("Error querying", zap.String("uri", r.URL.RawPath), zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
+				writeInternalError(w)
 				return
 			}
 			w.Header().Set("Content-Type", "application/json")
-			var rval = []struct {
-				ID      int    `json:"id"`
-				Name    string `json:"name"`
-				Image   string `json:"image"`
-				Players int    `json:"players"`
-			}{}
-			defer rows.Close()
-			for rows.Next() {
-				var row struct {
-					ID      int    `json:"id"`
-					Name    string `json:"name"`
-					Image   string `json:"image"`
-					Players int    `json:"players"`
-				}
-				err := rows.Scan(&row.ID, &row.Name, &row.Players)
-				if err != nil {
-					Logger.Error("Error scanning", zap.String("uri", r.URL.RawPath), zap.Error(err))
-					continue
-				}
-				if image := getPicforGameName(row.Name); image != "" {
-					row.Image = "/api/v0/cdn/" + image
-				}
-				rval = append(rval, row)
-			}
 			json.NewEncoder(w).Encode(rval)
 		},
 	))
+	docRouteMethod("/api/v0/games/played/top/{days}/{number}.json", methodDocEntry{
+		Method:      "GET",
+		Description: "List the games with the most active players recently",
+		RequiredParams: []methodParams{
+			{Name: "days", Type: "integer", Description: "How many days back to look"},
+			{Name: "number", Type: "integer", Description: "How many games to list"},
+		},
+		Response: []db.TopGame{},
+	})
 }
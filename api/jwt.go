@@ -7,7 +7,10 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/FederationOfFathers/dashboard/activity"
+	"github.com/FederationOfFathers/dashboard/db"
 	"github.com/gorilla/securecookie"
+	"go.uber.org/zap"
 )
 
 // JWTSecret is the Secret used when signing JTW tokens
@@ -53,6 +56,17 @@ func authorize(userID string, memberID int, w http.ResponseWriter, r *http.Reque
 			},
 		)
 	}
+	if memberID > 0 {
+		err := DB.RecordLogin(&db.LoginHistory{
+			MemberID:  memberID,
+			Via:       r.URL.Path,
+			IP:        r.RemoteAddr,
+			UserAgent: r.UserAgent(),
+		})
+		if err != nil {
+			Logger.Error("unable to record login", zap.Int("memberID", memberID), zap.Error(err))
+		}
+	}
 }
 
 // TODO if using old userid, replace with memberid based token
@@ -81,10 +95,12 @@ func authenticated(next http.HandlerFunc) http.Handler {
 		var err error
 		r, err = authorized(w, r)
 		if err != nil {
-			w.WriteHeader(http.StatusForbidden)
-			w.Write([]byte("forbidden"))
+			writeForbidden(w, "unauthenticated", "you must be logged in")
 			return
 		}
+		if id, err := strconv.Atoi(requestAuth(r)["memberid"]); err == nil {
+			activity.Record(id, activity.SourceAPI)
+		}
 		next(w, r)
 	})
 }
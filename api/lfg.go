@@ -0,0 +1,210 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/FederationOfFathers/dashboard/bot"
+	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/FederationOfFathers/dashboard/messaging"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// LFGCreateRequestBody opens a looking for group entry. GameID picks a game from the catalog, otherwise Game
+// names it
+type LFGCreateRequestBody struct {
+	GameID int    `json:"gameID"`
+	Game   string `json:"game"`
+	Size   int    `json:"size"`
+	// Minutes is how long the entry stays open, bot.LFGDuration when not given
+	Minutes int `json:"minutes"`
+}
+
+// lfgFromPath returns the lfgID path variable. Otherwise an error response is written and false returned
+func lfgFromPath(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["lfgID"])
+	if err != nil {
+		writeBadRequest(w, "invalid_lfg_id", "lfgID must be a number")
+		return 0, false
+	}
+	return id, true
+}
+
+func init() {
+	Router.Path("/api/v1/lfg").Methods("GET").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			lfgs, err := DB.ActiveLFGs(time.Now())
+			if err != nil {
+				Logger.Error("listing lfgs", zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(lfgs)
+		},
+	))
+	docRouteMethod("/api/v1/lfg", methodDocEntry{
+		Method:      "GET",
+		Description: "List the looking for group entries which have not expired or been closed, soonest to expire first. Full groups stay listed until they expire",
+		Response:    []db.LFG{},
+	})
+
+	Router.Path("/api/v1/lfg/create").Methods("POST").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			defer r.Body.Close()
+			member := requestMember(w, r)
+			if member == nil {
+				return
+			}
+			var data LFGCreateRequestBody
+			if !decodeJSON(w, r, &data) {
+				return
+			}
+			var l = &db.LFG{HostID: member.ID, Game: data.Game, Size: data.Size}
+			if data.GameID != 0 {
+				game, err := DB.GameByID(data.GameID)
+				if err != nil {
+					writeDBError(w, err, "lfg game lookup", zap.Int("game", data.GameID))
+					return
+				}
+				l.GameID, l.Game = game.ID, game.Name
+			}
+			if data.Minutes == 0 {
+				data.Minutes = bot.LFGDuration
+			}
+			if err := DB.CreateLFG(l, time.Duration(data.Minutes)*time.Minute); err != nil {
+				writeDBError(w, err, "creating lfg", zap.Int("member", member.ID))
+				return
+			}
+			Logger.Info("created lfg", zap.Int("lfg", l.ID), zap.String("game", l.Game), zap.Int("member", member.ID))
+			messaging.SendLFGMessage(l)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(l)
+		},
+	))
+	docRouteMethod("/api/v1/lfg/create", methodDocEntry{
+		Method: "POST",
+		Description: "Look for a group to play a game with right now, hosted by the logged in member, and post it in Discord " +
+			"with buttons to join. A member may only host one open entry at a time",
+		Request:  LFGCreateRequestBody{},
+		Response: db.LFG{},
+	})
+
+	Router.Path("/api/v1/lfg/{lfgID}").Methods("GET").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			id, ok := lfgFromPath(w, r)
+			if !ok {
+				return
+			}
+			l, err := DB.LFGByID(id)
+			if err != nil {
+				writeDBError(w, err, "lfg lookup", zap.Int("lfg", id))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(l)
+		},
+	))
+	docRouteMethod("/api/v1/lfg/{lfgID}", methodDocEntry{
+		Method:         "GET",
+		Description:    "Get a looking for group entry with its members",
+		RequiredParams: []methodParams{{Name: "lfgID", Type: "integer", Description: "LFG id"}},
+		Response:       db.LFG{},
+	})
+
+	Router.Path("/api/v1/lfg/{lfgID}/join").Methods("POST").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			member := requestMember(w, r)
+			if member == nil {
+				return
+			}
+			id, ok := lfgFromPath(w, r)
+			if !ok {
+				return
+			}
+			l, err := DB.JoinLFG(id, member.ID)
+			if err != nil {
+				writeDBError(w, err, "joining lfg", zap.Int("lfg", id), zap.Int("member", member.ID))
+				return
+			}
+			// the member who takes the last place fills the group
+			if l.FilledAt != nil {
+				messaging.SendLFGFilledMessage(l)
+			}
+			messaging.SendLFGMessage(l)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(l)
+		},
+	))
+	docRouteMethod("/api/v1/lfg/{lfgID}/join", methodDocEntry{
+		Method:         "POST",
+		Description:    "Join a looking for group entry as the logged in member. Taking the last place pings the group in Discord",
+		RequiredParams: []methodParams{{Name: "lfgID", Type: "integer", Description: "LFG id"}},
+		Response:       db.LFG{},
+	})
+
+	Router.Path("/api/v1/lfg/{lfgID}/leave").Methods("POST").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			member := requestMember(w, r)
+			if member == nil {
+				return
+			}
+			id, ok := lfgFromPath(w, r)
+			if !ok {
+				return
+			}
+			l, err := DB.LeaveLFG(id, member.ID)
+			if err != nil {
+				writeDBError(w, err, "leaving lfg", zap.Int("lfg", id), zap.Int("member", member.ID))
+				return
+			}
+			messaging.SendLFGMessage(l)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(l)
+		},
+	))
+	docRouteMethod("/api/v1/lfg/{lfgID}/leave", methodDocEntry{
+		Method:         "POST",
+		Description:    "Give up the logged in member's place in a looking for group entry which has not filled yet",
+		RequiredParams: []methodParams{{Name: "lfgID", Type: "integer", Description: "LFG id"}},
+		Response:       db.LFG{},
+	})
+
+	Router.Path("/api/v1/lfg/{lfgID}").Methods("DELETE").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			member := requestMember(w, r)
+			if member == nil {
+				return
+			}
+			id, ok := lfgFromPath(w, r)
+			if !ok {
+				return
+			}
+			admin, err := bot.IsUserIDAdmin(member.Discord)
+			if err != nil && err != bot.ErrUsernameNotFound {
+				Logger.Error("error determining admin status", zap.Int("member", member.ID), zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+			l, err := DB.CloseLFG(id, member.ID, admin)
+			if err != nil {
+				writeDBError(w, err, "closing lfg", zap.Int("lfg", id), zap.Int("member", member.ID))
+				return
+			}
+			Logger.Info("closed lfg", zap.Int("lfg", l.ID), zap.Int("member", member.ID))
+			messaging.SendLFGMessage(l)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(l)
+		},
+	))
+	docRouteMethod("/api/v1/lfg/{lfgID}", methodDocEntry{
+		Method:         "DELETE",
+		Description:    "Close a looking for group entry before it expires. Only its host or an admin may",
+		RequiredParams: []methodParams{{Name: "lfgID", Type: "integer", Description: "LFG id"}},
+		Response:       db.LFG{},
+	})
+}
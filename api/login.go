@@ -3,6 +3,7 @@ package api
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -10,8 +11,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/FederationOfFathers/dashboard/db"
 	"github.com/gorilla/mux"
-	"github.com/jinzhu/gorm"
 	uuid "github.com/nu7hatch/gouuid"
 	hashids "github.com/speps/go-hashids"
 	"go.uber.org/zap"
@@ -50,11 +51,17 @@ func init() {
 		).Error
 		if err != nil {
 			Logger.Error("inserting", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			writeInternalError(w)
 			return
 		}
 		json.NewEncoder(w).Encode(s)
 	})
+	docRouteMethod("/api/v0/login/get", methodDocEntry{
+		Method:      "GET",
+		Description: "Get a new login code, valid for 15 minutes, to be claimed through the bot",
+		Auth:        authNone,
+		Response:    "",
+	})
 
 	// v0 using slack
 	Router.Path("/api/v0/login/check/{code}").Methods("GET").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -70,7 +77,7 @@ func init() {
 				return
 			}
 			Logger.Error("scanning", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			writeInternalError(w)
 			return
 		}
 		if who != "" {
@@ -81,6 +88,12 @@ func init() {
 		}
 		json.NewEncoder(w).Encode("wait")
 	})
+	docRouteMethod("/api/v0/login/check/{code}", methodDocEntry{
+		Method:      "GET",
+		Description: "Check a login code by slack id. Responds \"wait\" until claimed, \"gone\" if expired, or redirects to the login link",
+		Auth:        authNone,
+		Response:    "",
+	})
 
 	// V1 using member id instead of slack
 	Router.Path("/api/v1/login/check/{code}").Methods("GET").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -98,12 +111,12 @@ func init() {
 		// handle errors
 		if err != nil {
 			// no record = already used the code
-			if err == gorm.ErrRecordNotFound {
+			if errors.Is(err, db.ErrNotFound) {
 				json.NewEncoder(w).Encode("gone")
 				return
 			}
 			Logger.Error("login code check", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			writeInternalError(w)
 			return
 		}
 
@@ -118,6 +131,12 @@ func init() {
 		// keep waiting
 		json.NewEncoder(w).Encode("wait")
 	})
+	docRouteMethod("/api/v1/login/check/{code}", methodDocEntry{
+		Method:      "GET",
+		Description: "Check a login code by member id. Responds \"wait\" until claimed, \"gone\" if expired, or redirects to the login link",
+		Auth:        authNone,
+		Response:    "",
+	})
 
 	// LOGOUT
 	Router.Path("/api/v0/logout").Methods("GET").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -135,5 +154,10 @@ func init() {
 		)
 		json.NewEncoder(w).Encode("logout complete")
 	})
+	docRouteMethod("/api/v0/logout", methodDocEntry{
+		Method:      "GET",
+		Description: "Log a user out of the API",
+		Auth:        authNone,
+	})
 
 }
@@ -7,8 +7,8 @@ import (
 	"strings"
 
 	"github.com/FederationOfFathers/dashboard/bot"
+	"github.com/FederationOfFathers/dashboard/db"
 	"github.com/gorilla/mux"
-	"github.com/jinzhu/gorm"
 	"go.uber.org/zap"
 )
 
@@ -17,48 +17,103 @@ func init() {
 	Router.Path("/api/v0/member/{memberID}").Methods("GET").Handler(authenticated(
 		func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Content-Type", "application/json")
-			member, err := DB.MemberByAny(mux.Vars(r)["memberID"])
-			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
+			viewer := requestViewer(w, r)
+			if viewer == nil {
 				return
 			}
-			if err == gorm.ErrRecordNotFound || member == nil || member.Slack != mux.Vars(r)["memberID"] {
-				http.NotFound(w, r)
+			member, err := DB.MemberBySlackID(mux.Vars(r)["memberID"])
+			if err != nil {
+				writeDBError(w, err, "member lookup", zap.String("memberID", mux.Vars(r)["memberID"]))
 				return
 			}
-			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
+			if member, err = viewer.visibleMember(member); err != nil {
+				Logger.Error("Unable to apply member privacy", zap.Error(err))
+				writeInternalError(w)
 				return
 			}
 			json.NewEncoder(w).Encode(member)
 		},
 	))
+	docRouteMethod("/api/v0/member/{memberID}", methodDocEntry{
+		Method:         "GET",
+		Description:    "Get a member by slack id",
+		RequiredParams: []methodParams{{Name: "memberID", Description: "Slack id of the member"}},
+		Response:       db.Member{},
+	})
 
 	// v1, using member id
 	Router.Path("/api/v1/member/{memberID}").Methods("GET").Handler(authenticated(
 		func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Content-Type", "application/json")
 
-			// get memberId as an int, invalid member id returns a 404
+			// get memberId as an int, invalid member id returns a 400
 			memberID, err := strconv.Atoi(mux.Vars(r)["memberID"])
 			if err != nil {
-				w.WriteHeader(http.StatusBadRequest)
-				w.Write([]byte("400 bad request"))
+				writeBadRequest(w, "invalid_member_id", "memberID must be a number")
 				return
 			}
 
-			// find the member
+			// find the member, 404 if not found and 500 on db error
 			member, err := DB.MemberByID(memberID)
-			if err == gorm.ErrRecordNotFound || member == nil { // if not found, return 404
-				http.NotFound(w, r)
+			if err != nil {
+				writeDBError(w, err, "member lookup", zap.Int("memberID", memberID))
 				return
-			} else if err != nil { // if db error, 500
-				w.WriteHeader(http.StatusInternalServerError)
+			}
+			viewer := requestViewer(w, r)
+			if viewer == nil {
+				return
+			}
+			if member, err = viewer.visibleMember(member); err != nil {
+				Logger.Error("Unable to apply member privacy", zap.Error(err))
+				writeInternalError(w)
 				return
 			}
 			json.NewEncoder(w).Encode(member)
 		},
 	))
+	docRouteMethod("/api/v1/member/{memberID}", methodDocEntry{
+		Method:         "GET",
+		Description:    "Get a member by member id",
+		RequiredParams: []methodParams{{Name: "memberID", Type: "integer", Description: "Member id"}},
+		Response:       db.Member{},
+	})
+
+	Router.Path("/api/v1/member/{memberID}/activity").Methods("GET").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			viewer := requestViewer(w, r)
+			if viewer == nil {
+				return
+			}
+			memberID, err := strconv.Atoi(mux.Vars(r)["memberID"])
+			if err != nil {
+				writeBadRequest(w, "invalid_member_id", "memberID must be a number")
+				return
+			}
+			if memberID != viewer.member.ID && !viewer.admin {
+				writeForbidden(w, "not_allowed", "only admins may see other members' activity")
+				return
+			}
+			member, err := DB.MemberByID(memberID)
+			if err != nil {
+				writeDBError(w, err, "member lookup", zap.Int("memberID", memberID))
+				return
+			}
+			summary, err := DB.MemberActivitySummary(member)
+			if err != nil {
+				Logger.Error("summarizing activity", zap.Int("memberID", memberID), zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+			json.NewEncoder(w).Encode(summary)
+		},
+	))
+	docRouteMethod("/api/v1/member/{memberID}/activity", methodDocEntry{
+		Method:         "GET",
+		Description:    "Get when a member was last active and how active they have been over the last 30 and 90 days. Members may only see their own, admins anyone's",
+		RequiredParams: []methodParams{{Name: "memberID", Type: "integer", Description: "Member id"}},
+		Response:       db.ActivitySummary{},
+	})
 
 	// v1, using member id
 	Router.Path("/api/v1/member/{memberID}").Methods("PUT", "POST").Handler(
@@ -70,103 +125,74 @@ func init() {
 				memberID := mux.Vars(r)["memberID"]
 				mid, err := strconv.Atoi(memberID)
 				if err != nil {
-					Logger.Error("bad member id", zap.String("memberID", memberID), zap.Error(err))
-					w.WriteHeader(http.StatusBadRequest)
-					return;
+					writeBadRequest(w, "invalid_member_id", "memberID must be a number")
+					return
 				}
 				member, err := DB.MemberByID(mid)
 				if err != nil {
-					Logger.Error("member lookup", zap.String("memberID", memberID), zap.Error(err))
-					http.NotFound(w, r)
-					return
-				}
-				if member.ID != mid {
-					Logger.Error("member mismatch", zap.Int("member", member.ID), zap.String("memberID", memberID), zap.Error(err))
-					http.NotFound(w, r)
+					writeDBError(w, err, "member lookup", zap.String("memberID", memberID))
 					return
 				}
 				if !strings.Contains(strings.ToLower(r.Header.Get("Content-Type")), "json") {
-					Logger.Error("content-type", zap.Error(err))
-					http.NotFound(w, r)
+					writeError(w, http.StatusUnsupportedMediaType, "unsupported_content_type", "the request body must be JSON")
 					return
 				}
 				var form = map[string]string{}
-
-				err = json.NewDecoder(r.Body).Decode(&form)
-				if err != nil {
-					Logger.Error("Error decoding JSON", zap.String("uri", r.URL.RawPath), zap.Error(err))
-					w.WriteHeader(http.StatusInternalServerError)
+				if !decodeJSON(w, r, &form) {
 					return
 				}
 
-				id := getMemberID(r)
-				amid, err := strconv.Atoi(id)
-				if err != nil {
-					Logger.Error("bad member id in request", zap.String("id", id), zap.Error(err))
-					w.WriteHeader(http.StatusInternalServerError)
+				requester := requestMember(w, r)
+				if requester == nil {
 					return
 				}
 
-				admin, _ := bot.IsUserIDAdmin(member.Discord)
-				if amid != member.ID && !admin {
-					http.NotFound(w, r)
+				admin, _ := bot.IsUserIDAdmin(requester.Discord)
+				if requester.ID != member.ID && !admin {
 					Logger.Debug(
 						"access control",
-						zap.Int("amid", amid),
+						zap.Int("amid", requester.ID),
 						zap.Bool("admin", admin),
-						zap.String("discord", member.Discord))
+						zap.String("discord", requester.Discord))
+					writeForbidden(w, "not_allowed", "only admins may change other members")
 					return
 				}
 
 				changed := false
 				changedXBL := false
+				before := member.PlatformIDs()
 				for k, v := range form {
-					switch strings.ToLower(k) {
-					case "xbl":
-						member.Xbl = v
-						changed = true
+					if _, ok := db.PlatformByKey(k); !ok {
+						continue
+					}
+					if err := member.SetPlatformID(k, v); err != nil {
+						writeDBError(w, err, "setting platform id")
+						return
+					}
+					changed = true
+					if strings.ToLower(k) == "xbl" {
 						changedXBL = true
-					case "psn":
-						member.Psn = v
-						changed = true
 					}
 				}
 				if changed {
-					if err := member.Save(); err != nil {
+					if err := member.SavePlatformIDs(); err != nil {
 						Logger.Error("saving member", zap.Error(err))
-						w.WriteHeader(http.StatusInternalServerError)
+						writeInternalError(w)
 						return
 					}
+					recordAudit(r, requester, member, "member.update", before, member.PlatformIDs())
 					if changedXBL {
-						err := DB.Exec(
-							strings.Join([]string{
-								"INSERT INTO membermeta",
-								"(member_id,meta_key,meta_value)",
-								"VALUES (?,'_xbl_corrected',NOW())",
-								"ON DUPLICATE KEY UPDATE meta_value=NOW()",
-							}, " "),
-							member.ID,
-						).Error
+						err := DB.SetLegacyMetaNow(member.ID, "_xbl_corrected")
 						if err != nil {
 							Logger.Error("error setting _xbl_corrected", zap.Int("member", member.ID), zap.Error(err))
 						}
 
-						err = DB.Exec("DELETE FROM membergames WHERE member = ?", member.ID).Error
+						// only the Xbox games go, what was seen on Discord or imported from Steam still stands
+						err = DB.DeleteMemberGames(member.ID, db.GameSourceXbox)
 						if err != nil {
 							Logger.Error("error deleting membergames", zap.Int("member", member.ID), zap.Error(err))
 						}
-						err = DB.Exec(
-							strings.Join([]string{
-								"DELETE FROM membermeta",
-								"WHERE member_id = ?",
-								"AND meta_key IN(?,?,?)",
-								"LIMIT 3",
-							}, " "),
-							member.ID,
-							"_games_last_check",
-							"_xuid_last_check",
-							"xuid",
-						).Error
+						err = DB.DeleteMemberMeta(member.ID, "_games_last_check", "_xuid_last_check", "xuid")
 						if err != nil {
 							Logger.Error("error deleting membermeta", zap.Int("member", member.ID), zap.Error(err))
 						}
@@ -175,4 +201,12 @@ func init() {
 			},
 		),
 	)
+	for _, method := range []string{"PUT", "POST"} {
+		docRouteMethod("/api/v1/member/{memberID}", methodDocEntry{
+			Method:         method,
+			Description:    "Update platform ids (xbl, psn, steam, battlenet, epic, switch, ea, riot) for a member. Members may update themselves, admins anyone",
+			RequiredParams: []methodParams{{Name: "memberID", Type: "integer", Description: "Member id"}},
+			Request:        map[string]string{},
+		})
+	}
 }
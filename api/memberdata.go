@@ -0,0 +1,203 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/FederationOfFathers/dashboard/roster"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type memberExport struct {
+	*db.MemberExport
+	Friends []int `json:"friends"`
+}
+
+type deletionRequestForm struct {
+	Reason string `json:"reason"`
+}
+
+type deletionReviewForm struct {
+	Approve bool `json:"approve"`
+}
+
+// memberForSelfOrAdmin returns the member in the memberID path variable if the requester is that member or an admin.
+// Otherwise an error response is written and nil returned
+func memberForSelfOrAdmin(w http.ResponseWriter, r *http.Request) (*profileViewer, *db.Member) {
+	viewer := requestViewer(w, r)
+	if viewer == nil {
+		return nil, nil
+	}
+	memberID, err := strconv.Atoi(mux.Vars(r)["memberID"])
+	if err != nil {
+		writeBadRequest(w, "invalid_member_id", "memberID must be a number")
+		return nil, nil
+	}
+	member, err := DB.MemberByID(memberID)
+	if err != nil {
+		writeDBError(w, err, "member lookup", zap.Int("memberID", memberID))
+		return nil, nil
+	}
+	if member.ID != viewer.member.ID && !viewer.admin {
+		writeForbidden(w, "not_allowed", "only admins may see or change other members' data")
+		return nil, nil
+	}
+	return viewer, member
+}
+
+func init() {
+	Router.Path("/api/v1/member/{memberID}/export").Methods("GET").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			viewer, member := memberForSelfOrAdmin(w, r)
+			if member == nil {
+				return
+			}
+			export, err := DB.ExportMember(member.ID)
+			if err != nil {
+				writeDBError(w, err, "exporting member", zap.Int("memberID", member.ID))
+				return
+			}
+			export.Meta = db.ReadableMeta(export.Meta, viewer.admin)
+			friends := roster.Get(member.ID)
+			recordAudit(r, viewer.member, member, "member.export", nil, nil)
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"member-%d-export.json\"", member.ID))
+			json.NewEncoder(w).Encode(memberExport{MemberExport: export, Friends: friends})
+		},
+	))
+	docRouteMethod("/api/v1/member/{memberID}/export", methodDocEntry{
+		Method:         "GET",
+		Description:    "Download everything stored about a member. Members may export their own data, admins anyone's",
+		RequiredParams: []methodParams{{Name: "memberID", Type: "integer", Description: "Member id"}},
+		Response:       memberExport{},
+	})
+
+	Router.Path("/api/v1/member/{memberID}/deletion").Methods("POST").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			defer r.Body.Close()
+			viewer, member := memberForSelfOrAdmin(w, r)
+			if member == nil {
+				return
+			}
+			var form deletionRequestForm
+			if r.ContentLength != 0 && !decodeJSON(w, r, &form) {
+				return
+			}
+			request, err := DB.RequestDeletion(member.ID, form.Reason)
+			if err != nil {
+				writeDBError(w, err, "requesting deletion", zap.Int("memberID", member.ID))
+				return
+			}
+			recordAudit(r, viewer.member, member, "member.deletion_request", nil, request)
+			Logger.Info("member requested deletion", zap.Int("memberID", member.ID), zap.Uint("request", request.ID))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(request)
+		},
+	))
+	docRouteMethod("/api/v1/member/{memberID}/deletion", methodDocEntry{
+		Method:         "POST",
+		Description:    "Ask for a member's data to be deleted. The member is anonymized once an admin approves the request",
+		RequiredParams: []methodParams{{Name: "memberID", Type: "integer", Description: "Member id"}},
+		Request:        deletionRequestForm{},
+		Response:       db.DeletionRequest{},
+	})
+
+	Router.Path("/api/v1/member/{memberID}/deletion").Methods("DELETE").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			_, member := memberForSelfOrAdmin(w, r)
+			if member == nil {
+				return
+			}
+			if err := DB.CancelDeletion(member.ID); err != nil {
+				writeDBError(w, err, "cancelling deletion", zap.Int("memberID", member.ID))
+				return
+			}
+		},
+	))
+	docRouteMethod("/api/v1/member/{memberID}/deletion", methodDocEntry{
+		Method:         "DELETE",
+		Description:    "Withdraw a member's pending deletion request",
+		RequiredParams: []methodParams{{Name: "memberID", Type: "integer", Description: "Member id"}},
+	})
+
+	Router.Path("/api/v1/admin/deletions").Methods("GET").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			if requireAdmin(w, r) == nil {
+				return
+			}
+			requests, err := DB.PendingDeletionRequests()
+			if err != nil {
+				Logger.Error("listing deletion requests", zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(requests)
+		},
+	))
+	docRouteMethod("/api/v1/admin/deletions", methodDocEntry{
+		Method:      "GET",
+		Description: "List pending deletion requests, oldest first",
+		Auth:        authAdmin,
+		Response:    []*db.DeletionRequest{},
+	})
+
+	Router.Path("/api/v1/admin/deletions/{requestID}").Methods("POST").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			defer r.Body.Close()
+			admin := requireAdmin(w, r)
+			if admin == nil {
+				return
+			}
+			requestID, err := strconv.ParseUint(mux.Vars(r)["requestID"], 10, 32)
+			if err != nil {
+				writeBadRequest(w, "invalid_request_id", "requestID must be a number")
+				return
+			}
+			var form deletionReviewForm
+			if !decodeJSON(w, r, &form) {
+				return
+			}
+			// the member is looked up first as approving removes their name from the record
+			pending, err := DB.DeletionRequestByID(uint(requestID))
+			if err != nil {
+				writeDBError(w, err, "reviewing deletion", zap.Uint64("request", requestID))
+				return
+			}
+			target, err := DB.MemberByID(pending.MemberID)
+			if err != nil {
+				writeDBError(w, err, "member lookup", zap.Int("memberID", pending.MemberID))
+				return
+			}
+			request, err := DB.ReviewDeletion(uint(requestID), admin.ID, form.Approve)
+			if err != nil {
+				writeDBError(w, err, "reviewing deletion", zap.Uint64("request", requestID))
+				return
+			}
+			action := "member.deletion_rejected"
+			if form.Approve {
+				action = "member.delete"
+				if err := roster.Clear(target.ID); err != nil {
+					Logger.Error("unable to clear roster", zap.Int("memberID", target.ID), zap.Error(err))
+				}
+			}
+			recordAudit(r, admin, target, action, nil, request)
+			Logger.Info("reviewed deletion request", zap.Uint64("request", requestID), zap.Bool("approved", form.Approve), zap.Int("admin", admin.ID))
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(request)
+		},
+	))
+	docRouteMethod("/api/v1/admin/deletions/{requestID}", methodDocEntry{
+		Method:         "POST",
+		Description:    "Approve or reject a pending deletion request. Approving anonymizes the member and deletes their meta, streams, games, friends, activity and logins, keeping their event slots under a placeholder name",
+		Auth:           authAdmin,
+		RequiredParams: []methodParams{{Name: "requestID", Type: "integer", Description: "Deletion request id"}},
+		Request:        deletionReviewForm{},
+		Response:       db.DeletionRequest{},
+	})
+}
@@ -8,68 +8,98 @@ import (
 	"github.com/FederationOfFathers/dashboard/bot"
 	"github.com/FederationOfFathers/dashboard/db"
 	"github.com/gorilla/mux"
-	"github.com/jinzhu/gorm"
 	"go.uber.org/zap"
 )
 
 func init() {
+	Router.Path("/api/v1/meta/keys").Methods("GET").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(db.MetaKeys)
+		},
+	))
+	docRouteMethod("/api/v1/meta/keys", methodDocEntry{
+		Method:      "GET",
+		Description: "List the known meta keys with their JSON schemas, defaults and who may write them",
+		Response:    []db.MetaKey{},
+	})
+
 	Router.Path("/api/v1/meta/member/{memberID}/{key}").Methods("DELETE").Handler(authenticated(
 		func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Content-Type", "application/json")
-			id := getMemberID(r)
-			authMember, err := DB.MemberByAny(id)
-			if err != nil {
-				Logger.Error("invalid member", zap.String("id", id), zap.Error(err))
-				w.WriteHeader(http.StatusForbidden)
+			authMember := requestMember(w, r)
+			if authMember == nil {
 				return
 			}
 			admin, _ := bot.IsUserIDAdmin(authMember.Discord)
 			member, err := DB.MemberByAny(mux.Vars(r)["memberID"])
 			if err != nil {
-				http.NotFound(w, r)
+				writeDBError(w, err, "member lookup", zap.String("memberID", mux.Vars(r)["memberID"]))
+				return
+			}
+			if member.ID != authMember.ID && !admin {
+				writeForbidden(w, "not_allowed", "only admins may change other members")
 				return
 			}
-			if strings.ToLower(member.Slack) != strings.ToLower(id) && !admin {
-				http.NotFound(w, r)
+			key := mux.Vars(r)["key"]
+			// keys missing from the registry are left over from before it and may be cleaned up by their owner
+			if k, ok := db.MetaKeyByName(key); ok && !k.CanWrite(member.ID == authMember.ID, admin) {
+				writeForbidden(w, "meta_key_not_writable", "you may not change "+key)
 				return
 			}
-			DB.Delete(db.MemberMeta{}, "member_ID = ? AND meta_key = ?", member.ID, mux.Vars(r)["key"])
+			existing, err := DB.MemberMetaJSON(member.ID)
+			if err != nil {
+				Logger.Error("querying meta", zap.Int("member", member.ID), zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+			if err := DB.DeleteMemberMeta(member.ID, key); err != nil {
+				Logger.Error("deleting meta", zap.Int("member", member.ID), zap.String("key", key), zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+			if v, ok := existing[key]; ok {
+				recordAudit(r, authMember, member, "meta.delete", map[string]json.RawMessage{key: v}, nil)
+			}
 		},
 	))
+	docRouteMethod("/api/v1/meta/member/{memberID}/{key}", methodDocEntry{
+		Method:      "DELETE",
+		Description: "Delete a meta key for a member. Members may delete their own, admins anyone's, and system keys may not be deleted",
+	})
 
 	Router.Path("/api/v1/meta/member/{memberID}").Methods("GET").Handler(authenticated(
 		func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Content-Type", "application/json")
-			member, err := DB.MemberByAny(mux.Vars(r)["memberID"])
-			if err == gorm.ErrRecordNotFound || member == nil {
-				http.NotFound(w, r)
+			viewer := requestViewer(w, r)
+			if viewer == nil {
 				return
 			}
+			member, err := DB.MemberByAny(mux.Vars(r)["memberID"])
 			if err != nil {
-				Logger.Error("querying user", zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
+				writeDBError(w, err, "querying user")
 				return
 			}
-			var out = map[string]string{}
-			rows, err := DB.Raw("SELECT meta_key,meta_value FROM membermeta WHERE member_id = ?", member.ID).Rows()
-			defer rows.Close()
+			out, err := DB.MemberMetaJSON(member.ID)
 			if err != nil {
 				Logger.Error("querying", zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
+				writeInternalError(w)
 				return
 			}
-			for rows.Next() {
-				var k string
-				var v string
-				if err := rows.Scan(&k, &v); err != nil {
-					Logger.Error("scanning", zap.Error(err))
-					continue
-				}
-				out[k] = v
+			settings, err := DB.MemberPrivacySettings(member.ID)
+			if err != nil {
+				Logger.Error("querying privacy", zap.Int("member", member.ID), zap.Error(err))
+				writeInternalError(w)
+				return
 			}
-			json.NewEncoder(w).Encode(out)
+			json.NewEncoder(w).Encode(viewer.checker(member, settings).meta(out))
 		},
 	))
+	docRouteMethod("/api/v1/meta/member/{memberID}", methodDocEntry{
+		Method:      "GET",
+		Description: "Get all meta values for a member which the requester may see, as JSON values. Unset keys have their default",
+		Response:    map[string]json.RawMessage{},
+	})
 
 	Router.Path("/api/v1/meta/member/{memberID}").Methods("PUT", "POST").Handler(
 		authenticated(
@@ -78,50 +108,72 @@ func init() {
 				defer r.Body.Close()
 				member, err := DB.MemberByAny(mux.Vars(r)["memberID"])
 				if err != nil {
-					http.NotFound(w, r)
+					writeDBError(w, err, "member lookup", zap.String("memberID", mux.Vars(r)["memberID"]))
 					return
 				}
 				if !strings.Contains(strings.ToLower(r.Header.Get("Content-Type")), "json") {
-					http.NotFound(w, r)
+					writeError(w, http.StatusUnsupportedMediaType, "unsupported_content_type", "the request body must be JSON")
 					return
 				}
-				var form = map[string]string{}
-
-				err = json.NewDecoder(r.Body).Decode(&form)
-				if err != nil {
-					Logger.Error("Error decoding JSON", zap.String("uri", r.URL.RawPath), zap.Error(err))
+				var form = map[string]json.RawMessage{}
+				if !decodeJSON(w, r, &form) {
+					return
 				}
 
-				id := getMemberID(r)
-				m, err := DB.MemberByAny(id)
-				if err != nil {
-					w.WriteHeader(http.StatusForbidden)
+				m := requestMember(w, r)
+				if m == nil {
 					return
 				}
 				admin, _ := bot.IsUserIDAdmin(m.Discord)
 				if m.ID != member.ID && !admin {
-					http.NotFound(w, r)
+					writeForbidden(w, "not_allowed", "only admins may change other members")
+					return
+				}
+
+				for key, value := range form {
+					k, ok := db.MetaKeyByName(key)
+					if !ok {
+						writeBadRequest(w, "unknown_meta_key", key+" is not a known meta key")
+						return
+					}
+					if !k.CanWrite(m.ID == member.ID, admin) {
+						writeForbidden(w, "meta_key_not_writable", "you may not change "+key)
+						return
+					}
+					if err := k.Validate(value); err != nil {
+						writeDBError(w, err, "validating meta")
+						return
+					}
+				}
+
+				existing, err := DB.MemberMetaJSON(member.ID)
+				if err != nil {
+					Logger.Error("querying meta", zap.Int("member", member.ID), zap.Error(err))
+					writeInternalError(w)
 					return
 				}
+				var before = map[string]json.RawMessage{}
+				for k := range form {
+					if v, ok := existing[k]; ok {
+						before[k] = v
+					}
+				}
 
 				for k, v := range form {
-					err := DB.Exec(strings.Join([]string{
-						"INSERT INTO membermeta (`member_id`,`meta_key`,`meta_value`)",
-						"VALUES(?,?,?)",
-						"ON DUPLICATE KEY UPDATE",
-						"`meta_value` = ?",
-					}, " "),
-						member.ID,
-						k,
-						v,
-						v,
-					).Error
-					if err != nil {
-						w.WriteHeader(http.StatusInternalServerError)
+					if err := DB.SetMemberMeta(member.ID, k, v); err != nil {
+						writeDBError(w, err, "setting meta", zap.Int("member", member.ID), zap.String("key", k))
 						return
 					}
 				}
+				recordAudit(r, m, member, "meta.set", before, form)
 			},
 		),
 	)
+	for _, method := range []string{"PUT", "POST"} {
+		docRouteMethod("/api/v1/meta/member/{memberID}", methodDocEntry{
+			Method:      method,
+			Description: "Set meta values for a member. Each value must match its key's schema from /api/v1/meta/keys. Members may set their own self keys, admins any self or admin key",
+			Request:     map[string]json.RawMessage{},
+		})
+	}
 }
@@ -4,48 +4,275 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/FederationOfFathers/dashboard/bot"
 	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/FederationOfFathers/dashboard/roster"
 	"go.uber.org/zap"
 )
 
 type memberRestricted struct {
-	ID      int    `json:"id"`
-	Name    string `json:"name"`
-	Slack   string `json:"slack"`
-	Discord string `json:"discord"`
-	Xbox    string `json:"xbox"`
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Slack     string `json:"slack"`
+	Discord   string `json:"discord"`
+	Xbox      string `json:"xbox"`
+	Steam     string `json:"steam"`
+	Battlenet string `json:"battlenet"`
+	Epic      string `json:"epic"`
+	Switch    string `json:"switch"`
+	EA        string `json:"ea"`
+	Riot      string `json:"riot"`
+}
+
+const memberSearchMaxPerPage = 100
+
+type memberSearchResult struct {
+	memberRestricted
+	Seen int `json:"seen"`
+}
+
+type memberSearchResponse struct {
+	Members []memberSearchResult `json:"members"`
+	Total   int                  `json:"total"`
+	Page    int                  `json:"page"`
+	PerPage int                  `json:"perPage"`
 }
 
 func init() {
 	Router.Path("/api/v1/members").Methods("GET").Handler(
 		authenticated(
 			func(w http.ResponseWriter, r *http.Request) {
+				viewer := requestViewer(w, r)
+				if viewer == nil {
+					return
+				}
 				members, err := DB.Members()
 				if err != nil {
-					w.WriteHeader(http.StatusInternalServerError)
 					Logger.Error("Unable to retrieve members", zap.Error(err))
+					writeInternalError(w)
+					return
 				}
 
-				membersRestricted := membersToMembersRestricted(members)
+				membersRestricted, err := membersToMembersRestricted(viewer, members)
+				if err != nil {
+					Logger.Error("Unable to apply member privacy", zap.Error(err))
+					writeInternalError(w)
+					return
+				}
 
 				json.NewEncoder(w).Encode(membersRestricted)
 
 			},
 		))
+	docRouteMethod("/api/v1/members", methodDocEntry{
+		Method:      "GET",
+		Description: "List all members, keyed by member id. Platform ids the member keeps private are left empty",
+		Response:    map[string]memberRestricted{},
+	})
+
+	Router.Path("/api/v1/members/lookup").Methods("GET").Handler(
+		authenticated(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				viewer := requestViewer(w, r)
+				if viewer == nil {
+					return
+				}
+				platform := r.URL.Query().Get("platform")
+				id := r.URL.Query().Get("id")
+				if platform == "" || id == "" {
+					writeBadRequest(w, "missing_parameter", "platform and id are required")
+					return
+				}
+				member, err := DB.MemberByPlatformID(platform, id)
+				if err != nil {
+					writeDBError(w, err, "looking up platform id", zap.String("platform", platform), zap.String("id", id))
+					return
+				}
+				settings, err := DB.MemberPrivacySettings(member.ID)
+				if err != nil {
+					Logger.Error("querying privacy", zap.Int("member", member.ID), zap.Error(err))
+					writeInternalError(w)
+					return
+				}
+				checker := viewer.checker(member, settings)
+				if p, _ := db.PlatformByKey(platform); !checker.canSee(p.Key) {
+					writeError(w, http.StatusNotFound, "member_not_found", "no member with that id")
+					return
+				}
+				json.NewEncoder(w).Encode(memberToMemberRestricted(checker.member()))
+			},
+		))
+	var platformKeys []string
+	for _, p := range db.Platforms {
+		platformKeys = append(platformKeys, p.Key)
+	}
+	docRouteMethod("/api/v1/members/lookup", methodDocEntry{
+		Method:      "GET",
+		Description: "Find the member with a gamertag or other platform id",
+		RequiredParams: []methodParams{
+			{Name: "platform", Description: "Which platform the id is for", Values: platformKeys},
+			{Name: "id", Description: "The id on that platform"},
+		},
+		Response: memberRestricted{},
+	})
+
+	Router.Path("/api/v1/members/search").Methods("GET").Handler(authenticated(searchMembers))
+	docRouteMethod("/api/v1/members/search", methodDocEntry{
+		Method:      "GET",
+		Description: "Search members by name or platform id, with filters, sorting and pagination",
+		OptionalParams: []methodParams{
+			{Name: "q", Description: "Matches anywhere in the name or any platform id you may see"},
+			{Name: "platform", Description: "Only members with an id you may see on this platform. May be repeated", Values: platformKeys},
+			{Name: "role", Description: "Only members with this Discord role, by id or name"},
+			{Name: "seen", Type: "integer", Description: "Only members seen in this many days"},
+			{Name: "verified", Type: "boolean", Description: "Only verified, or only unverified, members"},
+			{Name: "sort", Description: "Sort order, prefix with - for descending. Defaults to name", Values: []string{"name", "-name", "seen", "-seen", "id", "-id"}},
+			{Name: "page", Type: "integer", Description: "Page number, starting at 1"},
+			{Name: "perPage", Type: "integer", Description: "Members per page, at most 100. Defaults to 25"},
+		},
+		Response: memberSearchResponse{},
+	})
 }
 
-func membersToMembersRestricted(members []*db.Member) map[string]memberRestricted {
+// searchMembers writes a page of members matching the search in the query string
+func searchMembers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	viewer := requestViewer(w, r)
+	if viewer == nil {
+		return
+	}
+	query := r.URL.Query()
+	var search = db.MemberSearch{
+		Query:   query.Get("q"),
+		Sort:    query.Get("sort"),
+		Page:    1,
+		PerPage: 25,
+		Viewer: db.MemberSearchViewer{
+			ID:       viewer.member.ID,
+			Admin:    viewer.admin,
+			FriendOf: roster.Followers(viewer.member.ID),
+		},
+	}
+	if !db.ValidMemberSort(search.Sort) {
+		writeBadRequest(w, "invalid_sort", "sort must be name, seen or id, optionally prefixed with -")
+		return
+	}
+	for name, dst := range map[string]*int{"page": &search.Page, "perPage": &search.PerPage} {
+		if v := query.Get(name); v != "" {
+			i, err := strconv.Atoi(v)
+			if err != nil || i < 1 {
+				writeBadRequest(w, "invalid_"+name, name+" must be a positive number")
+				return
+			}
+			*dst = i
+		}
+	}
+	if search.PerPage > memberSearchMaxPerPage {
+		search.PerPage = memberSearchMaxPerPage
+	}
+	for _, platform := range query["platform"] {
+		if _, ok := db.PlatformByKey(platform); !ok {
+			writeBadRequest(w, "unknown_platform", "unknown platform "+platform)
+			return
+		}
+		search.Platforms = append(search.Platforms, platform)
+	}
+	if v := query.Get("seen"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 1 {
+			writeBadRequest(w, "invalid_seen", "seen must be a positive number of days")
+			return
+		}
+		search.SeenSince = time.Now().AddDate(0, 0, -days)
+	}
+	if role := query.Get("role"); role != "" {
+		search.DiscordIn = bot.DiscordIDsWithRole(role)
+	}
+	switch query.Get("verified") {
+	case "":
+	case "true", "1":
+		verified := bot.VerifiedDiscordIDs()
+		if search.DiscordIn == nil {
+			search.DiscordIn = verified
+		} else {
+			search.DiscordIn = intersectStrings(search.DiscordIn, verified)
+		}
+	case "false", "0":
+		search.DiscordNotIn = bot.VerifiedDiscordIDs()
+	default:
+		writeBadRequest(w, "invalid_verified", "verified must be true or false")
+		return
+	}
+
+	members, total, err := DB.SearchMembers(search)
+	if err != nil {
+		writeDBError(w, err, "searching members", zap.Any("search", search))
+		return
+	}
+	members, err = viewer.visibleMembers(members)
+	if err != nil {
+		Logger.Error("Unable to apply member privacy", zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+	var rval = memberSearchResponse{
+		Members: []memberSearchResult{},
+		Total:   total,
+		Page:    search.Page,
+		PerPage: search.PerPage,
+	}
+	for _, member := range members {
+		rval.Members = append(rval.Members, memberSearchResult{
+			memberRestricted: memberToMemberRestricted(member),
+			Seen:             member.Seen,
+		})
+	}
+	json.NewEncoder(w).Encode(rval)
+}
+
+func membersToMembersRestricted(viewer *profileViewer, members []*db.Member) (map[string]memberRestricted, error) {
 	membersRestricted := map[string]memberRestricted{}
+	members, err := viewer.visibleMembers(members)
+	if err != nil {
+		return membersRestricted, err
+	}
 	for _, member := range members {
-		membersRestricted[string(strconv.Itoa(member.ID))] = memberRestricted{
-			ID:      member.ID,
-			Name:    member.Name,
-			Slack:   member.Slack,
-			Discord: member.Discord,
-			Xbox:    member.Xbl,
-		}
+		membersRestricted[string(strconv.Itoa(member.ID))] = memberToMemberRestricted(member)
 	}
 
-	return membersRestricted
+	return membersRestricted, nil
+}
+
+func memberToMemberRestricted(member *db.Member) memberRestricted {
+	return memberRestricted{
+		ID:        member.ID,
+		Name:      member.Name,
+		Slack:     member.Slack,
+		Discord:   member.Discord,
+		Xbox:      member.Xbl,
+		Steam:     member.Steam,
+		Battlenet: member.Battlenet,
+		Epic:      member.Epic,
+		Switch:    member.Switch,
+		EA:        member.EA,
+		Riot:      member.Riot,
+	}
+}
+
+// intersectStrings returns the strings in both a and b
+func intersectStrings(a []string, b []string) []string {
+	var inB = map[string]bool{}
+	for _, s := range b {
+		inB[s] = true
+	}
+	var rval = []string{}
+	for _, s := range a {
+		if inB[s] {
+			rval = append(rval, s)
+		}
+	}
+	return rval
 }
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/FederationOfFathers/dashboard/messaging"
+	"go.uber.org/zap"
+)
+
+type mergeRequest struct {
+	From   int  `json:"from"`
+	Into   int  `json:"into"`
+	DryRun bool `json:"dryRun"`
+}
+
+func init() {
+	Router.Path("/api/v1/admin/members/merge").Methods("POST").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			defer r.Body.Close()
+			admin := requireAdmin(w, r)
+			if admin == nil {
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			var req mergeRequest
+			if !decodeJSON(w, r, &req) {
+				return
+			}
+			if req.From == 0 || req.Into == 0 {
+				writeBadRequest(w, "missing_parameter", "from and into are required")
+				return
+			}
+
+			if req.DryRun {
+				plan, err := DB.PlanMemberMerge(req.From, req.Into)
+				if err != nil {
+					writeDBError(w, err, "planning member merge", zap.Int("from", req.From), zap.Int("into", req.Into))
+					return
+				}
+				json.NewEncoder(w).Encode(plan)
+				return
+			}
+
+			entry := auditEntry(r, admin)
+			plan, err := DB.MergeMembers(req.From, req.Into, entry)
+			if err != nil {
+				writeDBError(w, err, "merging members", zap.Int("from", req.From), zap.Int("into", req.Into))
+				return
+			}
+			Logger.Info("merged members", zap.Int("from", req.From), zap.Int("into", req.Into), zap.Int("admin", admin.ID))
+			go messaging.SendAuditMessage(entry, admin, plan.Into)
+			json.NewEncoder(w).Encode(plan)
+		},
+	))
+	docRouteMethod("/api/v1/admin/members/merge", methodDocEntry{
+		Method:      "POST",
+		Description: "Merge one member into another, moving their streams, meta, games, events and logins and deleting the merged member. Set dryRun to preview the changes",
+		Auth:        authAdmin,
+		Request:     mergeRequest{},
+		Response:    db.MergePlan{},
+	})
+}
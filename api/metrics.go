@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/FederationOfFathers/dashboard/metrics"
+	"github.com/gorilla/mux"
+)
+
+// instrumented counts and times requests by route template, so that /api/v1/member/1 and
+// /api/v1/member/2 are counted together. It is registered before rateLimited (files are
+// initialized in name order) so that rate limited requests are counted too
+func instrumented(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if tpl, err := current.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+		start := time.Now()
+		var nw = &responseWriter{status: 200, ResponseWriter: w}
+		next.ServeHTTP(nw, r)
+		metrics.HTTPRequests.WithLabelValues(route, r.Method, strconv.Itoa(nw.status)).Inc()
+		metrics.HTTPDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+func init() {
+	Router.Use(instrumented)
+	Router.Path("/metrics").Methods("GET").Handler(metrics.Handler())
+	docRouteMethod("/metrics", methodDocEntry{
+		Method:      "GET",
+		Description: "Metrics in the Prometheus text format",
+		Auth:        authNone,
+	})
+}
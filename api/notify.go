@@ -15,4 +15,8 @@ func init() {
 			bridge.DiscordCoreDataUpdated.L.Unlock()
 		},
 	))
+	docRouteMethod("/api/v0/notify/slack-core-data", methodDocEntry{
+		Method:      "GET",
+		Description: "Long poll which returns when the cached Discord members, roles and channels are next refreshed",
+	})
 }
@@ -2,15 +2,15 @@ package api
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"strings"
 
 	"github.com/FederationOfFathers/dashboard/config"
+	"github.com/FederationOfFathers/dashboard/db"
 	"github.com/bwmarrin/discordgo"
-	"github.com/jinzhu/gorm"
 	"go.uber.org/zap"
 	"golang.org/x/oauth2"
 )
@@ -49,6 +49,29 @@ func initDiscordOauth() {
 	} else {
 		Router.Path("/api/v1/oauth/discord").Methods("GET").HandlerFunc(NotImplemented)
 	}
+	docRouteMethod("/api/v1/oauth/discord", methodDocEntry{
+		Method:      "GET",
+		Description: "Get the Discord OAuth2 authorization URL",
+		Auth:        authNone,
+		Response:    "",
+	})
+	docRouteMethod("/api/v1/oauth/discord/verify", methodDocEntry{
+		Method:      "GET",
+		Description: "Discord OAuth2 callback which links the Discord account to the logged in member",
+		RequiredParams: []methodParams{
+			{Name: "code", Description: "OAuth2 authorization code"},
+			{Name: "state", Description: "OAuth2 state"},
+		},
+	})
+	docRouteMethod("/api/v1/oauth/discord/login", methodDocEntry{
+		Method:      "GET",
+		Description: "Discord OAuth2 callback which logs in the member linked to the Discord account",
+		Auth:        authNone,
+		RequiredParams: []methodParams{
+			{Name: "code", Description: "OAuth2 authorization code"},
+			{Name: "state", Description: "OAuth2 state"},
+		},
+	})
 
 }
 
@@ -81,7 +104,7 @@ func discordOauthVerify(w http.ResponseWriter, r *http.Request) {
 
 	if code == "" || state == "" {
 		Logger.Error("bad request", zap.String("code", code), zap.String("state", state))
-		w.WriteHeader(http.StatusBadRequest)
+		writeBadRequest(w, "missing_code", "code and state are required")
 	} else {
 
 		// exchange code for a user token
@@ -95,7 +118,7 @@ func discordOauthVerify(w http.ResponseWriter, r *http.Request) {
 				zap.Strings("scopes", conf.Scopes),
 				zap.String("redirecturi", conf.RedirectURL),
 				zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			writeInternalError(w)
 			return
 		}
 
@@ -104,7 +127,7 @@ func discordOauthVerify(w http.ResponseWriter, r *http.Request) {
 		res, err := client.Get("https://discordapp.com/api/users/@me")
 		if err != nil {
 			Logger.Error("Could not get user object", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			writeInternalError(w)
 			return
 		}
 
@@ -112,28 +135,32 @@ func discordOauthVerify(w http.ResponseWriter, r *http.Request) {
 		body, err := ioutil.ReadAll(res.Body)
 		if err != nil {
 			Logger.Error("Could not parse body", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			writeInternalError(w)
 			return
 		}
 		userObj := discordgo.User{}
 		err = json.Unmarshal(body, &userObj)
 		if err != nil {
 			Logger.Error("Could not parse JSON", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			writeInternalError(w)
 			return
 		}
 
 		// unauthenticated user
 		if !isAuthenticated {
 			member, err := DB.MemberByDiscordID(userObj.ID)
-			if err == gorm.ErrRecordNotFound || err == sql.ErrNoRows {
-				w.WriteHeader(http.StatusForbidden)
-				w.Write([]byte("not authorizes"))
+			if errors.Is(err, db.ErrNotFound) {
+				writeForbidden(w, "not_a_member", "that Discord account is not a known member")
 				return
 			} else if err != nil {
 				Logger.Error("unable to check member", zap.String("discordid", userObj.ID), zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
-				w.Write([]byte("error"))
+				writeInternalError(w)
+				return
+			}
+
+			if member.DepartedAt != nil {
+				writeForbidden(w, "not_a_member", "that Discord account has left the guild")
+				return
 			}
 
 			// set auth cookie and redirect
@@ -143,8 +170,7 @@ func discordOauthVerify(w http.ResponseWriter, r *http.Request) {
 			member, err := DB.MemberByAny(id)
 			if err != nil {
 				Logger.Error("could not find member", zap.String("member_id", id), zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
-				w.Write([]byte("error"))
+				writeInternalError(w)
 				return
 			}
 
@@ -153,8 +179,7 @@ func discordOauthVerify(w http.ResponseWriter, r *http.Request) {
 
 			if err := member.Save(); err != nil {
 				Logger.Error("unable to save discord id", zap.Int("member", member.ID), zap.String("discord id", userObj.ID), zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
-				w.Write([]byte("error"))
+				writeInternalError(w)
 				return
 			}
 		}
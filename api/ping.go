@@ -6,8 +6,17 @@ import (
 	"net/http"
 
 	"github.com/FederationOfFathers/dashboard/bot"
+	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/bwmarrin/discordgo"
 )
 
+type pingResponse struct {
+	User     *db.Member        `json:"user"`
+	Member   *discordgo.Member `json:"member"`
+	Admin    bool              `json:"admin"`
+	Verified bool              `json:"verified"`
+}
+
 func init() {
 
 	Router.Path("/api/v1/ping").Methods("GET").Handler(authenticated(
@@ -16,17 +25,25 @@ func init() {
 			id := getMemberID(r)
 			Logger.Debug(fmt.Sprintf("id: %s", id))
 			w.Header().Set("X-UID", id)
-			member, _ := DB.MemberByAny(id)
+			member := requestMember(w, r)
+			if member == nil {
+				return
+			}
 			admin, _ := bot.IsUserIDAdmin(member.Discord)
 			verified, _ := bot.IsUserIDVerified(member.Discord)
 			dMember, _ := bot.Member(member.Discord)
-			var rval = map[string]interface{}{
-				"user":   member,
-				"member": dMember,
-				"admin":  admin,
-				"verified": verified,
+			var rval = pingResponse{
+				User:     member,
+				Member:   dMember,
+				Admin:    admin,
+				Verified: verified,
 			}
 			json.NewEncoder(w).Encode(rval)
 		},
 	))
+	docRouteMethod("/api/v1/ping", methodDocEntry{
+		Method:      "GET",
+		Description: "Make sure the user is logged in and return information about their current state",
+		Response:    pingResponse{},
+	})
 }
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type platformForm struct {
+	Name   string `json:"name"`
+	Family string `json:"family"`
+	Icon   string `json:"icon"`
+}
+
+func init() {
+	Router.Path("/api/v1/platforms").Methods("GET").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			platforms, err := DB.GamingPlatforms()
+			if err != nil {
+				Logger.Error("listing platforms", zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(platforms)
+		},
+	))
+	docRouteMethod("/api/v1/platforms", methodDocEntry{
+		Method:      "GET",
+		Description: "List the platforms games are played on. Game responses refer to them by id in their platform fields",
+		Response:    []db.GamingPlatform{},
+	})
+
+	Router.Path("/api/v1/admin/platforms/{platformID}").Methods("PUT").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			defer r.Body.Close()
+			admin := requireAdmin(w, r)
+			if admin == nil {
+				return
+			}
+			platformID, err := strconv.Atoi(mux.Vars(r)["platformID"])
+			if err != nil {
+				writeBadRequest(w, "invalid_platform_id", "platformID must be a number")
+				return
+			}
+			var form platformForm
+			if !decodeJSON(w, r, &form) {
+				return
+			}
+			var platform = &db.GamingPlatform{ID: platformID, Name: form.Name, Family: form.Family, Icon: form.Icon}
+			if err := DB.SaveGamingPlatform(platform); err != nil {
+				writeDBError(w, err, "saving platform", zap.Int("platform", platformID))
+				return
+			}
+			Logger.Info("saved platform", zap.Int("platform", platform.ID), zap.String("name", platform.Name), zap.Int("admin", admin.ID))
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(platform)
+		},
+	))
+	docRouteMethod("/api/v1/admin/platforms/{platformID}", methodDocEntry{
+		Method:         "PUT",
+		Description:    "Add a platform, or replace the name, family and icon of an existing one",
+		Auth:           authAdmin,
+		RequiredParams: []methodParams{{Name: "platformID", Type: "integer", Description: "Platform id"}},
+		OptionalParams: []methodParams{{Name: "family", Description: "Kind of platform, in the request body", Values: db.PlatformFamilies}},
+		Request:        platformForm{},
+		Response:       db.GamingPlatform{},
+	})
+}
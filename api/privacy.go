@@ -0,0 +1,196 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/FederationOfFathers/dashboard/bot"
+	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/FederationOfFathers/dashboard/roster"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// profileViewer is the member a profile is being shown to
+type profileViewer struct {
+	member *db.Member
+	admin  bool
+}
+
+// requestViewer returns the logged in member as a profileViewer. Otherwise an error response is written and nil returned
+func requestViewer(w http.ResponseWriter, r *http.Request) *profileViewer {
+	member := requestMember(w, r)
+	if member == nil {
+		return nil
+	}
+	admin, _ := bot.IsUserIDAdmin(member.Discord)
+	return &profileViewer{member: member, admin: admin}
+}
+
+// privacyChecker decides which of one member's fields a viewer may see
+type privacyChecker struct {
+	viewer   *profileViewer
+	owner    *db.Member
+	settings map[string]db.Visibility
+	friend   *bool
+}
+
+func (v *profileViewer) checker(owner *db.Member, settings map[string]db.Visibility) *privacyChecker {
+	return &privacyChecker{viewer: v, owner: owner, settings: settings}
+}
+
+// canSee reports whether the viewer may see the field. The owner's roster is only read when it matters
+func (c *privacyChecker) canSee(field string) bool {
+	visibility := c.settings[field]
+	self := c.viewer.member.ID == c.owner.ID
+	if visibility != db.VisibilityFriends || self || c.viewer.admin {
+		return visibility.VisibleTo(self, false, c.viewer.admin)
+	}
+	if c.friend == nil {
+		friend := roster.IsFriend(c.owner.ID, c.viewer.member.ID)
+		c.friend = &friend
+	}
+	return visibility.VisibleTo(self, *c.friend, c.viewer.admin)
+}
+
+// member returns a copy of the owner with the platform ids the viewer may not see cleared
+func (c *privacyChecker) member() *db.Member {
+	visible := *c.owner
+	for _, p := range db.Platforms {
+		if !c.canSee(p.Key) {
+			visible.SetPlatformID(p.Key, "")
+		}
+	}
+	return &visible
+}
+
+// meta removes the values the viewer may not see from a member's meta values. Keys only admins may read are
+// removed for everyone else, whatever the owner's settings
+func (c *privacyChecker) meta(values map[string]json.RawMessage) map[string]json.RawMessage {
+	var rval = map[string]json.RawMessage{}
+	for k, v := range db.ReadableMeta(values, c.viewer.admin) {
+		if c.canSee(db.MetaPrivacyField(k)) {
+			rval[k] = v
+		}
+	}
+	return rval
+}
+
+// visibleMembers returns copies of members with the platform ids the viewer may not see cleared
+func (v *profileViewer) visibleMembers(members []*db.Member) ([]*db.Member, error) {
+	var ids []int
+	for _, member := range members {
+		ids = append(ids, member.ID)
+	}
+	settings, err := DB.MembersPrivacySettings(ids)
+	if err != nil {
+		return nil, err
+	}
+	var rval = make([]*db.Member, 0, len(members))
+	for _, member := range members {
+		rval = append(rval, v.checker(member, settings[member.ID]).member())
+	}
+	return rval, nil
+}
+
+// visibleMember returns a copy of member with the platform ids the viewer may not see cleared
+func (v *profileViewer) visibleMember(member *db.Member) (*db.Member, error) {
+	members, err := v.visibleMembers([]*db.Member{member})
+	if err != nil {
+		return nil, err
+	}
+	return members[0], nil
+}
+
+func init() {
+	Router.Path("/api/v1/privacy/member/{memberID}").Methods("GET").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			viewer := requestViewer(w, r)
+			if viewer == nil {
+				return
+			}
+			member, err := DB.MemberByAny(mux.Vars(r)["memberID"])
+			if err != nil {
+				writeDBError(w, err, "member lookup", zap.String("memberID", mux.Vars(r)["memberID"]))
+				return
+			}
+			if member.ID != viewer.member.ID && !viewer.admin {
+				writeForbidden(w, "not_allowed", "only admins may see other members' privacy settings")
+				return
+			}
+			settings, err := DB.MemberPrivacySettings(member.ID)
+			if err != nil {
+				Logger.Error("querying privacy", zap.Int("member", member.ID), zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+			json.NewEncoder(w).Encode(settings)
+		},
+	))
+	docRouteMethod("/api/v1/privacy/member/{memberID}", methodDocEntry{
+		Method:      "GET",
+		Description: "Get who may see a member's platform ids and meta values, keyed by platform key or meta:<key>. Fields not listed are visible to the guild",
+		Response:    map[string]db.Visibility{},
+	})
+
+	Router.Path("/api/v1/privacy/member/{memberID}").Methods("PUT", "POST").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			defer r.Body.Close()
+			viewer := requestViewer(w, r)
+			if viewer == nil {
+				return
+			}
+			member, err := DB.MemberByAny(mux.Vars(r)["memberID"])
+			if err != nil {
+				writeDBError(w, err, "member lookup", zap.String("memberID", mux.Vars(r)["memberID"]))
+				return
+			}
+			if member.ID != viewer.member.ID && !viewer.admin {
+				writeForbidden(w, "not_allowed", "only admins may change other members")
+				return
+			}
+			if !strings.Contains(strings.ToLower(r.Header.Get("Content-Type")), "json") {
+				writeError(w, http.StatusUnsupportedMediaType, "unsupported_content_type", "the request body must be JSON")
+				return
+			}
+			var form = map[string]db.Visibility{}
+			if !decodeJSON(w, r, &form) {
+				return
+			}
+			for field, visibility := range form {
+				if !db.ValidPrivacyField(field) {
+					writeBadRequest(w, "unknown_field", field+" is not a platform key or meta:<key>")
+					return
+				}
+				if !db.ValidVisibility(visibility) {
+					writeBadRequest(w, "invalid_visibility", "visibility must be guild, friends or admins")
+					return
+				}
+			}
+
+			before, err := DB.MemberPrivacySettings(member.ID)
+			if err != nil {
+				Logger.Error("querying privacy", zap.Int("member", member.ID), zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+			for field, visibility := range form {
+				if err := DB.SetMemberPrivacy(member.ID, field, visibility); err != nil {
+					writeDBError(w, err, "setting privacy", zap.Int("member", member.ID), zap.String("field", field))
+					return
+				}
+			}
+			recordAudit(r, viewer.member, member, "privacy.set", before, form)
+		},
+	))
+	for _, method := range []string{"PUT", "POST"} {
+		docRouteMethod("/api/v1/privacy/member/{memberID}", methodDocEntry{
+			Method:      method,
+			Description: "Set who may see a member's platform ids and meta values: guild, friends or admins. Members may set their own, admins anyone's",
+			Request:     map[string]db.Visibility{},
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// RateLimit allows Requests requests per client in every Window. A limit with no requests is disabled
+type RateLimit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// RateLimitRead applies to requests which only read data
+var RateLimitRead = RateLimit{Requests: 300, Window: time.Minute}
+
+// RateLimitWrite applies to requests which change data
+var RateLimitWrite = RateLimit{Requests: 60, Window: time.Minute}
+
+// RateLimitAnnounce applies to requests which end up posting a message into Discord
+var RateLimitAnnounce = RateLimit{Requests: 10, Window: 10 * time.Minute}
+
+type rateClass string
+
+const (
+	rateClassRead     rateClass = "read"
+	rateClassWrite    rateClass = "write"
+	rateClassAnnounce rateClass = "announce"
+)
+
+// announceRoutes are the route templates which trigger announcements into Discord
+var announceRoutes = map[string]bool{
+	"/api/v1/events/create":         true,
+	"/api/v1/events/{eventID}/join": true,
+	"/api/v1/lfg/create":            true,
+	"/api/v1/lfg/{lfgID}/join":      true,
+}
+
+func (c rateClass) limit() RateLimit {
+	switch c {
+	case rateClassAnnounce:
+		return RateLimitAnnounce
+	case rateClassWrite:
+		return RateLimitWrite
+	}
+	return RateLimitRead
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+type rateLimiter struct {
+	sync.Mutex
+	windows   map[string]*rateWindow
+	lastSweep time.Time
+}
+
+var limiter = &rateLimiter{windows: map[string]*rateWindow{}}
+
+// take counts a request for key against limit. It returns how many requests remain in the
+// current window, how long until the window resets, and whether the request is allowed
+func (l *rateLimiter) take(key string, limit RateLimit, now time.Time) (int, time.Duration, bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	if now.Sub(l.lastSweep) > 10*time.Minute {
+		l.sweep(now)
+	}
+
+	win, ok := l.windows[key]
+	if !ok || now.Sub(win.start) >= limit.Window {
+		win = &rateWindow{start: now}
+		l.windows[key] = win
+	}
+	reset := win.start.Add(limit.Window).Sub(now)
+	if win.count >= limit.Requests {
+		return 0, reset, false
+	}
+	win.count++
+	return limit.Requests - win.count, reset, true
+}
+
+// sweep drops windows which have not been touched for long enough that they can no longer apply
+func (l *rateLimiter) sweep(now time.Time) {
+	longest := RateLimitRead.Window
+	for _, limit := range []RateLimit{RateLimitWrite, RateLimitAnnounce} {
+		if limit.Window > longest {
+			longest = limit.Window
+		}
+	}
+	for key, win := range l.windows {
+		if now.Sub(win.start) > longest {
+			delete(l.windows, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+func requestRateClass(r *http.Request) rateClass {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil && announceRoutes[tpl] {
+			return rateClassAnnounce
+		}
+	}
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return rateClassRead
+	}
+	return rateClassWrite
+}
+
+// rateLimitKey identifies the client making the request. Authenticated requests are keyed by member,
+// everything else by remote address (which handlers.ProxyHeaders has already resolved for us)
+func rateLimitKey(w http.ResponseWriter, r *http.Request) string {
+	if ar, err := authorized(w, r); err == nil {
+		if id := requestAuth(ar)["memberid"]; id != "" && id != "0" {
+			return "member:" + id
+		}
+		if id := requestAuth(ar)["userid"]; id != "" {
+			return "slack:" + id
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return "ip:" + host
+	}
+	return "ip:" + r.RemoteAddr
+}
+
+func rateLimited(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class := requestRateClass(r)
+		limit := class.limit()
+		if limit.Requests <= 0 || limit.Window <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := rateLimitKey(w, r)
+		remaining, reset, ok := limiter.take(string(class)+":"+key, limit, time.Now())
+		resetSeconds := strconv.Itoa(int(math.Ceil(reset.Seconds())))
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("RateLimit-Reset", resetSeconds)
+		if !ok {
+			Logger.Debug("rate limited", zap.String("key", key), zap.String("class", string(class)), zap.String("uri", r.RequestURI))
+			w.Header().Set("Retry-After", resetSeconds)
+			writeError(w, http.StatusTooManyRequests, "rate_limited", "too many requests, try again in "+resetSeconds+" seconds")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func init() {
+	Router.Use(rateLimited)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+func TestRateLimiterTake(t *testing.T) {
+	l := &rateLimiter{windows: map[string]*rateWindow{}}
+	limit := RateLimit{Requests: 2, Window: time.Minute}
+	now := time.Now()
+
+	if remaining, _, ok := l.take("a", limit, now); !ok || remaining != 1 {
+		t.Errorf("first request expected allowed with 1 remaining, got %v %d", ok, remaining)
+	}
+	if remaining, _, ok := l.take("a", limit, now); !ok || remaining != 0 {
+		t.Errorf("second request expected allowed with 0 remaining, got %v %d", ok, remaining)
+	}
+	if _, reset, ok := l.take("a", limit, now.Add(10*time.Second)); ok || reset != 50*time.Second {
+		t.Errorf("third request expected denied with 50s reset, got %v %s", ok, reset)
+	}
+	if _, _, ok := l.take("b", limit, now); !ok {
+		t.Errorf("other keys should not share a window")
+	}
+	if _, _, ok := l.take("a", limit, now.Add(time.Minute)); !ok {
+		t.Errorf("request after the window expected allowed")
+	}
+}
+
+func TestRateLimitedHeaders(t *testing.T) {
+	Logger = zap.NewNop()
+	defer func(l RateLimit) { RateLimitAnnounce = l }(RateLimitAnnounce)
+	RateLimitAnnounce = RateLimit{Requests: 1, Window: time.Minute}
+	limiter = &rateLimiter{windows: map[string]*rateWindow{}}
+
+	r := mux.NewRouter()
+	r.Use(rateLimited)
+	r.Path("/api/v1/events/{eventID}/join").Methods("POST").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest("POST", "/api/v1/events/1/join", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "1" || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("unexpected first response %d %v", rec.Code, rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 429 with Retry-After, got %d %v", rec.Code, rec.Header())
+	}
+}
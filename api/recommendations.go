@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/FederationOfFathers/dashboard/roster"
+	"go.uber.org/zap"
+)
+
+const recommendationsMaxLimit = 50
+
+type recommendationResult struct {
+	memberRestricted
+	Score       int      `json:"score"`
+	SharedGames []string `json:"sharedGames"`
+	Reasons     []string `json:"reasons"`
+}
+
+func init() {
+	Router.Path("/api/v1/members/{memberID}/recommendations").Methods("GET").Handler(authenticated(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			viewer := requestViewer(w, r)
+			if viewer == nil {
+				return
+			}
+			member := friendTarget(w, r)
+			if member == nil {
+				return
+			}
+			if member.ID != viewer.member.ID && !viewer.admin {
+				writeForbidden(w, "not_allowed", "only admins may see other members' recommendations")
+				return
+			}
+			var days, limit = 30, 10
+			for name, dst := range map[string]*int{"days": &days, "limit": &limit} {
+				if v := r.URL.Query().Get(name); v != "" {
+					i, err := strconv.Atoi(v)
+					if err != nil || i < 1 {
+						writeBadRequest(w, "invalid_"+name, name+" must be a positive number")
+						return
+					}
+					*dst = i
+				}
+			}
+			if limit > recommendationsMaxLimit {
+				limit = recommendationsMaxLimit
+			}
+
+			recommendations, err := DB.Recommendations(member.ID, time.Now().AddDate(0, 0, -days), roster.Get(member.ID), limit)
+			if err != nil {
+				Logger.Error("listing recommendations", zap.Int("member", member.ID), zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+			var ids []int
+			for _, rec := range recommendations {
+				ids = append(ids, rec.MemberID)
+			}
+			members, err := viewer.friendMembers(ids)
+			if err != nil {
+				Logger.Error("listing recommended members", zap.Int("member", member.ID), zap.Error(err))
+				writeInternalError(w)
+				return
+			}
+			var byID = map[int]memberRestricted{}
+			for _, m := range members {
+				byID[m.ID] = memberToMemberRestricted(m)
+			}
+			var rval = make([]recommendationResult, 0, len(recommendations))
+			for _, rec := range recommendations {
+				restricted, ok := byID[rec.MemberID]
+				if !ok {
+					continue
+				}
+				rval = append(rval, recommendationResult{
+					memberRestricted: restricted,
+					Score:            rec.Score,
+					SharedGames:      rec.SharedGames,
+					Reasons:          rec.Reasons,
+				})
+			}
+			json.NewEncoder(w).Encode(rval)
+		},
+	))
+	docRouteMethod("/api/v1/members/{memberID}/recommendations", methodDocEntry{
+		Method: "GET",
+		Description: "Suggest members to play with who are not already friends, best first, with the reasons for each. " +
+			"Members who recently played the most of the same games rank highest, with ties going to those usually active at the same hours. " +
+			"Only admins may see other members' recommendations",
+		RequiredParams: []methodParams{{Name: "memberID", Type: "integer", Description: "Member id"}},
+		OptionalParams: []methodParams{
+			{Name: "days", Type: "integer", Description: "How many days of play and activity to consider, 30 if not given"},
+			{Name: "limit", Type: "integer", Description: "How many members to suggest, 10 if not given and at most 50"},
+		},
+		Response: []recommendationResult{},
+	})
+}
@@ -13,9 +13,20 @@ func init() {
 	Router.Path("/api/v1/redirect/team-tool").Methods("GET").Handler(authenticated(
 		func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Content-Type", "text/plain")
-			id := getMemberID(r)
-			user, _ := bot.Member(id)
+			member := requestMember(w, r)
+			if member == nil {
+				return
+			}
+			user, err := bot.Member(member.Discord)
+			if err != nil {
+				writeError(w, http.StatusNotFound, "discord_member_not_found", "you are not a member of the Discord server")
+				return
+			}
 			http.Redirect(w, r, bridge.OldEventToolLink(user.Nick), http.StatusTemporaryRedirect)
 		},
 	))
+	docRouteMethod("/api/v1/redirect/team-tool", methodDocEntry{
+		Method:      "GET",
+		Description: "Redirect to a login link for the old team tool",
+	})
 }
@@ -1,12 +1,33 @@
 package api
 
 import (
-	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// authRequirement describes who may call a documented route
+type authRequirement int
+
+const (
+	// authMember routes require a logged in member
+	authMember authRequirement = iota
+	// authNone routes may be called without logging in
+	authNone
+	// authAdmin routes require a logged in member with an admin role in Discord
+	authAdmin
 )
 
-var apiRoutes = []string{}
 var methodDocs = map[string][]methodDocEntry{}
 
 type methodParams struct {
@@ -15,126 +36,327 @@ type methodParams struct {
 	Type        string
 	Values      []string
 }
+
 type methodDocEntry struct {
 	Method         string
 	Description    string
+	Auth           authRequirement
 	RequiredParams []methodParams
 	OptionalParams []methodParams
 	AdminParams    []methodParams
+	// Request and Response are zero values of the types sent and returned as JSON bodies
+	Request  interface{}
+	Response interface{}
 }
 
+// docRouteMethod documents a method on a route. The route must match the template given to the
+// Router exactly. Path variables are picked up from the template, and may be described by
+// including them in RequiredParams. Remaining params are query (or form) parameters.
 func docRouteMethod(route string, method methodDocEntry) {
-	if _, ok := methodDocs[route]; !ok {
-		apiRoutes = append(apiRoutes, route)
-		methodDocs[route] = []methodDocEntry{}
-	}
 	methodDocs[route] = append(methodDocs[route], method)
 }
 
-func docBuild() []byte {
-	w := new(bytes.Buffer)
-	enc := json.NewEncoder(w)
-	w.Write([]byte("{\n"))
-	for i, route := range apiRoutes {
-		if i > 0 {
-			w.Write([]byte(",\n"))
-		}
-		enc.Encode(route)
-		w.Write([]byte(":\n"))
-		enc.Encode(methodDocs[route])
-	}
-	w.Write([]byte("}\n"))
-	dec := json.NewDecoder(w)
-	var doc interface{}
-	dec.Decode(&doc)
-	buf, _ := json.MarshalIndent(&doc, "  ", "  ")
-	return buf
+func routeMethodDoc(route, method string) (methodDocEntry, bool) {
+	for _, doc := range methodDocs[route] {
+		if doc.Method == method {
+			return doc, true
+		}
+	}
+	return methodDocEntry{}, false
 }
 
-func init() {
-	docRouteMethod("/api/v0/login", methodDocEntry{
-		Method:      "GET",
-		Description: "Logs a user into the API. Linked to from slack messages for users",
-	})
+type registeredRoute struct {
+	Template string
+	Method   string
+	Prefix   bool
+}
 
-	docRouteMethod("/api/v0/logout", methodDocEntry{
-		Method:      "GET",
-		Description: "Log a user out of the API.",
+// registeredRoutes lists every method on every route registered with the Router
+func registeredRoutes() []registeredRoute {
+	var rval []registeredRoute
+	var seen = map[string]bool{}
+	Router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		re, _ := route.GetPathRegexp()
+		for _, method := range methods {
+			if seen[method+" "+tpl] {
+				continue
+			}
+			seen[method+" "+tpl] = true
+			rval = append(rval, registeredRoute{
+				Template: tpl,
+				Method:   method,
+				Prefix:   !strings.HasSuffix(re, "$"),
+			})
+		}
+		return nil
 	})
+	return rval
+}
 
-	docRouteMethod("/api/v0/groups", methodDocEntry{
-		Method:      "GET",
-		Description: "List all visible groups. List all groups for Admins",
-	})
+// undocumentedRoutes lists registered routes without a matching docRouteMethod call
+func undocumentedRoutes() []string {
+	var rval []string
+	for _, route := range registeredRoutes() {
+		if _, ok := routeMethodDoc(route.Template, route.Method); !ok {
+			rval = append(rval, fmt.Sprintf("%s %s", route.Method, route.Template))
+		}
+	}
+	return rval
+}
 
-	docRouteMethod("/api/v0/groups/{groupID}/join", methodDocEntry{
-		Method:      "GET",
-		Description: "Join the group specified by groupID (see /api/v0/groups) if it is marked visible",
-	})
+var pathVariable = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
 
-	docRouteMethod("/api/v0/groups/{groupID}/visibility", methodDocEntry{
-		Method:      "PUT",
-		Description: "Set the visibility of the group",
-		AdminParams: []methodParams{
-			methodParams{
-				Name:        "visible",
-				Type:        "string",
-				Values:      []string{"true", "false"},
-				Description: "Whether or not the group should be visible",
-			},
-		},
-	})
+type schemaBuilder struct {
+	components map[string]interface{}
+}
 
-	docRouteMethod("/api/v0/ping", methodDocEntry{
-		Method:      "GET",
-		Description: "Make sure the user is logged in and return information about their current state",
-	})
+var timeType = reflect.TypeOf(time.Time{})
+var marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
 
-	docRouteMethod("/api/v0/streams", methodDocEntry{
-		Method:      "GET",
-		Description: "List all registered streams",
-	})
+func (b *schemaBuilder) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	if t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) {
+		return map[string]interface{}{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		name := path.Base(t.PkgPath()) + "." + t.Name()
+		if _, ok := b.components[name]; !ok {
+			b.components[name] = map[string]interface{}{}
+			b.components[name] = b.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
 
-	docRouteMethod("/api/v0/streams/{slackID}", methodDocEntry{
-		Method:      "GET",
-		Description: "Get details for a specific streamer. Probably not used",
-	})
+func (b *schemaBuilder) object(t reflect.Type) map[string]interface{} {
+	var properties = map[string]interface{}{}
+	b.fields(t, properties)
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
 
-	docRouteMethod("/api/v0/streams/{slackID}/{type}", methodDocEntry{
-		Method:      "DELETE",
-		Description: "Delete a stream if the user is the owner or an admin",
-	})
+func (b *schemaBuilder) fields(t reflect.Type, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+		ft := field.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if field.Anonymous && tag == "" && ft.Kind() == reflect.Struct {
+			b.fields(ft, properties)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		name := field.Name
+		if tag != "" {
+			name = tag
+		}
+		properties[name] = b.schema(field.Type)
+	}
+}
 
-	docRouteMethod("/api/v0/streams", methodDocEntry{
-		Method:      "POST",
-		Description: "Add a stream",
-		RequiredParams: []methodParams{
-			methodParams{
-				Name:        "kind",
-				Type:        "string",
-				Values:      []string{"twitch", "youtube"},
-				Description: "Which service the stream streams from",
-			},
-			methodParams{
-				Name:        "id",
-				Type:        "string",
-				Description: "The identifier on the streaming service for the stream",
+func docParameter(p methodParams, in string, required bool, admin bool) map[string]interface{} {
+	var schema = map[string]interface{}{"type": "string"}
+	if p.Type != "" {
+		schema["type"] = p.Type
+	}
+	if len(p.Values) > 0 {
+		schema["enum"] = p.Values
+	}
+	description := p.Description
+	if admin {
+		description = strings.TrimSpace("(admin only) " + description)
+	}
+	return map[string]interface{}{
+		"name":        p.Name,
+		"in":          in,
+		"description": description,
+		"required":    required,
+		"schema":      schema,
+	}
+}
+
+func docOperation(b *schemaBuilder, route registeredRoute, doc methodDocEntry) map[string]interface{} {
+	var parameters = []interface{}{}
+	var pathParams = map[string]bool{}
+	var tpl = route.Template
+	if route.Prefix {
+		tpl = tpl + "{path}"
+	}
+	for _, match := range pathVariable.FindAllStringSubmatch(tpl, -1) {
+		var p = methodParams{Name: match[1]}
+		for _, rp := range doc.RequiredParams {
+			if rp.Name == p.Name {
+				p = rp
+			}
+		}
+		pathParams[p.Name] = true
+		parameters = append(parameters, docParameter(p, "path", true, false))
+	}
+	for _, p := range doc.RequiredParams {
+		if !pathParams[p.Name] {
+			parameters = append(parameters, docParameter(p, "query", true, false))
+		}
+	}
+	for _, p := range doc.OptionalParams {
+		parameters = append(parameters, docParameter(p, "query", false, false))
+	}
+	for _, p := range doc.AdminParams {
+		parameters = append(parameters, docParameter(p, "query", false, true))
+	}
+
+	var ok = map[string]interface{}{"description": "OK"}
+	if doc.Response != nil {
+		ok["content"] = map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": b.schema(reflect.TypeOf(doc.Response))},
+		}
+	}
+	var errorBody = map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": b.schema(reflect.TypeOf(errorResponse{}))},
+	}
+	var errorDoc = func(description string) map[string]interface{} {
+		return map[string]interface{}{"description": description, "content": errorBody}
+	}
+	var responses = map[string]interface{}{
+		"200":     ok,
+		"429":     errorDoc("Rate limit exceeded. See the Retry-After header"),
+		"default": errorDoc("Error"),
+	}
+
+	var op = map[string]interface{}{
+		"summary":    doc.Description,
+		"parameters": parameters,
+		"responses":  responses,
+	}
+	if segments := strings.Split(strings.Trim(route.Template, "/"), "/"); len(segments) > 2 && segments[0] == "api" {
+		op["tags"] = []string{segments[2]}
+	}
+	if doc.Request != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": b.schema(reflect.TypeOf(doc.Request))},
 			},
+		}
+	}
+	switch doc.Auth {
+	case authNone:
+		op["security"] = []interface{}{}
+	case authAdmin:
+		op["x-admin-only"] = true
+		responses["403"] = errorDoc("Not logged in, or not an admin")
+	default:
+		responses["403"] = errorDoc("Not logged in")
+	}
+	return op
+}
+
+// openAPIDocument builds an OpenAPI 3 document for every documented route registered with the Router
+func openAPIDocument() map[string]interface{} {
+	var b = &schemaBuilder{components: map[string]interface{}{}}
+	var paths = map[string]map[string]interface{}{}
+	var routes = registeredRoutes()
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Template+routes[i].Method < routes[j].Template+routes[j].Method
+	})
+	for _, route := range routes {
+		doc, ok := routeMethodDoc(route.Template, route.Method)
+		if !ok {
+			continue
+		}
+		tpl := route.Template
+		if route.Prefix {
+			tpl = tpl + "{path}"
+		}
+		tpl = pathVariable.ReplaceAllString(tpl, "{$1}")
+		if _, ok := paths[tpl]; !ok {
+			paths[tpl] = map[string]interface{}{}
+		}
+		paths[tpl][strings.ToLower(route.Method)] = docOperation(b, route, doc)
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "FoF Dashboard API",
+			"version": "1",
 		},
-		AdminParams: []methodParams{
-			methodParams{
-				Name:        "userID",
-				Type:        "string",
-				Description: "The slack UserID to assign the stream to",
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": b.components,
+			"securitySchemes": map[string]interface{}{
+				"cookieAuth": map[string]interface{}{
+					"type": "apiKey",
+					"in":   "cookie",
+					"name": cookieName,
+				},
 			},
 		},
-	})
-
-	docs := docBuild()
-	Router.Path("/api/v0/documentation").Methods("GET").Handler(authenticated(
-		func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "text/plain")
-			w.Write(docs)
+		"security": []interface{}{
+			map[string]interface{}{"cookieAuth": []string{}},
 		},
-	))
+	}
+}
+
+var openAPIOnce sync.Once
+var openAPIJSON []byte
+
+func init() {
+	Router.Path("/api/v1/openapi.json").Methods("GET").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		openAPIOnce.Do(func() {
+			var err error
+			if openAPIJSON, err = json.MarshalIndent(openAPIDocument(), "", "  "); err != nil {
+				Logger.Error("unable to build OpenAPI document", zap.Error(err))
+			}
+			for _, route := range undocumentedRoutes() {
+				Logger.Warn("undocumented API route", zap.String("route", route))
+			}
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPIJSON)
+	})
+	docRouteMethod("/api/v1/openapi.json", methodDocEntry{
+		Method:      "GET",
+		Description: "OpenAPI 3 document describing this API",
+		Auth:        authNone,
+	})
 }
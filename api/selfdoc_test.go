@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRoutesDocumented(t *testing.T) {
+	for _, route := range undocumentedRoutes() {
+		t.Errorf("%s is registered but not documented. Add a docRouteMethod call next to it", route)
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	buf, err := json.Marshal(openAPIDocument())
+	if err != nil {
+		t.Fatalf("unable to marshal OpenAPI document: %s", err)
+	}
+	var doc struct {
+		OpenAPI string                                       `json:"openapi"`
+		Paths   map[string]map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal(buf, &doc); err != nil {
+		t.Fatalf("unable to unmarshal OpenAPI document: %s", err)
+	}
+	if doc.OpenAPI == "" {
+		t.Errorf("missing openapi version")
+	}
+	join, ok := doc.Paths["/api/v1/events/{eventID}/join"]["post"]
+	if !ok {
+		t.Fatalf("expected POST /api/v1/events/{eventID}/join to be described")
+	}
+	params, _ := join["parameters"].([]interface{})
+	if len(params) != 1 || params[0].(map[string]interface{})["in"] != "path" {
+		t.Errorf("expected eventID path parameter, got %v", params)
+	}
+	if _, ok := join["requestBody"]; !ok {
+		t.Errorf("expected a request body")
+	}
+}
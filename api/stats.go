@@ -1,33 +1,122 @@
 package api
 
 import (
-	"fmt"
-	"io"
+	"encoding/json"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
 )
 
-func statsPassthrough(uri string, w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	rsp, err := http.Get(fmt.Sprintf("http://127.0.0.1:8874%s", uri))
-	if rsp != nil {
-		defer rsp.Body.Close()
+// queryPositiveInt reads a positive number from the query string, or def when it is not given. Otherwise an
+// error response is written and false returned
+func queryPositiveInt(w http.ResponseWriter, r *http.Request, name string, def int) (int, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, true
 	}
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 1 {
+		writeBadRequest(w, "invalid_"+name, name+" must be a positive number")
+		return 0, false
 	}
-	io.Copy(w, rsp.Body)
+	return i, true
 }
 
 func init() {
-	Router.PathPrefix("/api/v0/xhr/stats/").Methods("GET").Handler(authenticated(
-		func(w http.ResponseWriter, r *http.Request) {
-			statsPassthrough(fmt.Sprintf("%s?%s", r.URL.Path[17:], r.URL.Query().Encode()), w, r)
-		},
-	))
-	Router.PathPrefix("/xhr/stats/").Methods("GET").Handler(authenticated(
-		func(w http.ResponseWriter, r *http.Request) {
-			statsPassthrough(fmt.Sprintf("%s?%s", r.URL.Path[10:], r.URL.Query().Encode()), w, r)
-		},
-	))
+	for _, prefix := range []string{"/api/v0/xhr/stats/", "/xhr/stats/"} {
+		Router.Path(prefix + "members.json").Methods("GET").Handler(authenticated(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				counts, err := DB.MemberCounts(time.Now())
+				if err != nil {
+					Logger.Error("counting members", zap.Error(err))
+					writeInternalError(w)
+					return
+				}
+				json.NewEncoder(w).Encode(counts)
+			},
+		))
+		docRouteMethod(prefix+"members.json", methodDocEntry{
+			Method:      "GET",
+			Description: "Count current, active, new and departed members",
+			Response:    db.MemberCounts{},
+		})
+
+		Router.Path(prefix + "games/top.json").Methods("GET").Handler(authenticated(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				days, ok := queryPositiveInt(w, r, "days", 30)
+				if !ok {
+					return
+				}
+				limit, ok := queryPositiveInt(w, r, "limit", 10)
+				if !ok {
+					return
+				}
+				games, err := topGames(days, limit)
+				if err != nil {
+					Logger.Error("querying top games", zap.Int("days", days), zap.Int("limit", limit), zap.Error(err))
+					writeInternalError(w)
+					return
+				}
+				json.NewEncoder(w).Encode(games)
+			},
+		))
+		docRouteMethod(prefix+"games/top.json", methodDocEntry{
+			Method:      "GET",
+			Description: "List the games with the most active players recently",
+			OptionalParams: []methodParams{
+				{Name: "days", Type: "integer", Description: "How many days back to look, 30 if not given"},
+				{Name: "limit", Type: "integer", Description: "How many games to list, 10 if not given"},
+			},
+			Response: []db.TopGame{},
+		})
+
+		Router.Path(prefix + "players/{id}.json").Methods("GET").Handler(authenticated(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				days, ok := queryPositiveInt(w, r, "days", 30)
+				if !ok {
+					return
+				}
+				member, err := DB.MemberByAny(mux.Vars(r)["id"])
+				if err != nil {
+					writeDBError(w, err, "player lookup", zap.String("id", mux.Vars(r)["id"]))
+					return
+				}
+				games, err := DB.MemberPlayedGames(member.ID, time.Now().AddDate(0, 0, -days))
+				if err != nil {
+					Logger.Error("querying player history", zap.Int("member", member.ID), zap.Error(err))
+					writeInternalError(w)
+					return
+				}
+				json.NewEncoder(w).Encode(games)
+			},
+		))
+		docRouteMethod(prefix+"players/{id}.json", methodDocEntry{
+			Method:      "GET",
+			Description: "List the games a player has played recently, most recent first",
+			RequiredParams: []methodParams{
+				{Name: "id", Description: "Member id or Slack id of the player"},
+			},
+			OptionalParams: []methodParams{
+				{Name: "days", Type: "integer", Description: "How many days back to look, 30 if not given"},
+			},
+			Response: []db.PlayedGame{},
+		})
+
+		Router.PathPrefix(prefix).Methods("GET").Handler(authenticated(
+			func(w http.ResponseWriter, r *http.Request) {
+				writeError(w, http.StatusNotFound, "unknown_stat", "no such stat "+r.URL.Path)
+			},
+		))
+		docRouteMethod(prefix, methodDocEntry{
+			Method:      "GET",
+			Description: "Any other path under here answers 404 with the unknown_stat error code",
+		})
+	}
 }
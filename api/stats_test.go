@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQueryPositiveInt(t *testing.T) {
+	for _, test := range []struct {
+		query    string
+		expected int
+		ok       bool
+	}{
+		{"", 30, true},
+		{"?days=7", 7, true},
+		{"?days=0", 0, false},
+		{"?days=-1", 0, false},
+		{"?days=week", 0, false},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/v0/xhr/stats/games/top.json"+test.query, nil)
+		got, ok := queryPositiveInt(w, r, "days", 30)
+		if got != test.expected || ok != test.ok {
+			t.Errorf("%q: expected %d %v, got %d %v", test.query, test.expected, test.ok, got, ok)
+		}
+		if !ok && w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected a bad request, got %d", test.query, w.Code)
+		}
+	}
+}
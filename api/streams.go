@@ -3,15 +3,29 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/FederationOfFathers/dashboard/bot"
+	"github.com/FederationOfFathers/dashboard/db"
 	"github.com/FederationOfFathers/dashboard/streams"
 	"github.com/gorilla/mux"
-	"github.com/jinzhu/gorm"
 	"go.uber.org/zap"
 )
 
+type streamSetRequest struct {
+	// Kind is the service the stream streams from, twitch or youtube
+	Kind string `json:"kind"`
+	// ID is the identifier on the streaming service for the stream
+	ID string `json:"id"`
+	// UserID is the member to assign the stream to. Only admins may set a member other than themselves
+	UserID string `json:"userID"`
+}
+
+func validStreamKind(kind string) bool {
+	return kind == "twitch" || kind == "youtube"
+}
+
 func init() {
 	Router.Path("/api/v0/streams").Methods("GET").Handler(authenticated(
 		func(w http.ResponseWriter, r *http.Request) {
@@ -20,62 +34,76 @@ func init() {
 			enc.Encode(streams.Streams)
 		},
 	))
+	docRouteMethod("/api/v0/streams", methodDocEntry{
+		Method:      "GET",
+		Description: "List all registered streams",
+		Response:    []*db.Stream{},
+	})
 
 	Router.Path("/api/v1/streams/{memberID}/{type}").Methods("DELETE").Handler(authenticated(
 		func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Content-Type", "application/json")
-			id := getMemberID(r)
-			m, err := DB.MemberByAny(id)
-			if err != nil {
-				w.WriteHeader(http.StatusForbidden)
+			kind := mux.Vars(r)["type"]
+			if !validStreamKind(kind) {
+				writeBadRequest(w, "invalid_stream_kind", "type must be twitch or youtube")
+				return
+			}
+			m := requestMember(w, r)
+			if m == nil {
 				return
 			}
 			admin, _ := bot.IsUserIDAdmin(m.Discord)
 			member, err := DB.MemberByAny(mux.Vars(r)["memberID"])
 			if err != nil {
-				http.NotFound(w, r)
+				writeDBError(w, err, "member lookup", zap.String("memberID", mux.Vars(r)["memberID"]))
 				return
 			}
-			if strings.ToLower(member.Slack) != strings.ToLower(id) && !admin {
-				http.NotFound(w, r)
+			if member.ID != m.ID && !admin {
+				writeForbidden(w, "not_allowed", "only admins may change other members' streams")
 				return
 			}
 			stream, err := DB.StreamByMemberID(member.ID)
 			if err != nil {
-				http.NotFound(w, r)
+				writeDBError(w, err, "stream lookup", zap.Int("member", member.ID))
 				return
 			}
-			if err := streams.Remove(stream.ID, mux.Vars(r)["type"]); err != nil {
+			if err := streams.Remove(stream.ID, kind); err != nil {
 				Logger.Error("Error removing stream", zap.String("uri", r.URL.RawPath), zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
+				writeInternalError(w)
+				return
 			}
+			recordAudit(r, m, member, "stream.delete", map[string]string{kind: stream.Identifier(kind)}, nil)
 		},
 	))
+	docRouteMethod("/api/v1/streams/{memberID}/{type}", methodDocEntry{
+		Method:      "DELETE",
+		Description: "Delete a stream if the user is the owner or an admin",
+		RequiredParams: []methodParams{
+			{Name: "type", Description: "Which service to remove", Values: []string{"twitch", "youtube"}},
+		},
+	})
 
 	Router.Path("/api/v1/streams/{memberID}").Methods("GET").Handler(authenticated(
 		func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Content-Type", "application/json")
 			member, err := DB.MemberByAny(mux.Vars(r)["memberID"])
 			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				return
-			}
-			if err == gorm.ErrRecordNotFound || member == nil {
-				http.NotFound(w, r)
-				return
-			}
-			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
+				writeDBError(w, err, "member lookup", zap.String("memberID", mux.Vars(r)["memberID"]))
 				return
 			}
 			stream, err := DB.StreamByMemberID(member.ID)
-			if err != nil && err != gorm.ErrRecordNotFound {
-				w.WriteHeader(http.StatusInternalServerError)
+			if err != nil {
+				writeDBError(w, err, "stream lookup", zap.Int("member", member.ID))
 				return
 			}
 			json.NewEncoder(w).Encode(stream)
 		},
 	))
+	docRouteMethod("/api/v1/streams/{memberID}", methodDocEntry{
+		Method:      "GET",
+		Description: "Get the streams registered for a member",
+		Response:    db.Stream{},
+	})
 
 	streamSetHandler := authenticated(
 		func(w http.ResponseWriter, r *http.Request) {
@@ -84,14 +112,9 @@ func init() {
 			var id string
 			var userID string
 			if strings.Contains(strings.ToLower(r.Header.Get("Content-Type")), "json") {
-				var form = struct {
-					Kind   string `json:"kind"`
-					ID     string `json:"id"`
-					UserID string `json:"userID"`
-				}{}
-				err := json.NewDecoder(r.Body).Decode(&form)
-				if err != nil {
-					Logger.Error("Error decoding JSON", zap.String("uri", r.URL.RawPath), zap.Error(err))
+				var form = streamSetRequest{}
+				if !decodeJSON(w, r, &form) {
+					return
 				}
 				kind = form.Kind
 				id = form.ID
@@ -102,25 +125,35 @@ func init() {
 				userID = r.FormValue("userID")
 			}
 			w.Header().Set("Content-Type", "application/json")
-			if userID == "" {
-				userID = getMemberID(r)
+			if !validStreamKind(kind) {
+				writeBadRequest(w, "invalid_stream_kind", "kind must be twitch or youtube")
+				return
 			}
 
-			mid := getMemberID(r)
-			m, merr := DB.MemberByAny(mid)
-			if merr != nil {
-				w.WriteHeader(http.StatusForbidden)
+			m := requestMember(w, r)
+			if m == nil {
+				return
+			}
+			if userID == "" {
+				userID = strconv.Itoa(m.ID)
+			}
+			member, err := DB.MemberByAny(userID)
+			if err != nil {
+				writeDBError(w, err, "member lookup", zap.String("userID", userID))
 				return
 			}
 			admin, _ := bot.IsUserIDAdmin(m.Discord)
-			if mid != userID {
-				if !admin {
-					http.NotFound(w, r)
-					return
-				}
+			if member.ID != m.ID && !admin {
+				writeForbidden(w, "not_allowed", "only admins may change other members' streams")
+				return
 			}
 
-			err := streams.Add(kind, id, userID)
+			var before = map[string]string{}
+			if stream, err := DB.StreamByMemberID(member.ID); err == nil {
+				before[kind] = stream.Identifier(kind)
+			}
+
+			err = streams.Add(kind, id, strconv.Itoa(member.ID))
 			if err != nil {
 				Logger.Error(
 					"Error adding stream",
@@ -129,10 +162,19 @@ func init() {
 					zap.String("id", id),
 					zap.String("userID", userID),
 					zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
+				writeInternalError(w)
+				return
 			}
+			recordAudit(r, m, member, "stream.set", before, map[string]string{kind: id})
 		},
 	)
 	Router.Path("/api/v1/streams").Methods("PUT").Handler(streamSetHandler)
 	Router.Path("/api/v1/streams").Methods("POST").Handler(streamSetHandler)
+	for _, method := range []string{"PUT", "POST"} {
+		docRouteMethod("/api/v1/streams", methodDocEntry{
+			Method:      method,
+			Description: "Add a stream. Accepts a JSON body or the same fields as form values",
+			Request:     streamSetRequest{},
+		})
+	}
 }
@@ -6,7 +6,6 @@ import (
 	"strconv"
 
 	"github.com/FederationOfFathers/dashboard/bridge"
-	"go.uber.org/zap"
 )
 
 func init() {
@@ -21,12 +20,17 @@ func init() {
 				return
 			}
 			w.Header().Set("Content-Type", "application/json")
-			id := getMemberID(r)
-			member, err := DB.MemberByAny(id)
-			if err != nil {
-				Logger.Error("Unable to get member", zap.Error(err), zap.String("slackId", id))
+			member := requestMember(w, r)
+			if member == nil {
+				return
 			}
 			json.NewEncoder(w).Encode(bridge.OldEventToolAuthorization(strconv.Itoa(member.ID)))
 		},
 	)
+	docRouteMethod("/api/v1/auth/team-tool", methodDocEntry{
+		Method:      "GET",
+		Description: "Get an authorization string for the old team tool. Empty when not logged in",
+		Auth:        authNone,
+		Response:    "",
+	})
 }
@@ -1,33 +1,66 @@
 package api
 
 import (
-	"fmt"
-	"io"
+	"encoding/json"
 	"net/http"
-)
 
-func usersPassthrough(uri string, w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	rsp, err := http.Get(fmt.Sprintf("http://127.0.0.1:8875%s", uri))
-	if rsp != nil {
-		defer rsp.Body.Close()
-	}
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	io.Copy(w, rsp.Body)
-}
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
 
 func init() {
-	Router.PathPrefix("/api/v0/xhr/users/").Methods("GET").Handler(authenticated(
-		func(w http.ResponseWriter, r *http.Request) {
-			usersPassthrough(r.URL.Path[17:], w, r)
-		},
-	))
-	Router.PathPrefix("/xhr/users/").Methods("GET").Handler(authenticated(
-		func(w http.ResponseWriter, r *http.Request) {
-			usersPassthrough(r.URL.Path[10:], w, r)
-		},
-	))
+	for _, prefix := range []string{"/api/v0/xhr/users/", "/xhr/users/"} {
+		Router.Path(prefix + "search.json").Methods("GET").Handler(authenticated(searchMembers))
+		docRouteMethod(prefix+"search.json", methodDocEntry{
+			Method:      "GET",
+			Description: "Search members. Takes the same query string as /api/v1/members/search",
+			Response:    memberSearchResponse{},
+		})
+
+		Router.Path(prefix + "{id}.json").Methods("GET").Handler(authenticated(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				viewer := requestViewer(w, r)
+				if viewer == nil {
+					return
+				}
+				member, err := DB.MemberByAny(mux.Vars(r)["id"])
+				if err == nil && member.AnonymizedAt != nil {
+					writeError(w, http.StatusNotFound, "member_not_found", "no such member")
+					return
+				} else if err != nil {
+					writeDBError(w, err, "user lookup", zap.String("id", mux.Vars(r)["id"]))
+					return
+				}
+				visible, err := viewer.visibleMember(member)
+				if err != nil {
+					Logger.Error("Unable to apply member privacy", zap.Int("member", member.ID), zap.Error(err))
+					writeInternalError(w)
+					return
+				}
+				json.NewEncoder(w).Encode(memberSearchResult{
+					memberRestricted: memberToMemberRestricted(visible),
+					Seen:             visible.Seen,
+				})
+			},
+		))
+		docRouteMethod(prefix+"{id}.json", methodDocEntry{
+			Method:      "GET",
+			Description: "Get one member. Platform ids the member keeps private are left empty",
+			RequiredParams: []methodParams{
+				{Name: "id", Description: "Member id, Slack id or name"},
+			},
+			Response: memberSearchResult{},
+		})
+
+		Router.PathPrefix(prefix).Methods("GET").Handler(authenticated(
+			func(w http.ResponseWriter, r *http.Request) {
+				writeError(w, http.StatusNotFound, "unknown_user_query", "no such user query "+r.URL.Path)
+			},
+		))
+		docRouteMethod(prefix, methodDocEntry{
+			Method:      "GET",
+			Description: "Any other path under here answers 404 with the unknown_user_query error code",
+		})
+	}
 }
@@ -1,7 +1,6 @@
 package api
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
@@ -13,10 +12,10 @@ func init() {
 			var payload struct {
 				Ref string `json:"ref"`
 			}
-			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-				Logger.Warn(err.Error())
+			defer r.Body.Close()
+			if !decodeJSON(w, r, &payload) {
+				return
 			}
-			r.Body.Close()
 			Logger.Warn(fmt.Sprintf("%#v", payload))
 			touchFile := ""
 			if payload.Ref == "refs/heads/dev" {
@@ -30,4 +29,12 @@ func init() {
 			}
 		},
 	)
+	docRouteMethod("/api/v0/gh/ui-rebuild", methodDocEntry{
+		Method:      "POST",
+		Description: "GitHub push webhook which queues a rebuild of the UI",
+		Auth:        authNone,
+		Request: struct {
+			Ref string `json:"ref"`
+		}{},
+	})
 }
@@ -39,3 +39,25 @@ func IsUserIDVerified(userID string) (bool, error) {
 
 	return false, nil
 }
+
+// DiscordIDsWithRole returns the Discord IDs of every guild member with the role, given by id or name
+func DiscordIDsWithRole(role string) []string {
+	if r, err := data.RoleByName(role); err == nil {
+		role = r.ID
+	}
+	var rval = []string{}
+	for _, m := range data.GetMembers() {
+		for _, ur := range m.Roles {
+			if ur == role {
+				rval = append(rval, m.User.ID)
+				break
+			}
+		}
+	}
+	return rval
+}
+
+// VerifiedDiscordIDs returns the Discord IDs of every guild member with the verified role
+func VerifiedDiscordIDs() []string {
+	return DiscordIDsWithRole(verifiedRole)
+}
@@ -0,0 +1,25 @@
+package bot
+
+import (
+	"github.com/FederationOfFathers/dashboard/activity"
+	"github.com/bwmarrin/discordgo"
+)
+
+// activityMessageHandler records guild messages as member activity
+func (d *DiscordAPI) activityMessageHandler(s *discordgo.Session, event *discordgo.MessageCreate) {
+	if event.GuildID != d.Config.GuildId || event.Author == nil || event.Author.Bot {
+		return
+	}
+	activity.RecordDiscord(event.Author.ID, activity.SourceMessage)
+}
+
+// activityVoiceHandler records joining a voice channel as member activity
+func (d *DiscordAPI) activityVoiceHandler(s *discordgo.Session, event *discordgo.VoiceStateUpdate) {
+	if event.GuildID != d.Config.GuildId || event.ChannelID == "" {
+		return
+	}
+	if event.BeforeUpdate != nil && event.BeforeUpdate.ChannelID == event.ChannelID {
+		return
+	}
+	activity.RecordDiscord(event.UserID, activity.SourceVoice)
+}
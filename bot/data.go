@@ -5,6 +5,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/FederationOfFathers/dashboard/metrics"
 	"github.com/FederationOfFathers/dashboard/store"
 	"github.com/bwmarrin/discordgo"
 	"go.uber.org/zap"
@@ -233,10 +234,14 @@ func updateDiscordChannelsList() error {
 }
 
 func populateLists() {
+	defer func(start time.Time) {
+		metrics.CacheRefreshDuration.Observe(time.Since(start).Seconds())
+	}(time.Now())
 	var wg sync.WaitGroup
 	wg.Add(3)
+	var membersUpdated bool
 	go func() {
-		updateDiscordMemberList()
+		membersUpdated = updateDiscordMemberList() == nil
 		wg.Done()
 	}()
 	go func() {
@@ -254,4 +259,7 @@ func populateLists() {
 	DiscordCoreDataUpdated.Broadcast()
 	DiscordCoreDataUpdated.L.Unlock()
 	data.Unlock()
+	if membersUpdated {
+		syncGuildMembers(data.GetMembers())
+	}
 }
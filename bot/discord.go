@@ -7,7 +7,9 @@ import (
 	"time"
 
 	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/FederationOfFathers/dashboard/imagecache"
 	"github.com/FederationOfFathers/dashboard/messaging"
+	"github.com/FederationOfFathers/dashboard/metrics"
 	"github.com/bwmarrin/discordgo"
 	"github.com/honeycombio/beeline-go"
 	"go.uber.org/zap"
@@ -22,11 +24,17 @@ type DiscordAPI struct {
 }
 
 type DiscordCfg struct {
-	ClientId        string         `yaml:"appClientId"`
-	Token           string         `yaml:"botToken"`
-	StreamChannelId string         `yaml:"streamChannelId"`
-	GuildId         string         `yaml:"guildId"`
-	RoleCfg         DiscordRoleCfg `yaml:"roleConfig"`
+	ClientId           string         `yaml:"appClientId"`
+	Token              string         `yaml:"botToken"`
+	StreamChannelId    string         `yaml:"streamChannelId"`
+	ModLogChannelId    string         `yaml:"modLogChannelId"`
+	MilestoneChannelId string         `yaml:"milestoneChannelId"`
+	DigestChannelId    string         `yaml:"digestChannelId"`
+	LFGChannelId       string         `yaml:"lfgChannelId"`
+	LFGVoiceCategoryId string         `yaml:"lfgVoiceCategoryId"`
+	GuildId            string         `yaml:"guildId"`
+	RoleCfg            DiscordRoleCfg `yaml:"roleConfig"`
+	GameRoles          GameRolesCfg   `yaml:"gameRoles"`
 }
 
 type GuildChannels struct {
@@ -66,12 +74,22 @@ func StartDiscord(cfg DiscordCfg, yt *youtube.Service) (*DiscordAPI, error) {
 
 	// register slash command
 	discordApi.registerSlashStream()
+	discordApi.registerSlashProfile()
+	discordApi.registerSlashFindPlayers()
+	discordApi.registerSlashLFG()
 
 	//add handlers
 	discordApi.discord.AddHandler(discordApi.slashCommandHandlers)
 	discordApi.discord.AddHandler(discordApi.roleAssignmentHandler)
 	discordApi.discord.AddHandler(discordApi.teamCommandHandler)
 	discordApi.discord.AddHandler(discordApi.verifiedEventsHandler)
+	discordApi.discord.AddHandler(countDiscordEvents)
+	discordApi.discord.AddHandler(discordApi.guildMemberAddHandler)
+	discordApi.discord.AddHandler(discordApi.guildMemberUpdateHandler)
+	discordApi.discord.AddHandler(discordApi.guildMemberRemoveHandler)
+	discordApi.discord.AddHandler(discordApi.activityMessageHandler)
+	discordApi.discord.AddHandler(discordApi.activityVoiceHandler)
+	discordApi.discord.AddHandler(discordApi.presenceGamesHandler)
 
 	//go discordApi.setChannelAssignMessage()
 
@@ -86,6 +104,11 @@ func StartDiscord(cfg DiscordCfg, yt *youtube.Service) (*DiscordAPI, error) {
 
 }
 
+// countDiscordEvents counts every event received from the gateway by type
+func countDiscordEvents(s *discordgo.Session, event *discordgo.Event) {
+	metrics.DiscordEvents.WithLabelValues(event.Type).Inc()
+}
+
 // verifiedEventsHandler checks if the user is verified before running the handler
 func (d *DiscordAPI) verifiedEventsHandler(s *discordgo.Session, event *discordgo.MessageCreate) {
 	if event.GuildID != d.Config.GuildId {
@@ -260,6 +283,8 @@ func (d *DiscordAPI) Connect() error {
 		return err
 	}
 
+	// guild member events are needed to provision members as they join and leave, and presences to record what they play
+	dg.Identify.Intents = discordgo.IntentsAllWithoutPrivileged | discordgo.IntentsGuildMembers | discordgo.IntentsGuildPresences
 	d.discord = dg
 	return dg.Open()
 }
@@ -282,6 +307,97 @@ func (d *DiscordAPI) SendDM(userID string, message string) {
 	}
 }
 
+// PostDirectMessage sends a DM to a member from the bot
+func (d *DiscordAPI) PostDirectMessage(member *db.Member, message string) error {
+	if d.discord == nil {
+		return fmt.Errorf("discord API not connected")
+	}
+	if member.Discord == "" {
+		return fmt.Errorf("member %d has no discord id", member.ID)
+	}
+	ch, err := d.discord.UserChannelCreate(member.Discord)
+	if err != nil {
+		return err
+	}
+	_, err = d.discord.ChannelMessageSend(ch.ID, message)
+	return err
+}
+
+// PostMilestoneMessage announces a member milestone in the milestone channel
+func (d *DiscordAPI) PostMilestoneMessage(message string) error {
+	if d.discord == nil {
+		return fmt.Errorf("discord API not connected")
+	}
+	if d.Config.MilestoneChannelId == "" {
+		return fmt.Errorf("milestone channel id not configured")
+	}
+	_, err := d.discord.ChannelMessageSend(d.Config.MilestoneChannelId, message)
+	return err
+}
+
+// PostDigestMessage posts the weekly summary of what the community played to the digest channel
+func (d *DiscordAPI) PostDigestMessage(trends *db.GameTrends) error {
+	if d.discord == nil {
+		return fmt.Errorf("discord API not connected")
+	}
+	if d.Config.DigestChannelId == "" {
+		return fmt.Errorf("digest channel id not configured")
+	}
+	_, err := d.discord.ChannelMessageSendEmbed(d.Config.DigestChannelId, digestEmbed(trends))
+	return err
+}
+
+// digestEmbed lays out the weekly digest with a field each for the most played, rising and falling games
+func digestEmbed(trends *db.GameTrends) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Title:       "🎮 What the community played",
+		Description: fmt.Sprintf("Week of %s", trends.Week.Format("January 2")),
+		Color:       0x5865F2,
+		Timestamp:   trends.Week.AddDate(0, 0, 7).Format(time.RFC3339),
+	}
+	if len(trends.Top) == 0 {
+		embed.Description += "\nNobody's games were recorded this week"
+		return embed
+	}
+	var sections = []struct {
+		name   string
+		trends []*db.GameTrend
+		line   func(n int, t *db.GameTrend) string
+	}{
+		{"Most played", trends.Top, func(n int, t *db.GameTrend) string {
+			return fmt.Sprintf("%d. **%s**, %s", n+1, t.Name, plural(t.Players, "player"))
+		}},
+		{"Rising", trends.Rising, func(n int, t *db.GameTrend) string {
+			return fmt.Sprintf("📈 **%s** +%d, %s", t.Name, t.Delta, plural(t.Players, "player"))
+		}},
+		{"Falling", trends.Falling, func(n int, t *db.GameTrend) string {
+			return fmt.Sprintf("📉 **%s** %d, %s", t.Name, t.Delta, plural(t.Players, "player"))
+		}},
+	}
+	for _, section := range sections {
+		if len(section.trends) == 0 {
+			continue
+		}
+		var lines []string
+		for n, t := range section.trends {
+			lines = append(lines, section.line(n, t))
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: section.name, Value: strings.Join(lines, "\n")})
+	}
+	if image := trends.Top[0].Image; strings.HasPrefix(image, "http") {
+		embed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: imagecache.Link(image, "thumbnail")}
+	}
+	return embed
+}
+
+// plural returns n and the noun, with an s unless n is 1
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
 func (d DiscordAPI) PostStreamMessage(sm messaging.StreamMessage) error {
 	if d.discord == nil {
 		return fmt.Errorf("discord API not connected")
@@ -402,3 +518,39 @@ func userIDFromMention(mention string) string {
 func channelIDFromChannelLink(channelLink string) string {
 	return strings.Trim(channelLink[2:len(channelLink)-1], "!")
 }
+
+// PostAuditMessage mirrors an audit log entry to the mod log channel, if one is configured
+func (d *DiscordAPI) PostAuditMessage(a *db.AuditLog, actor string, target string) error {
+	if d.Config.ModLogChannelId == "" {
+		return nil
+	}
+	if d.discord == nil {
+		return fmt.Errorf("discord API not connected")
+	}
+
+	messageEmbed := discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("🛡️ %s", a.Action),
+		Description: fmt.Sprintf("**%s** (%d) changed **%s** (%d)", actor, a.ActorID, target, a.TargetID),
+		Color:       0xDC3545,
+		Timestamp:   a.CreatedAt.Format(time.RFC3339),
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Before", Value: auditValue(a.Before)},
+			{Name: "After", Value: auditValue(a.After)},
+		},
+	}
+
+	_, err := d.discord.ChannelMessageSendEmbed(d.Config.ModLogChannelId, &messageEmbed)
+	return err
+}
+
+// auditValue formats audit JSON for an embed field, which may not be empty or longer than 1024 characters
+func auditValue(v []byte) string {
+	s := string(v)
+	if s == "" || s == "null" {
+		return "-"
+	}
+	if len(s) > 1000 {
+		s = s[:1000] + "…"
+	}
+	return "```" + s + "```"
+}
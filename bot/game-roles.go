@@ -0,0 +1,227 @@
+package bot
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/FederationOfFathers/dashboard/db"
+	"go.uber.org/zap"
+)
+
+// Actions in a game role report
+const (
+	GameRoleGrant  = "grant"
+	GameRoleRemove = "remove"
+)
+
+// GameRolesCfg configures giving Discord roles to members from the games they play. Games take part when an admin
+// links them to a role and turns on autoRole
+type GameRolesCfg struct {
+	Enabled bool `yaml:"enabled"`
+	// DryRun only logs what the hourly job would change
+	DryRun bool `yaml:"dryRun"`
+	// Days is how recently a member must have played a game to be given its role. Defaults to 14
+	Days int `yaml:"days"`
+	// IdleDays is how long after they last played a member loses a role they were given. 0 never takes roles away,
+	// and it is never less than Days, so a role is not taken away only to be given back
+	IdleDays int `yaml:"idleDays"`
+}
+
+// days returns Days, or its default when unset
+func (c GameRolesCfg) days() int {
+	if c.Days < 1 {
+		return 14
+	}
+	return c.Days
+}
+
+// GameRoleChange is a role the job gives a member, or takes away
+type GameRoleChange struct {
+	Action   string `json:"action"`
+	MemberID int    `json:"memberID"`
+	Discord  string `json:"discordID"`
+	RoleID   string `json:"roleID"`
+	Role     string `json:"role"`
+	// GameID and Game are the game linked to the role the member played last, if any
+	GameID int       `json:"gameID,omitempty"`
+	Game   string    `json:"game,omitempty"`
+	Played time.Time `json:"played"`
+	// Applied is false in a dry run, and when Discord refused the change
+	Applied bool `json:"applied"`
+}
+
+// GameRoleReport lists the changes a run of the game role job made, or would have made in a dry run
+type GameRoleReport struct {
+	DryRun  bool              `json:"dryRun"`
+	At      time.Time         `json:"at"`
+	Changes []*GameRoleChange `json:"changes"`
+}
+
+// ErrNotConnected means there is no Discord session to make changes through
+var ErrNotConnected = errors.New("discord API not connected")
+
+var gameRolesLock sync.Mutex
+
+// MindGameRoles runs the game role job every hour once the guild roster has loaded
+func (d *DiscordAPI) MindGameRoles() {
+	go func() {
+		for range time.Tick(time.Hour) {
+			if len(data.GetMembers()) == 0 {
+				continue
+			}
+			report, err := RunGameRoles(d.Config.GameRoles.DryRun)
+			if err != nil {
+				Logger.Error("unable to update game roles", zap.Error(err))
+				continue
+			}
+			for _, c := range report.Changes {
+				Logger.Info("game role",
+					zap.Bool("dryRun", report.DryRun),
+					zap.String("action", c.Action),
+					zap.Int("member", c.MemberID),
+					zap.String("role", c.Role),
+					zap.String("game", c.Game),
+					zap.Bool("applied", c.Applied))
+			}
+		}
+	}()
+}
+
+// RunGameRoles gives game roles to members who played a linked game recently, and takes away roles given before
+// once a member has been idle for IdleDays. A dry run only reports what it would do
+func RunGameRoles(dryRun bool) (*GameRoleReport, error) {
+	if discordApi == nil {
+		return nil, ErrNotConnected
+	}
+	gameRolesLock.Lock()
+	defer gameRolesLock.Unlock()
+
+	plays, err := DB.GameRolePlays()
+	if err != nil {
+		return nil, err
+	}
+	grants, err := DB.GameRoleGrants()
+	if err != nil {
+		return nil, err
+	}
+	var current = map[string]map[string]bool{}
+	for _, m := range data.GetMembers() {
+		if m.User == nil {
+			continue
+		}
+		current[m.User.ID] = map[string]bool{}
+		for _, role := range m.Roles {
+			current[m.User.ID][role] = true
+		}
+	}
+	var names = map[string]string{}
+	data.RLock()
+	for _, role := range data.Roles {
+		names[role.ID] = role.Name
+	}
+	data.RUnlock()
+
+	now := time.Now()
+	report := &GameRoleReport{DryRun: dryRun, At: now}
+	report.Changes = planGameRoles(plays, grants, current, now, discordApi.Config.GameRoles)
+	for _, c := range report.Changes {
+		c.Role = names[c.RoleID]
+		if !dryRun {
+			c.Applied = discordApi.applyGameRole(c, now)
+		}
+	}
+	return report, nil
+}
+
+// applyGameRole makes a change in Discord and records it, reporting whether Discord accepted it
+func (d *DiscordAPI) applyGameRole(c *GameRoleChange, now time.Time) bool {
+	switch c.Action {
+	case GameRoleGrant:
+		if !d.addRoleToUser(c.Discord, c.RoleID) {
+			return false
+		}
+		if err := DB.RecordGameRoleGrant(c.MemberID, c.RoleID, now); err != nil {
+			Logger.Error("unable to record game role", zap.Int("member", c.MemberID), zap.String("role", c.RoleID), zap.Error(err))
+		}
+	case GameRoleRemove:
+		if !d.removeRoleFromUser(c.Discord, c.RoleID) {
+			return false
+		}
+		if err := DB.DeleteGameRoleGrant(c.MemberID, c.RoleID); err != nil {
+			Logger.Error("unable to forget game role", zap.Int("member", c.MemberID), zap.String("role", c.RoleID), zap.Error(err))
+		}
+	}
+	data.setMemberRole(c.Discord, c.RoleID, c.Action == GameRoleGrant)
+	return true
+}
+
+// planGameRoles works out the roles to give and take away. current maps the Discord ids of guild members to the
+// roles they have. Only roles the job gave are taken away, and members not in the guild are left alone
+func planGameRoles(plays []*db.GameRolePlay, grants []*db.GameRoleGrant, current map[string]map[string]bool, now time.Time, cfg GameRolesCfg) []*GameRoleChange {
+	var changes = []*GameRoleChange{}
+	var played = map[string]*db.GameRolePlay{}
+	recent := now.AddDate(0, 0, -cfg.days())
+	for _, p := range plays {
+		played[p.Discord+"/"+p.RoleID] = p
+		roles, ok := current[p.Discord]
+		if !ok || roles[p.RoleID] || p.Played.Before(recent) {
+			continue
+		}
+		changes = append(changes, &GameRoleChange{
+			Action:   GameRoleGrant,
+			MemberID: p.MemberID,
+			Discord:  p.Discord,
+			RoleID:   p.RoleID,
+			GameID:   p.GameID,
+			Game:     p.Game,
+			Played:   p.Played,
+		})
+	}
+	if cfg.IdleDays < 1 {
+		return changes
+	}
+	idleDays := cfg.IdleDays
+	if idleDays < cfg.days() {
+		idleDays = cfg.days()
+	}
+	idle := now.AddDate(0, 0, -idleDays)
+	for _, g := range grants {
+		if !current[g.Discord][g.RoleID] {
+			continue
+		}
+		change := &GameRoleChange{Action: GameRoleRemove, MemberID: g.MemberID, Discord: g.Discord, RoleID: g.RoleID}
+		if p, ok := played[g.Discord+"/"+g.RoleID]; ok {
+			if !p.Played.Before(idle) {
+				continue
+			}
+			change.GameID, change.Game, change.Played = p.GameID, p.Game, p.Played
+		} else if !g.GrantedAt.Before(idle) {
+			// the game was unlinked from the role, so the member has been idle since at least when it was given
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// setMemberRole updates the cached roster after a role changes, until it is next loaded from Discord
+func (d *DiscordData) setMemberRole(discordID, roleID string, has bool) {
+	d.Lock()
+	defer d.Unlock()
+	for _, m := range d.Users {
+		if m.User == nil || m.User.ID != discordID {
+			continue
+		}
+		var roles = []string{}
+		for _, r := range m.Roles {
+			if r != roleID {
+				roles = append(roles, r)
+			}
+		}
+		if has {
+			roles = append(roles, roleID)
+		}
+		m.Roles = roles
+	}
+}
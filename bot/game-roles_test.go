@@ -0,0 +1,62 @@
+package bot
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FederationOfFathers/dashboard/db"
+)
+
+func TestPlanGameRoles(t *testing.T) {
+	now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(n int) time.Time {
+		return now.AddDate(0, 0, -n)
+	}
+	plays := []*db.GameRolePlay{
+		{MemberID: 1, Discord: "d1", RoleID: "destiny", Played: daysAgo(2)},
+		{MemberID: 2, Discord: "d2", RoleID: "destiny", Played: daysAgo(2)},
+		{MemberID: 3, Discord: "d3", RoleID: "destiny", Played: daysAgo(20)},
+		{MemberID: 4, Discord: "d4", RoleID: "destiny", Played: daysAgo(40)},
+		{MemberID: 5, Discord: "gone", RoleID: "destiny", Played: daysAgo(1)},
+		{MemberID: 6, Discord: "d6", RoleID: "destiny", Played: daysAgo(40)},
+	}
+	grants := []*db.GameRoleGrant{
+		{MemberID: 3, Discord: "d3", RoleID: "destiny", GrantedAt: daysAgo(25)},
+		{MemberID: 4, Discord: "d4", RoleID: "destiny", GrantedAt: daysAgo(45)},
+		{MemberID: 7, Discord: "d7", RoleID: "sot", GrantedAt: daysAgo(60)},
+		{MemberID: 8, Discord: "d8", RoleID: "sot", GrantedAt: daysAgo(60)},
+	}
+	current := map[string]map[string]bool{
+		"d1": {},
+		"d2": {"destiny": true},
+		"d3": {"destiny": true},
+		"d4": {"destiny": true},
+		"d6": {"destiny": true},
+		"d7": {"sot": true},
+		"d8": {},
+	}
+
+	for _, test := range []struct {
+		name     string
+		cfg      GameRolesCfg
+		expected []string
+	}{
+		// d2 has the role already, d5 left the guild and d6 was given the role by hand
+		{"keep roles", GameRolesCfg{Days: 14}, []string{"grant 1 destiny"}},
+		{"remove idle", GameRolesCfg{Days: 14, IdleDays: 30}, []string{"grant 1 destiny", "remove 4 destiny", "remove 7 sot"}},
+		{"idle days below days", GameRolesCfg{Days: 14, IdleDays: 7}, []string{"grant 1 destiny", "remove 3 destiny", "remove 4 destiny", "remove 7 sot"}},
+		{"default days", GameRolesCfg{}, []string{"grant 1 destiny"}},
+	} {
+		var got []string
+		for _, c := range planGameRoles(plays, grants, current, now, test.cfg) {
+			got = append(got, strings.Join([]string{c.Action, strconv.Itoa(c.MemberID), c.RoleID}, " "))
+		}
+		sort.Strings(got)
+		if strings.Join(got, ", ") != strings.Join(test.expected, ", ") {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package bot
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+// PresenceInterval is how often the same member playing the same game is written to membergames
+var PresenceInterval = 15 * time.Minute
+
+// PresenceNewGames is how many unknown games one member's presence may add for review each day
+var PresenceNewGames = 5
+
+// presenceOptOutKey is the boolean meta key members set to keep their games from being recorded
+const presenceOptOutKey = "hidePresenceGames"
+
+// presenceLimiter keeps presence updates, which Discord sends often, from writing to the database on every one
+type presenceLimiter struct {
+	sync.Mutex
+	played  map[string]time.Time
+	created map[string][]time.Time
+}
+
+var presence = &presenceLimiter{played: map[string]time.Time{}, created: map[string][]time.Time{}}
+
+// allowPlayed reports whether a member playing a game should be written, at most once per PresenceInterval
+func (l *presenceLimiter) allowPlayed(discordID, game string, now time.Time) bool {
+	key := discordID + "/" + strings.ToLower(game)
+	l.Lock()
+	defer l.Unlock()
+	if last, ok := l.played[key]; ok && now.Sub(last) < PresenceInterval {
+		return false
+	}
+	l.played[key] = now
+	if len(l.played) > 10000 {
+		for k, t := range l.played {
+			if now.Sub(t) >= PresenceInterval {
+				delete(l.played, k)
+			}
+		}
+	}
+	return true
+}
+
+// allowCreate reports whether a member may add another unknown game, at most PresenceNewGames a day
+func (l *presenceLimiter) allowCreate(discordID string, now time.Time) bool {
+	l.Lock()
+	defer l.Unlock()
+	var recent []time.Time
+	for _, t := range l.created[discordID] {
+		if now.Sub(t) < 24*time.Hour {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= PresenceNewGames {
+		l.created[discordID] = recent
+		return false
+	}
+	l.created[discordID] = append(recent, now)
+	return true
+}
+
+// presenceGamesHandler records the games guild members are shown playing in membergames
+func (d *DiscordAPI) presenceGamesHandler(s *discordgo.Session, event *discordgo.PresenceUpdate) {
+	if event.GuildID != d.Config.GuildId || event.User == nil || event.User.Bot {
+		return
+	}
+	now := time.Now()
+	for _, a := range event.Activities {
+		if a == nil || a.Type != discordgo.ActivityTypeGame {
+			continue
+		}
+		name := strings.TrimSpace(a.Name)
+		if name == "" || !presence.allowPlayed(event.User.ID, name, now) {
+			continue
+		}
+		recordPresenceGame(event.User.ID, name, now)
+	}
+}
+
+func recordPresenceGame(discordID string, name string, now time.Time) {
+	member, err := DB.MemberByDiscordID(discordID)
+	if err != nil {
+		if !errors.Is(err, db.ErrNotFound) {
+			Logger.Error("unable to find member for presence", zap.String("discordID", discordID), zap.Error(err))
+		}
+		return
+	}
+	meta, err := DB.MemberMetaJSON(member.ID)
+	if err != nil {
+		Logger.Error("unable to read presence opt out", zap.Int("member", member.ID), zap.Error(err))
+		return
+	}
+	if string(meta[presenceOptOutKey]) == "true" {
+		return
+	}
+
+	game, err := DB.GameByName(name)
+	if errors.Is(err, db.ErrNotFound) {
+		if !presence.allowCreate(discordID, now) {
+			return
+		}
+		game, err = DB.CreatePendingGame(name)
+		if err == nil {
+			Logger.Info("added game from presence for review", zap.Int("game", game.ID), zap.String("name", name), zap.Int("member", member.ID))
+		}
+	}
+	if err != nil {
+		Logger.Error("unable to find game for presence", zap.String("name", name), zap.Error(err))
+		return
+	}
+	if err := DB.RecordPlayed(member.ID, game.ID, now, db.GameSourceDiscord); err != nil {
+		Logger.Error("unable to record played game", zap.Int("member", member.ID), zap.Int("game", game.ID), zap.Error(err))
+	}
+}
@@ -0,0 +1,43 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPresenceLimiter(t *testing.T) {
+	l := &presenceLimiter{played: map[string]time.Time{}, created: map[string][]time.Time{}}
+	now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+
+	for _, test := range []struct {
+		discordID string
+		game      string
+		at        time.Duration
+		expected  bool
+	}{
+		{"1", "Halo", 0, true},
+		{"1", "halo", time.Minute, false},
+		{"1", "Destiny 2", time.Minute, true},
+		{"2", "Halo", time.Minute, true},
+		{"1", "Halo", PresenceInterval, true},
+	} {
+		if got := l.allowPlayed(test.discordID, test.game, now.Add(test.at)); got != test.expected {
+			t.Errorf("%s playing %s at +%s: expected %v, got %v", test.discordID, test.game, test.at, test.expected, got)
+		}
+	}
+
+	for i := 0; i < PresenceNewGames; i++ {
+		if !l.allowCreate("1", now) {
+			t.Fatalf("new game %d was not allowed", i+1)
+		}
+	}
+	if l.allowCreate("1", now.Add(time.Hour)) {
+		t.Error("expected new games to be limited")
+	}
+	if !l.allowCreate("2", now) {
+		t.Error("expected another member to be allowed a new game")
+	}
+	if !l.allowCreate("1", now.Add(24*time.Hour)) {
+		t.Error("expected the limit to reset after a day")
+	}
+}
@@ -0,0 +1,103 @@
+package bot
+
+import (
+	"errors"
+
+	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+// memberDisplayName is the name a guild member goes by, their nickname if they have one
+func memberDisplayName(m *discordgo.Member) string {
+	if m.Nick != "" {
+		return m.Nick
+	}
+	if m.User != nil {
+		return m.User.Username
+	}
+	return ""
+}
+
+// syncGuildMember creates or updates the member record for a guild member
+func syncGuildMember(m *discordgo.Member) {
+	if m.User == nil || m.User.Bot {
+		return
+	}
+	member, created, err := DB.SyncDiscordMember(m.User.ID, memberDisplayName(m))
+	if errors.Is(err, db.ErrForbidden) {
+		return
+	}
+	if err != nil {
+		Logger.Error("unable to sync guild member", zap.String("discordID", m.User.ID), zap.Error(err))
+		return
+	}
+	if created {
+		Logger.Info("added new member", zap.String("discordID", m.User.ID), zap.Int("memberID", member.ID))
+	}
+}
+
+// syncGuildMembers provisions member records for the whole guild roster and flags members who are no longer in it
+func syncGuildMembers(members []*discordgo.Member) {
+	if DB == nil || len(members) == 0 {
+		return
+	}
+	var ids = make([]string, 0, len(members))
+	var guild = make([]db.GuildMember, 0, len(members))
+	for _, m := range members {
+		if m.User == nil {
+			continue
+		}
+		ids = append(ids, m.User.ID)
+		if !m.User.Bot {
+			guild = append(guild, db.GuildMember{Discord: m.User.ID, Name: memberDisplayName(m)})
+		}
+	}
+	synced, err := DB.SyncDiscordMembers(guild)
+	if err != nil {
+		Logger.Error("unable to sync guild members", zap.Error(err))
+		return
+	}
+	if synced.Created > 0 || synced.Renamed > 0 || synced.Returned > 0 {
+		Logger.Info("synced guild members",
+			zap.Int("created", synced.Created),
+			zap.Int("renamed", synced.Renamed),
+			zap.Int("returned", synced.Returned))
+	}
+	departed, err := DB.MarkMembersDepartedExcept(ids)
+	if err != nil {
+		Logger.Error("unable to flag departed members", zap.Error(err))
+		return
+	}
+	if departed > 0 {
+		Logger.Info("flagged members who left the guild", zap.Int64("count", departed))
+	}
+}
+
+// guildMemberAddHandler provisions a member record for someone joining the guild
+func (d *DiscordAPI) guildMemberAddHandler(s *discordgo.Session, event *discordgo.GuildMemberAdd) {
+	if event.GuildID != d.Config.GuildId {
+		return
+	}
+	syncGuildMember(event.Member)
+}
+
+// guildMemberUpdateHandler keeps a member's name in line with their nickname
+func (d *DiscordAPI) guildMemberUpdateHandler(s *discordgo.Session, event *discordgo.GuildMemberUpdate) {
+	if event.GuildID != d.Config.GuildId {
+		return
+	}
+	syncGuildMember(event.Member)
+}
+
+// guildMemberRemoveHandler flags a member as having left the guild
+func (d *DiscordAPI) guildMemberRemoveHandler(s *discordgo.Session, event *discordgo.GuildMemberRemove) {
+	if event.GuildID != d.Config.GuildId || event.User == nil {
+		return
+	}
+	if err := DB.MarkMemberDeparted(event.User.ID); err != nil {
+		Logger.Error("unable to flag departed member", zap.String("discordID", event.User.ID), zap.Error(err))
+		return
+	}
+	Logger.Info("member left the guild", zap.String("discordID", event.User.ID))
+}
@@ -3,6 +3,8 @@ package bot
 import (
 	"strings"
 
+	"github.com/FederationOfFathers/dashboard/activity"
+	"github.com/FederationOfFathers/dashboard/db"
 	"github.com/bwmarrin/discordgo"
 	"go.uber.org/zap"
 )
@@ -11,6 +13,9 @@ import (
 func (d *DiscordAPI) slashCommandHandlers(s *discordgo.Session, i *discordgo.InteractionCreate) {
 
 	Logger.With(zap.Any("interaction", i.Interaction)).Debug("slash command interaction")
+	if i.Member != nil && i.Member.User != nil {
+		activity.RecordDiscord(i.Member.User.ID, activity.SourceInteraction)
+	}
 
 	switch i.Type {
 	case discordgo.InteractionApplicationCommand:
@@ -29,6 +34,8 @@ func (d *DiscordAPI) slashMessageComponentHandler(s *discordgo.Session, i *disco
 	switch customID[:strings.Index(customID, ":")] {
 	case "stream":
 		d.slashStreamComponentHandler(s, i)
+	case "lfg":
+		d.slashLFGComponentHandler(s, i)
 	}
 }
 
@@ -38,5 +45,34 @@ func (d *DiscordAPI) slashApplicationCommanInteractionHandler(s *discordgo.Sessi
 	switch i.Interaction.ApplicationCommandData().Name {
 	case "stream":
 		d.slashStreamHandler(s, i)
+	case "profile":
+		d.slashProfileHandler(s, i)
+	case "findplayers":
+		d.slashFindPlayersHandler(s, i)
+	case "lfg":
+		d.slashLFGHandler(s, i)
+	}
+}
+
+// respondEphemeral responds to an interaction with a message only the user who triggered it can see
+func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   64,
+		},
+	})
+	if err != nil {
+		Logger.With(zap.Error(err)).Error("unable to respond to interaction")
+	}
+}
+
+// interactionMember gets the member record for the user who triggered an interaction, creating it if needed
+func interactionMember(i *discordgo.InteractionCreate) (*db.Member, error) {
+	m, created, err := DB.SyncDiscordMember(i.Member.User.ID, memberDisplayName(i.Member))
+	if created {
+		Logger.Info("adding new member", zap.String("discordID", i.Member.User.ID))
 	}
+	return m, err
 }
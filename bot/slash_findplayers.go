@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+// FindPlayersDays is how far back /findplayers looks when no number of days is given
+var FindPlayersDays = 30
+
+// findPlayersMax is how many players /findplayers lists, to stay inside Discord's message length
+const findPlayersMax = 25
+
+// registerSlashFindPlayers registers the /findplayers command for the bot
+func (d *DiscordAPI) registerSlashFindPlayers() {
+	minDays := float64(1)
+	findPlayersCommand := &discordgo.ApplicationCommand{
+		Name:        "findplayers",
+		Description: "Lists the members who played a game recently",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "game",
+				Description: "the game's name",
+				Required:    true,
+				Type:        discordgo.ApplicationCommandOptionString,
+			},
+			{
+				Name:        "days",
+				Description: fmt.Sprintf("how many days back to look, %d if not given", FindPlayersDays),
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				MinValue:    &minDays,
+				MaxValue:    365,
+			},
+		},
+	}
+
+	if _, err := d.discord.ApplicationCommandCreate(d.discord.State.User.ID, d.Config.GuildId, findPlayersCommand); err != nil {
+		Logger.With(zap.Error(err)).Error("unable to register findplayers slash command")
+	}
+}
+
+// slashFindPlayersHandler handles the /findplayers command
+func (d *DiscordAPI) slashFindPlayersHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var name string
+	var days = FindPlayersDays
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "game":
+			name = strings.TrimSpace(option.StringValue())
+		case "days":
+			days = int(option.IntValue())
+		}
+	}
+
+	game, err := findGame(name)
+	if err != nil {
+		Logger.With(zap.Error(err), zap.String("game", name)).Error("unable to look up game")
+		respondEphemeral(s, i, "hmm, something didn't go right...sorry! try again if you must")
+		return
+	}
+	if game == nil {
+		respondEphemeral(s, i, fmt.Sprintf("I don't know a game called `%s`", name))
+		return
+	}
+
+	players, err := DB.GamePlayers(game.ID, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		Logger.With(zap.Error(err), zap.Int("game", game.ID)).Error("unable to list game players")
+		respondEphemeral(s, i, "hmm, something didn't go right...sorry! try again if you must")
+		return
+	}
+	respondEphemeral(s, i, findPlayersSummary(game.Name, days, players))
+}
+
+// findGame finds a game by its name or an alias, then by the closest search match. It returns nil when nothing matches
+func findGame(name string) (*db.Game, error) {
+	game, err := DB.GameByName(name)
+	if !errors.Is(err, db.ErrNotFound) {
+		return game, err
+	}
+	games, err := DB.SearchGames(name, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(games) == 0 {
+		return nil, nil
+	}
+	return games[0], nil
+}
+
+// findPlayersSummary lists the members who played a game, most recent first
+func findPlayersSummary(game string, days int, players []db.GamePlayer) string {
+	if len(players) == 0 {
+		return fmt.Sprintf("nobody has played **%s** in the last %d days", game, days)
+	}
+	var lines = []string{fmt.Sprintf("**%s**, played in the last %d days by:", game, days)}
+	for n, p := range players {
+		if n == findPlayersMax {
+			lines = append(lines, fmt.Sprintf("...and %d more", len(players)-n))
+			break
+		}
+		who := p.Member.Name
+		if p.Member.Discord != "" {
+			who = fmt.Sprintf("<@%s>", p.Member.Discord)
+		}
+		lines = append(lines, fmt.Sprintf("%s, <t:%d:R>", who, p.Played.Unix()))
+	}
+	return strings.Join(lines, "\n")
+}
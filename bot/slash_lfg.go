@@ -0,0 +1,403 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+// LFGDuration is how many minutes an /lfg entry stays open when no duration is given
+var LFGDuration = 60
+
+// registerSlashLFG registers the /lfg command for the bot
+func (d *DiscordAPI) registerSlashLFG() {
+	minSize, minMinutes := float64(db.LFGMinSize), float64(1)
+	lfgCommand := &discordgo.ApplicationCommand{
+		Name:        "lfg",
+		Description: "Look for a group to play a game with right now",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "game",
+				Description: "the game's name",
+				Required:    true,
+				Type:        discordgo.ApplicationCommandOptionString,
+			},
+			{
+				Name:        "size",
+				Description: "how many players in total, including you",
+				Required:    true,
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				MinValue:    &minSize,
+				MaxValue:    db.LFGMaxSize,
+			},
+			{
+				Name:        "duration",
+				Description: fmt.Sprintf("how many minutes to keep looking, %d if not given", LFGDuration),
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				MinValue:    &minMinutes,
+				MaxValue:    db.LFGMaxDuration.Minutes(),
+			},
+		},
+	}
+
+	if _, err := d.discord.ApplicationCommandCreate(d.discord.State.User.ID, d.Config.GuildId, lfgCommand); err != nil {
+		Logger.With(zap.Error(err)).Error("unable to register lfg slash command")
+	}
+}
+
+// slashLFGHandler handles the /lfg command, posting the entry with its buttons in the channel it was used in
+func (d *DiscordAPI) slashLFGHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var l = &db.LFG{}
+	var minutes = LFGDuration
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "game":
+			l.Game = strings.TrimSpace(option.StringValue())
+		case "size":
+			l.Size = int(option.IntValue())
+		case "duration":
+			minutes = int(option.IntValue())
+		}
+	}
+
+	game, err := findGame(l.Game)
+	if err != nil {
+		Logger.With(zap.Error(err), zap.String("game", l.Game)).Error("unable to look up game")
+		respondEphemeral(s, i, "hmm, something didn't go right...sorry! try again if you must")
+		return
+	}
+	if game != nil {
+		l.GameID, l.Game = game.ID, game.Name
+	}
+	host, err := interactionMember(i)
+	if err != nil {
+		Logger.With(zap.Error(err)).Error("unable to find member data")
+		respondEphemeral(s, i, "hmm, something didn't go right...sorry! try again if you must")
+		return
+	}
+	l.HostID = host.ID
+	if err := DB.CreateLFG(l, time.Duration(minutes)*time.Minute); err != nil {
+		respondEphemeral(s, i, lfgErrorMessage(err))
+		return
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{lfgEmbed(l, time.Now())},
+			Components: lfgComponents(l, time.Now()),
+		},
+	})
+	if err != nil {
+		Logger.With(zap.Error(err), zap.Int("lfg", l.ID)).Error("unable to post lfg")
+		return
+	}
+	msg, err := s.InteractionResponse(i.Interaction)
+	if err != nil {
+		Logger.With(zap.Error(err), zap.Int("lfg", l.ID)).Error("unable to find lfg message")
+		return
+	}
+	if err := DB.SetLFGMessage(l.ID, msg.ChannelID, msg.ID); err != nil {
+		Logger.With(zap.Error(err), zap.Int("lfg", l.ID)).Error("unable to record lfg message")
+	}
+}
+
+// slashLFGComponentHandler handles the join, leave and close buttons on an entry
+func (d *DiscordAPI) slashLFGComponentHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	// lfg:join|leave|close:id
+	customID := i.MessageComponentData().CustomID
+	parts := strings.Split(customID, ":")
+	if len(parts) != 3 {
+		Logger.With(zap.String("button_id", customID)).Error("unknown lfg button")
+		return
+	}
+	id, err := strconv.Atoi(parts[2])
+	if err != nil {
+		Logger.With(zap.String("button_id", customID)).Error("unknown lfg button")
+		return
+	}
+	member, err := interactionMember(i)
+	if err != nil {
+		Logger.With(zap.Error(err)).Error("unable to find member data")
+		respondEphemeral(s, i, "hmm, something didn't go right...sorry! try again if you must")
+		return
+	}
+
+	var l *db.LFG
+	switch parts[1] {
+	case "join":
+		l, err = DB.JoinLFG(id, member.ID)
+	case "leave":
+		l, err = DB.LeaveLFG(id, member.ID)
+	case "close":
+		admin, _ := IsUserIDAdmin(i.Member.User.ID)
+		l, err = DB.CloseLFG(id, member.ID, admin)
+	default:
+		Logger.With(zap.String("button_id", customID)).Error("unknown lfg button")
+		return
+	}
+	if err != nil {
+		respondEphemeral(s, i, lfgErrorMessage(err))
+		return
+	}
+
+	// Discord needs an answer within 3 seconds, so the message is updated first
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{lfgEmbed(l, time.Now())},
+			Components: lfgComponents(l, time.Now()),
+		},
+	})
+	if err != nil {
+		Logger.With(zap.Error(err), zap.Int("lfg", l.ID)).Error("unable to update lfg")
+	}
+
+	// a join which takes the last place fills the group. Its voice channel is added to the message once it exists
+	if parts[1] == "join" && l.FilledAt != nil {
+		if err := d.PostLFGFilledMessage(l); err != nil {
+			Logger.With(zap.Error(err), zap.Int("lfg", l.ID)).Error("unable to announce full lfg")
+		}
+		if l.VoiceChannelID != "" && l.MessageID != "" {
+			if err := d.PostLFGMessage(l); err != nil {
+				Logger.With(zap.Error(err), zap.Int("lfg", l.ID)).Error("unable to update lfg")
+			}
+		}
+	}
+}
+
+// lfgErrorMessage is what a member is told when they can't create, join, leave or close an entry
+func lfgErrorMessage(err error) string {
+	var dbErr *db.Error
+	if errors.As(err, &dbErr) {
+		return dbErr.Message
+	}
+	Logger.With(zap.Error(err)).Error("lfg change failed")
+	return "hmm, something didn't go right...sorry! try again if you must"
+}
+
+// PostLFGMessage posts an entry made through the API to the LFG channel, or updates the message showing it
+func (d *DiscordAPI) PostLFGMessage(l *db.LFG) error {
+	if d.discord == nil {
+		return fmt.Errorf("discord API not connected")
+	}
+	now := time.Now()
+	if l.MessageID != "" {
+		_, err := d.discord.ChannelMessageEditComplex(&discordgo.MessageEdit{
+			ID:         l.MessageID,
+			Channel:    l.ChannelID,
+			Embeds:     []*discordgo.MessageEmbed{lfgEmbed(l, now)},
+			Components: lfgComponents(l, now),
+		})
+		return err
+	}
+	if d.Config.LFGChannelId == "" {
+		return fmt.Errorf("lfg channel id not configured")
+	}
+	msg, err := d.discord.ChannelMessageSendComplex(d.Config.LFGChannelId, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{lfgEmbed(l, now)},
+		Components: lfgComponents(l, now),
+	})
+	if err != nil {
+		return err
+	}
+	l.ChannelID, l.MessageID = msg.ChannelID, msg.ID
+	return DB.SetLFGMessage(l.ID, msg.ChannelID, msg.ID)
+}
+
+// PostLFGFilledMessage pings everyone in a group which just filled, making a temporary voice channel for them
+// first when lfgVoiceCategoryId is configured
+func (d *DiscordAPI) PostLFGFilledMessage(l *db.LFG) error {
+	if d.discord == nil {
+		return fmt.Errorf("discord API not connected")
+	}
+	if d.Config.LFGVoiceCategoryId != "" && l.VoiceChannelID == "" {
+		voice, err := d.discord.GuildChannelCreateComplex(d.Config.GuildId, discordgo.GuildChannelCreateData{
+			Name:      lfgVoiceChannelName(l.Game),
+			Type:      discordgo.ChannelTypeGuildVoice,
+			UserLimit: l.Size,
+			ParentID:  d.Config.LFGVoiceCategoryId,
+		})
+		if err != nil {
+			Logger.With(zap.Error(err), zap.Int("lfg", l.ID)).Error("unable to make lfg voice channel")
+		} else if err := DB.SetLFGVoiceChannel(l.ID, voice.ID); err != nil {
+			Logger.With(zap.Error(err), zap.Int("lfg", l.ID)).Error("unable to record lfg voice channel")
+		} else {
+			l.VoiceChannelID = voice.ID
+		}
+	}
+	channelID := l.ChannelID
+	if channelID == "" {
+		channelID = d.Config.LFGChannelId
+	}
+	if channelID == "" {
+		return fmt.Errorf("lfg channel id not configured")
+	}
+	_, err := d.discord.ChannelMessageSend(channelID, lfgFilledMessage(l))
+	return err
+}
+
+// MindLFG closes entries as they expire, and removes their voice channels once nobody is left in them
+func (d *DiscordAPI) MindLFG() {
+	go func() {
+		for range time.Tick(time.Minute) {
+			d.expireLFGs()
+		}
+	}()
+}
+
+func (d *DiscordAPI) expireLFGs() {
+	now := time.Now()
+	expired, err := DB.ExpireLFGs(now)
+	if err != nil {
+		Logger.Error("unable to expire lfgs", zap.Error(err))
+		return
+	}
+	for _, l := range expired {
+		if l.MessageID == "" {
+			continue
+		}
+		if err := d.PostLFGMessage(l); err != nil {
+			Logger.Error("unable to update expired lfg", zap.Int("lfg", l.ID), zap.Error(err))
+		}
+	}
+
+	ended, err := DB.EndedLFGVoiceChannels(now)
+	if err != nil {
+		Logger.Error("unable to list lfg voice channels", zap.Error(err))
+		return
+	}
+	for _, l := range ended {
+		if d.voiceChannelOccupied(l.VoiceChannelID) {
+			continue
+		}
+		if _, err := d.discord.ChannelDelete(l.VoiceChannelID); err != nil {
+			var restErr *discordgo.RESTError
+			if !errors.As(err, &restErr) || restErr.Response == nil || restErr.Response.StatusCode != 404 {
+				Logger.Error("unable to remove lfg voice channel", zap.Int("lfg", l.ID), zap.String("channel", l.VoiceChannelID), zap.Error(err))
+				continue
+			}
+		}
+		if err := DB.SetLFGVoiceChannel(l.ID, ""); err != nil {
+			Logger.Error("unable to forget lfg voice channel", zap.Int("lfg", l.ID), zap.Error(err))
+		}
+	}
+}
+
+// voiceChannelOccupied reports whether anyone is in a voice channel, going by the session state
+func (d *DiscordAPI) voiceChannelOccupied(channelID string) bool {
+	guild, err := d.discord.State.Guild(d.Config.GuildId)
+	if err != nil {
+		return false
+	}
+	d.discord.State.RLock()
+	defer d.discord.State.RUnlock()
+	for _, vs := range guild.VoiceStates {
+		if vs.ChannelID == channelID {
+			return true
+		}
+	}
+	return false
+}
+
+// lfgStatus describes where an entry stands as of now
+func lfgStatus(l *db.LFG, now time.Time) string {
+	switch {
+	case l.FilledAt != nil && now.Before(l.ExpiresAt) && l.ClosedAt == nil:
+		return "Group full, have fun!"
+	case l.FilledAt != nil:
+		return "This group filled up"
+	case l.ClosedAt != nil && l.ClosedAt.Before(l.ExpiresAt):
+		return "Closed by the host"
+	case !now.Before(l.ExpiresAt) || l.ClosedAt != nil:
+		return "Expired"
+	}
+	need := l.Size - len(l.Members)
+	if need == 1 {
+		return "Looking for 1 more player"
+	}
+	return fmt.Sprintf("Looking for %d more players", need)
+}
+
+// lfgEmbed shows an entry, who has joined it and when it expires
+func lfgEmbed(l *db.LFG, now time.Time) *discordgo.MessageEmbed {
+	var players []string
+	for _, m := range l.Members {
+		who := m.Name
+		if m.Discord != "" {
+			who = fmt.Sprintf("<@%s>", m.Discord)
+		}
+		if m.MemberID == l.HostID {
+			who += " (host)"
+		}
+		players = append(players, who)
+	}
+	if len(players) == 0 {
+		players = append(players, "nobody yet")
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("🎮 LFG: %s", l.Game),
+		Description: lfgStatus(l, now),
+		Color:       0x99AAB5,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: fmt.Sprintf("Players (%d/%d)", len(l.Members), l.Size), Value: strings.Join(players, "\n")},
+		},
+		Footer: &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("LFG #%d", l.ID)},
+	}
+	if l.Open(now) {
+		embed.Color = 0x57F287
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Expires", Value: fmt.Sprintf("<t:%d:R>", l.ExpiresAt.Unix()), Inline: true})
+	}
+	if l.VoiceChannelID != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Voice", Value: fmt.Sprintf("<#%s>", l.VoiceChannelID), Inline: true})
+	}
+	return embed
+}
+
+// lfgComponents are the buttons on an open entry. Entries which filled, closed or expired have none
+func lfgComponents(l *db.LFG, now time.Time) []discordgo.MessageComponent {
+	if !l.Open(now) {
+		return []discordgo.MessageComponent{}
+	}
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{Label: "Join", Style: discordgo.PrimaryButton, CustomID: fmt.Sprintf("lfg:join:%d", l.ID)},
+				discordgo.Button{Label: "Leave", Style: discordgo.SecondaryButton, CustomID: fmt.Sprintf("lfg:leave:%d", l.ID)},
+				discordgo.Button{Label: "Close", Style: discordgo.DangerButton, CustomID: fmt.Sprintf("lfg:close:%d", l.ID)},
+			},
+		},
+	}
+}
+
+// lfgFilledMessage pings everyone in a full group
+func lfgFilledMessage(l *db.LFG) string {
+	var mentions []string
+	for _, m := range l.Members {
+		if m.Discord != "" {
+			mentions = append(mentions, fmt.Sprintf("<@%s>", m.Discord))
+		} else {
+			mentions = append(mentions, m.Name)
+		}
+	}
+	message := fmt.Sprintf("🎮 %s, your **%s** group is full!", strings.Join(mentions, " "), l.Game)
+	if l.VoiceChannelID != "" {
+		message += fmt.Sprintf(" Jump in <#%s>", l.VoiceChannelID)
+	}
+	return message
+}
+
+// lfgVoiceChannelName names a group's voice channel after its game, within Discord's 100 character limit
+func lfgVoiceChannelName(game string) string {
+	name := "LFG " + game
+	if r := []rune(name); len(r) > 100 {
+		name = string(r[:100])
+	}
+	return name
+}
@@ -0,0 +1,45 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FederationOfFathers/dashboard/db"
+)
+
+func TestLFGStatus(t *testing.T) {
+	now := time.Date(2026, time.October, 19, 20, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Minute)
+	members := []*db.LFGMember{{MemberID: 1, Discord: "10"}, {MemberID: 2, Discord: "20"}}
+	for _, test := range []struct {
+		name    string
+		lfg     db.LFG
+		status  string
+		buttons bool
+	}{
+		{"one more", db.LFG{Size: 3, Members: members, ExpiresAt: now.Add(time.Hour)}, "Looking for 1 more player", true},
+		{"more", db.LFG{Size: 5, Members: members, ExpiresAt: now.Add(time.Hour)}, "Looking for 3 more players", true},
+		{"full", db.LFG{Size: 2, Members: members, ExpiresAt: now.Add(time.Hour), FilledAt: &earlier}, "Group full, have fun!", false},
+		{"full then expired", db.LFG{Size: 2, Members: members, ExpiresAt: earlier, FilledAt: &earlier, ClosedAt: &earlier}, "This group filled up", false},
+		{"closed", db.LFG{Size: 3, Members: members, ExpiresAt: now.Add(time.Hour), ClosedAt: &earlier}, "Closed by the host", false},
+		{"expired", db.LFG{Size: 3, Members: members, ExpiresAt: earlier, ClosedAt: &earlier}, "Expired", false},
+	} {
+		if got := lfgStatus(&test.lfg, now); got != test.status {
+			t.Errorf("%s: expected status %q, got %q", test.name, test.status, got)
+		}
+		if got := len(lfgComponents(&test.lfg, now)) > 0; got != test.buttons {
+			t.Errorf("%s: expected buttons %v, got %v", test.name, test.buttons, got)
+		}
+	}
+}
+
+func TestLFGFilledMessage(t *testing.T) {
+	l := &db.LFG{
+		Game:           "Apex Legends",
+		Members:        []*db.LFGMember{{MemberID: 1, Discord: "10"}, {MemberID: 2, Name: "Sam"}},
+		VoiceChannelID: "99",
+	}
+	if got, want := lfgFilledMessage(l), "🎮 <@10> Sam, your **Apex Legends** group is full! Jump in <#99>"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,219 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/FederationOfFathers/dashboard/roster"
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+// registerSlashProfile registers the /profile set/clear/show/whois commands for the bot
+func (d *DiscordAPI) registerSlashProfile() {
+
+	var platformChoices []*discordgo.ApplicationCommandOptionChoice
+	for _, p := range db.Platforms {
+		platformChoices = append(platformChoices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  p.Label,
+			Value: p.Key,
+		})
+	}
+	platformOption := &discordgo.ApplicationCommandOption{
+		Name:        "platform",
+		Description: "which gaming platform",
+		Required:    true,
+		Type:        discordgo.ApplicationCommandOptionString,
+		Choices:     platformChoices,
+	}
+
+	profileCommand := &discordgo.ApplicationCommand{
+		Name:        "profile",
+		Description: "Manage the gamertags and platform ids on your profile",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "set",
+				Description: "Adds or replaces one of your platform ids",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					platformOption,
+					{
+						Name:        "id",
+						Description: "your gamertag, friend code or id on that platform",
+						Required:    true,
+						Type:        discordgo.ApplicationCommandOptionString,
+					},
+				},
+			},
+			{
+				Name:        "clear",
+				Description: "Removes one of your platform ids",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options:     []*discordgo.ApplicationCommandOption{platformOption},
+			},
+			{
+				Name:        "show",
+				Description: "Shows the platform ids on your profile, or someone else's",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        "member",
+						Description: "whose profile to show",
+						Type:        discordgo.ApplicationCommandOptionUser,
+					},
+				},
+			},
+			{
+				Name:        "whois",
+				Description: "Finds the member with a gamertag or platform id",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					platformOption,
+					{
+						Name:        "id",
+						Description: "the gamertag, friend code or id to look for",
+						Required:    true,
+						Type:        discordgo.ApplicationCommandOptionString,
+					},
+				},
+			},
+		},
+	}
+
+	if _, err := d.discord.ApplicationCommandCreate(d.discord.State.User.ID, d.Config.GuildId, profileCommand); err != nil {
+		Logger.With(zap.Error(err)).Error("unable to register profile slash command")
+	}
+}
+
+// slashProfileHandler handles the /profile commands
+func (d *DiscordAPI) slashProfileHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+
+	commandData := i.ApplicationCommandData()
+	if len(commandData.Options) == 0 {
+		respondEphemeral(s, i, "use `/profile set`, `/profile clear`, `/profile show` or `/profile whois`")
+		return
+	}
+	subCommand := commandData.Options[0]
+	options := map[string]*discordgo.ApplicationCommandInteractionDataOption{}
+	for _, option := range subCommand.Options {
+		options[option.Name] = option
+	}
+
+	switch subCommand.Name {
+	case "set", "clear":
+		platform, _ := db.PlatformByKey(options["platform"].StringValue())
+		var id string
+		if subCommand.Name == "set" {
+			id = options["id"].StringValue()
+		}
+
+		m, err := interactionMember(i)
+		if err != nil {
+			Logger.With(zap.Error(err), zap.String("discordID", i.Member.User.ID)).Error("unable to find member data")
+			respondEphemeral(s, i, "hmm, something didn't go right...sorry! try again if you must")
+			return
+		}
+		if err := m.SetPlatformID(platform.Key, id); err != nil {
+			respondEphemeral(s, i, err.Error())
+			return
+		}
+		if err := m.SavePlatformIDs(); err != nil {
+			Logger.With(zap.Error(err), zap.Int("memberID", m.ID)).Error("unable to save platform id")
+			respondEphemeral(s, i, "hmm, something didn't go right...sorry! try again if you must")
+			return
+		}
+		if id == "" {
+			respondEphemeral(s, i, fmt.Sprintf("OK, your %s id has been removed", platform.Label))
+		} else {
+			respondEphemeral(s, i, fmt.Sprintf("OK, your %s id is now `%s`", platform.Label, m.PlatformID(platform.Key)))
+		}
+
+	case "show":
+		discordID := i.Member.User.ID
+		if option, ok := options["member"]; ok {
+			discordID = option.UserValue(nil).ID
+		}
+		m, err := DB.MemberByDiscordID(discordID)
+		if errors.Is(err, db.ErrNotFound) {
+			respondEphemeral(s, i, fmt.Sprintf("<@%s> has not set up a profile yet", discordID))
+			return
+		} else if err != nil {
+			Logger.With(zap.Error(err), zap.String("discordID", discordID)).Error("unable to find member data")
+			respondEphemeral(s, i, "hmm, something didn't go right...sorry! try again if you must")
+			return
+		}
+		visible, err := profileVisibility(m, i.Member.User.ID)
+		if err != nil {
+			Logger.With(zap.Error(err), zap.Int("memberID", m.ID)).Error("unable to read member privacy")
+			respondEphemeral(s, i, "hmm, something didn't go right...sorry! try again if you must")
+			return
+		}
+		respondEphemeral(s, i, profileSummary(discordID, m, visible))
+
+	case "whois":
+		platform, _ := db.PlatformByKey(options["platform"].StringValue())
+		id := options["id"].StringValue()
+		m, err := DB.MemberByPlatformID(platform.Key, id)
+		if err == nil {
+			var visible func(string) bool
+			if visible, err = profileVisibility(m, i.Member.User.ID); err == nil && !visible(platform.Key) {
+				// a hidden id answers the same as an unknown one, so it can't be looked up
+				err = db.ErrNotFound
+			}
+		}
+		if errors.Is(err, db.ErrNotFound) {
+			respondEphemeral(s, i, fmt.Sprintf("nobody has `%s` as their %s id", id, platform.Label))
+			return
+		} else if err != nil {
+			Logger.With(zap.Error(err), zap.String("platform", platform.Key), zap.String("id", id)).Error("unable to look up platform id")
+			respondEphemeral(s, i, "hmm, something didn't go right...sorry! try again if you must")
+			return
+		}
+		who := m.Name
+		if m.Discord != "" {
+			who = fmt.Sprintf("<@%s>", m.Discord)
+		}
+		respondEphemeral(s, i, fmt.Sprintf("`%s` on %s is %s", m.PlatformID(platform.Key), platform.Label, who))
+
+	default:
+		respondEphemeral(s, i, "use `/profile set`, `/profile clear`, `/profile show` or `/profile whois`")
+	}
+}
+
+// profileVisibility returns which of a member's platform ids the Discord user asking may see, following the
+// member's privacy settings the same way the API does
+func profileVisibility(owner *db.Member, viewerDiscordID string) (func(key string) bool, error) {
+	settings, err := DB.MemberPrivacySettings(owner.ID)
+	if err != nil {
+		return nil, err
+	}
+	var viewerID int
+	if viewer, err := DB.MemberByDiscordID(viewerDiscordID); err == nil {
+		viewerID = viewer.ID
+	} else if !errors.Is(err, db.ErrNotFound) {
+		return nil, err
+	}
+	self := owner.Discord != "" && owner.Discord == viewerDiscordID
+	admin, _ := IsUserIDAdmin(viewerDiscordID)
+	return func(key string) bool {
+		visibility := settings[key]
+		friend := visibility == db.VisibilityFriends && roster.IsFriend(owner.ID, viewerID)
+		return visibility.VisibleTo(self, friend, admin)
+	}, nil
+}
+
+// profileSummary lists the platform ids a member has set which visible allows
+func profileSummary(discordID string, m *db.Member, visible func(key string) bool) string {
+	var lines []string
+	for _, p := range db.Platforms {
+		if id := m.PlatformID(p.Key); id != "" && visible(p.Key) {
+			lines = append(lines, fmt.Sprintf("**%s**: `%s`", p.Label, id))
+		}
+	}
+	if len(lines) == 0 {
+		return fmt.Sprintf("<@%s> has not added any platform ids yet. Use `/profile set` to add yours", discordID)
+	}
+	return fmt.Sprintf("<@%s>\n%s", discordID, strings.Join(lines, "\n"))
+}
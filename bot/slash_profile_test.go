@@ -0,0 +1,16 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/FederationOfFathers/dashboard/db"
+)
+
+func TestProfileSummaryHidesPrivateIDs(t *testing.T) {
+	m := &db.Member{Xbl: "Public Tag", Steam: "76561197960287930"}
+	got := profileSummary("1", m, func(key string) bool { return key != "steam" })
+	if !strings.Contains(got, "Public Tag") || strings.Contains(got, m.Steam) {
+		t.Errorf("expected only the visible id, got %q", got)
+	}
+}
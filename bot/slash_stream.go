@@ -1,14 +1,13 @@
 package bot
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/FederationOfFathers/dashboard/db"
 	"github.com/bwmarrin/discordgo"
 	"github.com/gocolly/colly/v2"
-	"github.com/jinzhu/gorm"
 	"go.uber.org/zap"
 	"google.golang.org/api/youtube/v3"
 )
@@ -156,7 +155,7 @@ func (d *DiscordAPI) slashStreamHandler(s *discordgo.Session, i *discordgo.Inter
 		Logger.With(zap.String("discordID", i.Member.User.ID), zap.Int("memberID", m.ID)).Info("removing streams")
 
 		stream, err := DB.StreamByMemberID(m.ID)
-		if err != nil && err != gorm.ErrRecordNotFound {
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
 
 			Logger.With(zap.Error(err), zap.Int("memberID", m.ID)).Error("could not retrieve member stream")
 			return
@@ -264,29 +263,14 @@ func (d *DiscordAPI) slashStreamComponentHandler(s *discordgo.Session, i *discor
 		streamUsername := componentParts[4]
 
 		// get or create the member record
-		m, err := DB.MemberByDiscordID(i.Member.User.ID)
+		m, err := interactionMember(i)
 		if err != nil {
-			if err == gorm.ErrRecordNotFound || err == sql.ErrNoRows {
-				Logger.Info("adding new member")
-
-				// new member
-				m = db.NewMember(DB)
-				m.Discord = i.Member.User.ID
-				m.Name = i.Member.Nick
-				m.Save()
-				newM, err := DB.MemberByDiscordID(i.Member.User.ID)
-				if err != nil {
-					Logger.With(zap.Error(err)).Error("unable to retrieve newly created member")
-					return
-				}
-				m = newM
-			}
 			Logger.With(zap.Error(err)).Error("unable to find member data")
 			return
 		}
 
 		stream, err := DB.StreamByMemberID(m.ID)
-		if err != nil && err != gorm.ErrRecordNotFound {
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
 
 			Logger.With(zap.Error(err), zap.Int("memberID", m.ID)).Error("could not retrieve member stream")
 			return
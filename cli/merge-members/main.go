@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/apokalyptik/cfg"
+	"go.uber.org/zap"
+)
+
+var from int
+var into int
+var actor int
+var dryRun bool
+var mysqlURI string
+
+func main() {
+	flag.IntVar(&from, "from", from, "id of the member to merge and delete")
+	flag.IntVar(&into, "into", into, "id of the member to keep")
+	flag.IntVar(&actor, "actor", actor, "id of the admin doing the merge, for the audit log")
+	flag.BoolVar(&dryRun, "dry-run", dryRun, "only show what would change")
+	dcfg := cfg.New("cfg-db")
+	dcfg.StringVar(&mysqlURI, "mysql", mysqlURI, "MySQL Connection URI")
+	cfg.Parse()
+
+	if from == 0 || into == 0 {
+		fmt.Fprintln(os.Stderr, "-from and -into are required")
+		os.Exit(2)
+	}
+
+	db.Logger, _ = zap.NewDevelopment()
+	DB := db.New("mysql", mysqlURI)
+
+	var plan *db.MergePlan
+	var err error
+	if dryRun {
+		plan, err = DB.PlanMemberMerge(from, into)
+	} else {
+		plan, err = DB.MergeMembers(from, into, &db.AuditLog{ActorID: actor, Method: "CLI", URI: "merge-members"})
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	out, _ := json.MarshalIndent(plan, "", "  ")
+	fmt.Println(string(out))
+}
@@ -0,0 +1,97 @@
+package steam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the Steam Web API
+const DefaultBaseURL = "https://api.steampowered.com"
+
+// Client calls the Steam Web API. BaseURL may point elsewhere, such as at a stub server in tests
+type Client struct {
+	BaseURL string
+	Key     string
+	HTTP    *http.Client
+}
+
+// Game is a game in a Steam library. Playtimes are in minutes
+type Game struct {
+	AppID           int    `json:"appid"`
+	Name            string `json:"name"`
+	PlaytimeForever int    `json:"playtime_forever"`
+	Playtime2Weeks  int    `json:"playtime_2weeks"`
+	LastPlayed      int64  `json:"rtime_last_played"`
+	IconHash        string `json:"img_icon_url"`
+}
+
+// LastPlayedTime returns when the game was last played, or the zero time if Steam did not say
+func (g Game) LastPlayedTime() time.Time {
+	if g.LastPlayed <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(g.LastPlayed, 0)
+}
+
+// New returns a client for the Steam Web API with a request timeout
+func New(key string) *Client {
+	return &Client{
+		BaseURL: DefaultBaseURL,
+		Key:     key,
+		HTTP:    &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+type gamesResponse struct {
+	Response struct {
+		Games []Game `json:"games"`
+	} `json:"response"`
+}
+
+// OwnedGames returns the games in a player's library. Private libraries come back empty
+func (c *Client) OwnedGames(ctx context.Context, steamID string) ([]Game, error) {
+	return c.games(ctx, "IPlayerService/GetOwnedGames/v1/", url.Values{
+		"steamid":                   {steamID},
+		"include_appinfo":           {"1"},
+		"include_played_free_games": {"1"},
+	})
+}
+
+// RecentlyPlayedGames returns the games a player has played in the last two weeks
+func (c *Client) RecentlyPlayedGames(ctx context.Context, steamID string) ([]Game, error) {
+	return c.games(ctx, "IPlayerService/GetRecentlyPlayedGames/v1/", url.Values{"steamid": {steamID}})
+}
+
+func (c *Client) games(ctx context.Context, method string, query url.Values) ([]Game, error) {
+	query.Set("key", c.Key)
+	query.Set("format", "json")
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimRight(c.BaseURL, "/")+"/"+method+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("steam %s returned %s", method, resp.Status)
+	}
+	var body gamesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decoding steam %s: %w", method, err)
+	}
+	if body.Response.Games == nil {
+		return []Game{}, nil
+	}
+	return body.Response.Games, nil
+}
@@ -0,0 +1,71 @@
+package steam
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func stub(t *testing.T) *Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path + "?" + r.URL.Query().Get("steamid") {
+		case "/IPlayerService/GetOwnedGames/v1/?76561197960287930":
+			if r.URL.Query().Get("include_appinfo") != "1" {
+				t.Error("expected app info to be requested")
+			}
+			w.Write([]byte(`{"response":{"game_count":2,"games":[{"appid":1172470,"name":"Apex Legends","playtime_forever":600,"rtime_last_played":1760000000},{"appid":620,"name":"Portal 2","playtime_forever":0}]}}`))
+		case "/IPlayerService/GetRecentlyPlayedGames/v1/?76561197960287930":
+			w.Write([]byte(`{"response":{"total_count":1,"games":[{"appid":1172470,"name":"Apex Legends","playtime_2weeks":90,"playtime_forever":600}]}}`))
+		case "/IPlayerService/GetOwnedGames/v1/?76561197960287931":
+			w.Write([]byte(`{"response":{}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	c := New("secret")
+	c.BaseURL = server.URL
+	return c
+}
+
+func TestOwnedGames(t *testing.T) {
+	c := stub(t)
+	games, err := c.OwnedGames(context.Background(), "76561197960287930")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 2 || games[0].AppID != 1172470 || games[0].Name != "Apex Legends" || games[0].LastPlayedTime().Unix() != 1760000000 {
+		t.Errorf("unexpected games %+v", games)
+	}
+	if !games[1].LastPlayedTime().IsZero() {
+		t.Errorf("expected an unplayed game to have no last played time, got %s", games[1].LastPlayedTime())
+	}
+
+	private, err := c.OwnedGames(context.Background(), "76561197960287931")
+	if err != nil || len(private) != 0 {
+		t.Errorf("expected a private library to be empty, got %v %v", private, err)
+	}
+}
+
+func TestRecentlyPlayedGames(t *testing.T) {
+	games, err := stub(t).RecentlyPlayedGames(context.Background(), "76561197960287930")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 1 || games[0].Playtime2Weeks != 90 {
+		t.Errorf("unexpected games %+v", games)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	c := stub(t)
+	c.Key = "wrong"
+	if _, err := c.OwnedGames(context.Background(), "76561197960287930"); err == nil {
+		t.Error("expected an error for a rejected key")
+	}
+}
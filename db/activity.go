@@ -0,0 +1,119 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+// MemberActivity counts what a member did in one hour from one source, such as Discord messages or API requests
+type MemberActivity struct {
+	ID       uint      `gorm:"primary_key" json:"-"`
+	MemberID int       `gorm:"not null;unique_index:member_activity_hour" json:"memberID"`
+	Hour     time.Time `gorm:"not null;unique_index:member_activity_hour;index" json:"hour"`
+	Source   string    `gorm:"type:varchar(32);not null;default:'';unique_index:member_activity_hour" json:"source"`
+	Count    int       `gorm:"not null;default:0" json:"count"`
+}
+
+// ActivityKey identifies one member's activity from one source in one hour
+type ActivityKey struct {
+	MemberID int
+	Hour     time.Time
+	Source   string
+}
+
+// ActivitySummary describes how active a member has been recently
+type ActivitySummary struct {
+	// LastActive is when the member was last seen, nil if never
+	LastActive *time.Time `json:"lastActive"`
+	// ActiveDays30 and ActiveDays90 count the days with any activity in the last 30 and 90 days
+	ActiveDays30 int `json:"activeDays30"`
+	ActiveDays90 int `json:"activeDays90"`
+	// Events30 and Events90 count all activity in the last 30 and 90 days, by source
+	Events30 map[string]int `json:"events30"`
+	Events90 map[string]int `json:"events90"`
+}
+
+// RecordActivity adds batched activity counts to the hourly activity table and moves each member's Seen up to seen
+func (d *DB) RecordActivity(counts map[ActivityKey]int, seen time.Time) error {
+	if len(counts) == 0 {
+		return nil
+	}
+	var values []string
+	var args []interface{}
+	var members = map[int]bool{}
+	for k, count := range counts {
+		values = append(values, "(?,?,?,?)")
+		args = append(args, k.MemberID, k.Hour, k.Source, count)
+		members[k.MemberID] = true
+	}
+	err := d.Exec(
+		"INSERT INTO member_activities (member_id,hour,source,count) VALUES "+strings.Join(values, ",")+
+			" ON DUPLICATE KEY UPDATE count = count + VALUES(count)",
+		args...,
+	).Error
+	if err != nil {
+		return err
+	}
+	var ids = make([]int, 0, len(members))
+	for id := range members {
+		ids = append(ids, id)
+	}
+	return d.Exec("UPDATE members SET seen = ? WHERE id IN (?) AND seen < ?", seen.Unix(), ids, seen.Unix()).Error
+}
+
+// MemberIDsByDiscordIDs maps Discord ids to member ids. Discord ids without a member are left out
+func (d *DB) MemberIDsByDiscordIDs(discordIDs []string) (map[string]int, error) {
+	var rval = map[string]int{}
+	if len(discordIDs) == 0 {
+		return rval, nil
+	}
+	var members []*Member
+	if err := d.Select("id, discord").Where("discord IN (?)", discordIDs).Find(&members).Error; err != nil {
+		return rval, err
+	}
+	for _, m := range members {
+		rval[m.Discord] = m.ID
+	}
+	return rval, nil
+}
+
+// MemberActivitySummary summarizes a member's activity over the last 30 and 90 days
+func (d *DB) MemberActivitySummary(member *Member) (*ActivitySummary, error) {
+	var rval = &ActivitySummary{
+		Events30: map[string]int{},
+		Events90: map[string]int{},
+	}
+	if member.Seen > 0 {
+		seen := time.Unix(int64(member.Seen), 0)
+		rval.LastActive = &seen
+	}
+	now := time.Now()
+	since30 := now.AddDate(0, 0, -30)
+	since90 := now.AddDate(0, 0, -90)
+
+	rows, err := d.Raw("SELECT hour, source, count FROM member_activities WHERE member_id = ? AND hour >= ?", member.ID, since90).Rows()
+	if err != nil {
+		return rval, err
+	}
+	defer rows.Close()
+	var days30 = map[string]bool{}
+	var days90 = map[string]bool{}
+	for rows.Next() {
+		var hour time.Time
+		var source string
+		var count int
+		if err := rows.Scan(&hour, &source, &count); err != nil {
+			return rval, err
+		}
+		day := hour.Format("2006-01-02")
+		days90[day] = true
+		rval.Events90[source] += count
+		if !hour.Before(since30) {
+			days30[day] = true
+			rval.Events30[source] += count
+		}
+	}
+	rval.ActiveDays30 = len(days30)
+	rval.ActiveDays90 = len(days90)
+	return rval, rows.Err()
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditLog records a privileged action one member took on another member's data
+type AuditLog struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+	// ActorID is the member who took the action
+	ActorID int `gorm:"index" json:"actorID"`
+	// TargetID is the member whose data was changed
+	TargetID int `gorm:"index" json:"targetID"`
+	// Action is what was done, such as member.update or stream.delete
+	Action string `gorm:"type:varchar(191);not null;default:'';index" json:"action"`
+	// Before and After hold JSON describing the changed values
+	Before    json.RawMessage `gorm:"type:text" json:"before"`
+	After     json.RawMessage `gorm:"type:text" json:"after"`
+	IP        string          `gorm:"type:varchar(64);not null;default:''" json:"ip"`
+	UserAgent string          `gorm:"type:varchar(255);not null;default:''" json:"userAgent"`
+	Method    string          `gorm:"type:varchar(16);not null;default:''" json:"method"`
+	URI       string          `gorm:"type:varchar(255);not null;default:''" json:"uri"`
+}
+
+// AuditFilter narrows the audit log. Zero values are not filtered on
+type AuditFilter struct {
+	ActorID  int
+	TargetID int
+	Action   string
+	Since    time.Time
+	Until    time.Time
+	Page     int
+	PerPage  int
+}
+
+// RecordAudit saves a new audit log entry
+func (d *DB) RecordAudit(a *AuditLog) error {
+	return d.Create(a).Error
+}
+
+// AuditLogs returns a page of audit log entries matching f, newest first, and the total number of matching entries
+func (d *DB) AuditLogs(f AuditFilter) ([]*AuditLog, int, error) {
+	q := d.Model(&AuditLog{})
+	if f.ActorID != 0 {
+		q = q.Where("actor_id = ?", f.ActorID)
+	}
+	if f.TargetID != 0 {
+		q = q.Where("target_id = ?", f.TargetID)
+	}
+	if f.Action != "" {
+		q = q.Where("action = ?", f.Action)
+	}
+	if !f.Since.IsZero() {
+		q = q.Where("created_at >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		q = q.Where("created_at < ?", f.Until)
+	}
+
+	var total int
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if f.PerPage < 1 {
+		f.PerPage = 50
+	}
+	if f.Page < 1 {
+		f.Page = 1
+	}
+	var entries = []*AuditLog{}
+	err := q.Order("id DESC").Offset((f.Page - 1) * f.PerPage).Limit(f.PerPage).Find(&entries).Error
+	return entries, total, err
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ErrNotFound is the kind of error returned when a requested record does not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrConflict is the kind of error returned when a change conflicts with the current state of a record
+var ErrConflict = errors.New("conflict")
+
+// ErrForbidden is the kind of error returned when a member may not make a change
+var ErrForbidden = errors.New("forbidden")
+
+// ErrInvalid is the kind of error returned when a value is not in the expected format
+var ErrInvalid = errors.New("invalid")
+
+// Error is a typed error returned from the db package. Kind is one of ErrNotFound, ErrConflict,
+// ErrForbidden or ErrInvalid, so callers can use errors.Is, and Code is a short machine readable reason
+// such as "event_full"
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func notFoundError(code, format string, args ...interface{}) error {
+	return &Error{Kind: ErrNotFound, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func conflictError(code, format string, args ...interface{}) error {
+	return &Error{Kind: ErrConflict, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func forbiddenError(code, format string, args ...interface{}) error {
+	return &Error{Kind: ErrForbidden, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func invalidError(code, format string, args ...interface{}) error {
+	return &Error{Kind: ErrInvalid, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// notFound converts gorm and sql "no rows" errors into a typed ErrNotFound error. Other errors are returned as is
+func notFound(err error, code, format string, args ...interface{}) error {
+	if err == gorm.ErrRecordNotFound || err == sql.ErrNoRows {
+		return notFoundError(code, format, args...)
+	}
+	return err
+}
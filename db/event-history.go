@@ -0,0 +1,83 @@
+package db
+
+import (
+	"time"
+)
+
+// EventAttendance is a member's slot in an event which has happened. Events and their slots are purged
+// shortly after they start, so this is the only record of who took part
+type EventAttendance struct {
+	ID        uint       `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time  `json:"-"`
+	EventID   uint       `gorm:"not null;unique_index:event_member" json:"eventID"`
+	MemberID  int        `gorm:"not null;unique_index:event_member;index" json:"memberID"`
+	Type      int        `gorm:"not null" json:"type"`
+	Title     string     `gorm:"type:varchar(191);not null;default:''" json:"title"`
+	When      *time.Time `json:"when"`
+}
+
+// EventTally counts the events a member has taken part in. Attended includes the events they hosted
+type EventTally struct {
+	Hosted   int
+	Attended int
+}
+
+// RecordEventAttendance keeps a record of the event's hosts and members. Alternates are left out. Members must be loaded
+func (d *DB) RecordEventAttendance(e *Event) error {
+	for _, eMember := range e.Members {
+		if eMember.Type == EventMemberTypeAlt {
+			continue
+		}
+		err := d.Exec(
+			"INSERT IGNORE INTO event_attendances (created_at,event_id,member_id,type,title,`when`) VALUES(NOW(),?,?,?,?,?)",
+			e.ID,
+			eMember.MemberID,
+			eMember.Type,
+			e.Title,
+			e.When,
+		).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillEventAttendance records the past events still in the events table, so the history includes what
+// happened before attendance was kept. Events already recorded are left alone, so it is safe to run every start
+func (d *DB) backfillEventAttendance() (int64, error) {
+	rval := d.Exec(
+		"INSERT IGNORE INTO event_attendances (created_at,event_id,member_id,type,title,`when`) "+
+			"SELECT NOW(), e.id, em.member_id, em.type, e.title, e.`when` "+
+			"FROM event_members em JOIN events e ON (em.event_id = e.id) "+
+			"WHERE em.deleted_at IS NULL AND e.deleted_at IS NULL AND em.type != ? AND em.member_id > 0 AND e.`when` <= NOW()",
+		EventMemberTypeAlt,
+	)
+	return rval.RowsAffected, rval.Error
+}
+
+// MemberEventTallies returns how many past events each member has hosted and attended, keyed by member id
+func (d *DB) MemberEventTallies() (map[int]*EventTally, error) {
+	var rval = map[int]*EventTally{}
+	rows, err := d.Raw("SELECT member_id, SUM(type = ?), COUNT(*) FROM event_attendances GROUP BY member_id", EventMemberTypeHost).Rows()
+	if err != nil {
+		return rval, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var memberID int
+		var tally EventTally
+		if err := rows.Scan(&memberID, &tally.Hosted, &tally.Attended); err != nil {
+			return rval, err
+		}
+		rval[memberID] = &tally
+	}
+	return rval, rows.Err()
+}
+
+// MemberIDsWhoStreamed returns the ids of members who have ever been seen live
+func (d *DB) MemberIDsWhoStreamed() ([]int, error) {
+	var ids []int
+	err := d.Model(&Stream{}).Where("twitch_start > 0 OR youtube_start > 0").Pluck("member_id", &ids).Error
+	return ids, err
+}
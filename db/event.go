@@ -133,14 +133,14 @@ func (e *Event) BeforeCreate() error {
 func (d *DB) EventChannelByID(id int) (*EventChannel, error) {
 	var eventChannel EventChannel
 	err := d.Where(id).First(&eventChannel).Error
-	return &eventChannel, err
+	return &eventChannel, notFound(err, "channel_not_found", "no event channel with id %d", id)
 }
 
 // EventChanneByChannelID returns an event channel the by discord channel id (snowflake)
 func (d *DB) EventChannelByChannelID(chID string) (*EventChannel, error) {
 	var eventChannel EventChannel
 	err := d.Where(&EventChannel{ID: chID}).First(&eventChannel).Error
-	return &eventChannel, err
+	return &eventChannel, notFound(err, "channel_not_found", "no event channel with channel id %s", chID)
 }
 
 // EventChannels gets all event channels in the DB, or an error
@@ -158,7 +158,7 @@ func (d *DB) EventByID(id int) (*Event, error) {
 	err := d.Raw("SELECT * FROM events WHERE events.id = ? LIMIT 1", id).Scan(event).Error
 
 	event.db = d
-	return event, err
+	return event, notFound(err, "event_not_found", "no event with id %d", id)
 }
 
 func (d *DB) EventMembers(event *Event) ([]*EventMember, error) {
@@ -174,14 +174,73 @@ func (d *DB) EventMemberByID(id uint) (*EventMember, error) {
 
 	err := d.Raw("SELECT * FROM event_members WHERE id = ? LIMIT 1", id).Scan(&member).Error
 
-	return &member, err
+	return &member, notFound(err, "event_member_not_found", "no event member with id %d", id)
 
 }
 
-func (d *DB) DeleteEventMemberByID(u uint) {
-	if err := d.Exec("DELETE FROM event_members WHERE id = ?", u).Error; err != nil {
+func (d *DB) DeleteEventMemberByID(u uint) error {
+	err := d.Exec("DELETE FROM event_members WHERE id = ?", u).Error
+	if err != nil {
 		Logger.Error("unable to delete event members", zap.Uint("id", u), zap.Error(err))
 	}
+	return err
+}
+
+// LeaveEvent removes the event member slot with the given id, which must belong to memberID
+func (d *DB) LeaveEvent(eventMemberID uint, memberID int) error {
+	eMember, err := d.EventMemberByID(eventMemberID)
+	if err != nil {
+		return err
+	}
+	if eMember.MemberID != memberID {
+		return forbiddenError("not_your_slot", "event member %d belongs to someone else", eventMemberID)
+	}
+	return d.DeleteEventMemberByID(eventMemberID)
+}
+
+// IsHost reports whether memberID is a host of the event. Members must be loaded
+func (e *Event) IsHost(memberID int) bool {
+	for _, eMember := range e.Members {
+		if eMember.MemberID == memberID && eMember.Type == EventMemberTypeHost {
+			return true
+		}
+	}
+	return false
+}
+
+// Join adds a slot in the event for memberID. Alternates may always join, but other members
+// may only join while the event has fewer than Need members. Members must be loaded
+func (e *Event) Join(memberID int, memberType int) error {
+	if memberType == EventMemberTypeMember && e.Need > 0 {
+		var filled int
+		for _, eMember := range e.Members {
+			if eMember.Type != EventMemberTypeAlt {
+				filled++
+			}
+		}
+		if filled >= e.Need {
+			return conflictError("event_full", "the event already has the %d members it needs", e.Need)
+		}
+	}
+	e.Members = append(e.Members, &EventMember{MemberID: memberID, Type: memberType})
+	return e.Save()
+}
+
+// DeleteEventAsMember deletes the event with the given id on behalf of memberID, who must be its host
+func (d *DB) DeleteEventAsMember(eventID int, memberID int) error {
+	event, err := d.EventByID(eventID)
+	if err != nil {
+		return err
+	}
+	if event.Members, err = d.EventMembers(event); err != nil {
+		return err
+	}
+	if !event.IsHost(memberID) {
+		return forbiddenError("not_event_host", "only the host may delete event %d", eventID)
+	}
+	Logger.Info("Deleting event", zap.Any("event", event))
+	d.DeleteEvent(*event)
+	return nil
 }
 
 // SaveEventChannel creates or saves an EventChannel
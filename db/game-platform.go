@@ -0,0 +1,136 @@
+package db
+
+import "strings"
+
+// Families group gaming platforms by maker or kind
+const (
+	FamilyXbox        = "xbox"
+	FamilyPlayStation = "playstation"
+	FamilyPC          = "pc"
+	FamilyNintendo    = "nintendo"
+	FamilyMobile      = "mobile"
+	FamilyVR          = "vr"
+)
+
+// PlatformFamilies lists every family a gaming platform may belong to
+var PlatformFamilies = []string{FamilyXbox, FamilyPlayStation, FamilyPC, FamilyNintendo, FamilyMobile, FamilyVR}
+
+// Codes of the gaming platforms every install starts with. 1 to 8 are the codes the platform scrapers have always
+// written to games
+const (
+	PlatformXbox360      = 1
+	PlatformXboxOne      = 2
+	PlatformPC           = 3
+	PlatformIOS          = 4
+	PlatformAndroid      = 5
+	PlatformMobile       = 6
+	PlatformGearVR       = 7
+	PlatformKindle       = 8
+	PlatformPS5          = 9
+	PlatformXboxSeriesXS = 10
+	PlatformSwitch       = 11
+	PlatformSteamDeck    = 12
+	PlatformQuest        = 13
+	PlatformSteam        = 14
+)
+
+// GamingPlatform is a system games are played on. ID is the code games and game_platforms refer to it by
+type GamingPlatform struct {
+	ID     int    `gorm:"primary_key;auto_increment:false" json:"id"`
+	Name   string `gorm:"type:varchar(191);not null;default:''" json:"name"`
+	Family string `gorm:"type:varchar(32);not null;default:''" json:"family"`
+	// Icon names the icon the UI shows for the platform
+	Icon string `gorm:"type:varchar(191);not null;default:''" json:"icon"`
+}
+
+// TableName keeps gaming platforms in platforms
+func (GamingPlatform) TableName() string {
+	return "platforms"
+}
+
+// gamingPlatformSeeds are added when missing. The names of 1 to 8 are the ones the games API has always used
+var gamingPlatformSeeds = []*GamingPlatform{
+	{ID: PlatformXbox360, Name: "Xbox360", Family: FamilyXbox, Icon: "xbox"},
+	{ID: PlatformXboxOne, Name: "XboxOne", Family: FamilyXbox, Icon: "xbox"},
+	{ID: PlatformPC, Name: "PC", Family: FamilyPC, Icon: "windows"},
+	{ID: PlatformIOS, Name: "iOS", Family: FamilyMobile, Icon: "apple"},
+	{ID: PlatformAndroid, Name: "Android", Family: FamilyMobile, Icon: "android"},
+	{ID: PlatformMobile, Name: "Mobile", Family: FamilyMobile, Icon: "mobile"},
+	{ID: PlatformGearVR, Name: "GearVR", Family: FamilyVR, Icon: "vr"},
+	{ID: PlatformKindle, Name: "Kindle", Family: FamilyMobile, Icon: "amazon"},
+	{ID: PlatformPS5, Name: "PS5", Family: FamilyPlayStation, Icon: "playstation"},
+	{ID: PlatformXboxSeriesXS, Name: "Series X|S", Family: FamilyXbox, Icon: "xbox"},
+	{ID: PlatformSwitch, Name: "Switch", Family: FamilyNintendo, Icon: "nintendo-switch"},
+	{ID: PlatformSteamDeck, Name: "Steam Deck", Family: FamilyPC, Icon: "steam"},
+	{ID: PlatformQuest, Name: "Quest", Family: FamilyVR, Icon: "meta"},
+	{ID: PlatformSteam, Name: "Steam", Family: FamilyPC, Icon: "steam"},
+}
+
+// seedGamingPlatforms adds the seed platforms, leaving any an admin has edited alone, and a placeholder for any
+// other code games already use so every game's platform can be described
+func (d *DB) seedGamingPlatforms() error {
+	for _, p := range gamingPlatformSeeds {
+		err := d.Exec("INSERT IGNORE INTO platforms (id, name, family, icon) VALUES(?,?,?,?)", p.ID, p.Name, p.Family, p.Icon).Error
+		if err != nil {
+			return err
+		}
+	}
+	return d.Exec(strings.Join([]string{
+		"INSERT IGNORE INTO platforms (id, name)",
+		"SELECT code, CONCAT('Platform ', code) FROM (",
+		"SELECT platform AS code FROM games WHERE platform > 0",
+		"UNION SELECT platform FROM game_platforms WHERE platform > 0",
+		") codes",
+	}, " ")).Error
+}
+
+// GamingPlatforms returns every gaming platform by code
+func (d *DB) GamingPlatforms() ([]*GamingPlatform, error) {
+	var platforms = []*GamingPlatform{}
+	err := d.Order("id").Find(&platforms).Error
+	return platforms, err
+}
+
+// gamingPlatformsByCode maps codes to gaming platforms
+func (d *DB) gamingPlatformsByCode() (map[int]*GamingPlatform, error) {
+	platforms, err := d.GamingPlatforms()
+	if err != nil {
+		return nil, err
+	}
+	var rval = make(map[int]*GamingPlatform, len(platforms))
+	for _, p := range platforms {
+		rval[p.ID] = p
+	}
+	return rval, nil
+}
+
+// SaveGamingPlatform adds a gaming platform or replaces the name, family and icon of the one with its code
+func (d *DB) SaveGamingPlatform(p *GamingPlatform) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.ID < 1 {
+		return invalidError("invalid_platform", "a platform code must be a positive number")
+	}
+	if p.Name == "" || len([]rune(p.Name)) > 191 {
+		return invalidError("invalid_name", "a platform needs a name of at most 191 characters")
+	}
+	if err := validPlatformFamily(p.Family); err != nil {
+		return err
+	}
+	if len([]rune(p.Icon)) > 191 {
+		return invalidError("invalid_icon", "icons must be at most 191 characters")
+	}
+	return d.Exec(
+		"INSERT INTO platforms (id, name, family, icon) VALUES(?,?,?,?) ON DUPLICATE KEY UPDATE name = VALUES(name), family = VALUES(family), icon = VALUES(icon)",
+		p.ID, p.Name, p.Family, p.Icon,
+	).Error
+}
+
+// validPlatformFamily returns an invalid error unless family is one of PlatformFamilies
+func validPlatformFamily(family string) error {
+	for _, f := range PlatformFamilies {
+		if f == family {
+			return nil
+		}
+	}
+	return invalidError("invalid_family", "family must be one of %s", strings.Join(PlatformFamilies, ", "))
+}
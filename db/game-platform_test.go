@@ -0,0 +1,27 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGamingPlatformSeeds(t *testing.T) {
+	var seen = map[int]bool{}
+	for _, p := range gamingPlatformSeeds {
+		if seen[p.ID] {
+			t.Errorf("platform %d is seeded twice", p.ID)
+		}
+		seen[p.ID] = true
+		if err := validPlatformFamily(p.Family); err != nil {
+			t.Errorf("%s: %v", p.Name, err)
+		}
+	}
+	for code := PlatformXbox360; code <= PlatformKindle; code++ {
+		if !seen[code] {
+			t.Errorf("legacy platform %d is not seeded", code)
+		}
+	}
+	if err := validPlatformFamily("console"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected an unknown family to be invalid, got %v", err)
+	}
+}
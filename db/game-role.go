@@ -0,0 +1,119 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+// NoGameRolesKey is the boolean meta key members set to never be given game roles automatically
+const NoGameRolesKey = "noGameRoles"
+
+// GameRoleGrant records a Discord role given to a member because they played a game linked to it. Only roles
+// recorded here are ever taken away again, so roles handed out by hand are left alone
+type GameRoleGrant struct {
+	MemberID  int       `gorm:"primary_key;auto_increment:false" json:"memberID"`
+	RoleID    string    `gorm:"primary_key;type:varchar(32)" json:"roleID"`
+	GrantedAt time.Time `gorm:"not null" json:"grantedAt"`
+	// Discord is the member's Discord id, filled in by GameRoleGrants
+	Discord string `gorm:"-" json:"-"`
+}
+
+// GameRolePlay is when a member last played any of the auto role games linked to a Discord role
+type GameRolePlay struct {
+	MemberID int
+	Discord  string
+	RoleID   string
+	// GameID and Game are the game the member played most recently of those linked to the role
+	GameID int
+	Game   string
+	Played time.Time
+}
+
+// GameRolePlays lists, for each Discord role linked to an auto role game, when each current member with a
+// Discord account last played one of its games. Members who set NoGameRolesKey are left out
+func (d *DB) GameRolePlays() ([]*GameRolePlay, error) {
+	rows, err := d.Raw(strings.Join([]string{
+		"SELECT m.id, m.discord, g.role_id, g.id, g.name, mg.played",
+		"FROM membergames mg",
+		"JOIN games g ON (mg.game = g.id)",
+		"JOIN members m ON (mg.member = m.id)",
+		"WHERE g.auto_role = 1 AND g.role_id <> ''",
+		"AND m.discord IS NOT NULL AND m.discord <> ''",
+		"AND m.departed_at IS NULL AND m.anonymized_at IS NULL",
+		"AND m.id NOT IN (SELECT member_id FROM membermeta WHERE meta_key = ? AND meta_value = 'true')",
+		"ORDER BY mg.played DESC",
+	}, " "), NoGameRolesKey).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rval = []*GameRolePlay{}
+	var seen = map[string]bool{}
+	for rows.Next() {
+		var row = &GameRolePlay{}
+		if err := rows.Scan(&row.MemberID, &row.Discord, &row.RoleID, &row.GameID, &row.Game, &row.Played); err != nil {
+			return nil, err
+		}
+		// rows come most recent first, so the first for a member and role is the one to keep
+		key := row.RoleID + "/" + row.Discord
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		rval = append(rval, row)
+	}
+	return rval, rows.Err()
+}
+
+// GameRoleGrants lists the game roles given automatically to current members with a Discord account. Members
+// who set NoGameRolesKey are left out, so roles they were given before are not taken away either
+func (d *DB) GameRoleGrants() ([]*GameRoleGrant, error) {
+	rows, err := d.Raw(strings.Join([]string{
+		"SELECT gr.member_id, gr.role_id, gr.granted_at, m.discord",
+		"FROM game_role_grants gr",
+		"JOIN members m ON (gr.member_id = m.id)",
+		"WHERE m.discord IS NOT NULL AND m.discord <> ''",
+		"AND m.departed_at IS NULL AND m.anonymized_at IS NULL",
+		"AND m.id NOT IN (SELECT member_id FROM membermeta WHERE meta_key = ? AND meta_value = 'true')",
+		"ORDER BY gr.member_id, gr.role_id",
+	}, " "), NoGameRolesKey).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rval = []*GameRoleGrant{}
+	for rows.Next() {
+		var row = &GameRoleGrant{}
+		if err := rows.Scan(&row.MemberID, &row.RoleID, &row.GrantedAt, &row.Discord); err != nil {
+			return nil, err
+		}
+		rval = append(rval, row)
+	}
+	return rval, rows.Err()
+}
+
+// SetGameRole links a game to a Discord role, and sets whether the role is given to members who play it
+func (d *DB) SetGameRole(gameID int, roleID string, auto bool) (*Game, error) {
+	game, err := d.GameByID(gameID)
+	if err != nil {
+		return nil, err
+	}
+	game.RoleID, game.AutoRole = roleID, auto
+	if err := d.SaveGame(game); err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+// RecordGameRoleGrant notes that a member was given a game role automatically
+func (d *DB) RecordGameRoleGrant(memberID int, roleID string, at time.Time) error {
+	return d.Exec(
+		"INSERT INTO game_role_grants (member_id, role_id, granted_at) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE granted_at = VALUES(granted_at)",
+		memberID, roleID, at,
+	).Error
+}
+
+// DeleteGameRoleGrant forgets an automatically given game role, once it has been taken away
+func (d *DB) DeleteGameRoleGrant(memberID int, roleID string) error {
+	return d.Where("member_id = ? AND role_id = ?", memberID, roleID).Delete(&GameRoleGrant{}).Error
+}
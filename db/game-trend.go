@@ -0,0 +1,175 @@
+package db
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// GameWeek is how many members played a game in the week starting Week, a Monday in UTC
+type GameWeek struct {
+	ID      int       `gorm:"primary_key" json:"-"`
+	Week    time.Time `gorm:"type:date;not null;unique_index:game_week" json:"week"`
+	GameID  int       `gorm:"not null;unique_index:game_week;index" json:"gameID"`
+	Players int       `gorm:"not null;default:0" json:"players"`
+}
+
+// GameTrend compares how many members played a game in a week with the week before
+type GameTrend struct {
+	ID              int             `json:"id"`
+	Name            string          `json:"name"`
+	Image           string          `json:"image"`
+	Platform        int             `json:"platform"`
+	PlatformInfo    *GamingPlatform `json:"platformInfo,omitempty"`
+	Players         int             `json:"players"`
+	PreviousPlayers int             `json:"previousPlayers"`
+	// Delta is Players less PreviousPlayers
+	Delta int `json:"delta"`
+}
+
+// GameTrends are the games played most in the week starting Week, and those whose player counts rose and fell
+// most from the week before
+type GameTrends struct {
+	Week    time.Time    `json:"week"`
+	Top     []*GameTrend `json:"top"`
+	Rising  []*GameTrend `json:"rising"`
+	Falling []*GameTrend `json:"falling"`
+}
+
+// WeekOf returns the start of the week t is in, Monday at midnight UTC
+func WeekOf(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+// SnapshotGameWeek records how many members have played each game since the start of week. Counts only go up,
+// since membergames keeps when each member last played, so a snapshot taken late must not undercount the week
+func (d *DB) SnapshotGameWeek(week time.Time) error {
+	week = WeekOf(week)
+	return d.Exec(strings.Join([]string{
+		"INSERT INTO game_weeks (week, game_id, players)",
+		"SELECT ?, mg.game, COUNT(*) FROM membergames mg",
+		"JOIN members m ON (mg.member = m.id)",
+		"WHERE mg.played >= ? AND mg.played < ? AND m.anonymized_at IS NULL",
+		"GROUP BY mg.game",
+		"ON DUPLICATE KEY UPDATE players = GREATEST(players, VALUES(players))",
+	}, " "), week.Format("2006-01-02"), week, week.AddDate(0, 0, 7)).Error
+}
+
+// gameWeekPlayers maps games to their player counts in the week starting week
+func (d *DB) gameWeekPlayers(week time.Time) (map[int]int, error) {
+	var rows []*GameWeek
+	if err := d.Where("week = ?", week.Format("2006-01-02")).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	var rval = make(map[int]int, len(rows))
+	for _, row := range rows {
+		rval[row.GameID] = row.Players
+	}
+	return rval, nil
+}
+
+// GameTrends returns up to limit games each that were played most in week, and that rose and fell most from the
+// week before
+func (d *DB) GameTrends(week time.Time, limit int) (*GameTrends, error) {
+	week = WeekOf(week)
+	current, err := d.gameWeekPlayers(week)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := d.gameWeekPlayers(week.AddDate(0, 0, -7))
+	if err != nil {
+		return nil, err
+	}
+	rval := &GameTrends{Week: week}
+	rval.Top, rval.Rising, rval.Falling = compareWeeks(current, previous, limit)
+
+	var ids []int
+	for _, trends := range [][]*GameTrend{rval.Top, rval.Rising, rval.Falling} {
+		for _, t := range trends {
+			ids = append(ids, t.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return rval, nil
+	}
+	var games []*Game
+	if err := d.Where("id IN (?)", ids).Find(&games).Error; err != nil {
+		return nil, err
+	}
+	known, err := d.gamingPlatformsByCode()
+	if err != nil {
+		return nil, err
+	}
+	var byID = map[int]*Game{}
+	for _, g := range games {
+		byID[g.ID] = g
+	}
+	for _, trends := range [][]*GameTrend{rval.Top, rval.Rising, rval.Falling} {
+		for _, t := range trends {
+			if g, ok := byID[t.ID]; ok {
+				t.Name, t.Image, t.Platform, t.PlatformInfo = g.Name, g.Image, g.Platform, known[g.Platform]
+			}
+		}
+	}
+	return rval, nil
+}
+
+// compareWeeks ranks games by their player counts in the current week, and by the change in them, biggest rise
+// and biggest fall first. Ties go to the game with more players, then the lower id
+func compareWeeks(current, previous map[int]int, limit int) ([]*GameTrend, []*GameTrend, []*GameTrend) {
+	var top, rising, falling = []*GameTrend{}, []*GameTrend{}, []*GameTrend{}
+	var seen = map[int]bool{}
+	for _, counts := range []map[int]int{current, previous} {
+		for id := range counts {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			t := &GameTrend{ID: id, Players: current[id], PreviousPlayers: previous[id]}
+			t.Delta = t.Players - t.PreviousPlayers
+			if t.Players > 0 {
+				top = append(top, t)
+			}
+			switch {
+			case t.Delta > 0:
+				rising = append(rising, t)
+			case t.Delta < 0:
+				falling = append(falling, t)
+			}
+		}
+	}
+	order := func(trends []*GameTrend, sign int) {
+		sort.Slice(trends, func(i, j int) bool {
+			a, b := trends[i], trends[j]
+			if a.Delta != b.Delta {
+				return a.Delta*sign > b.Delta*sign
+			}
+			if a.Players+a.PreviousPlayers != b.Players+b.PreviousPlayers {
+				return a.Players+a.PreviousPlayers > b.Players+b.PreviousPlayers
+			}
+			return a.ID < b.ID
+		})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Players != top[j].Players {
+			return top[i].Players > top[j].Players
+		}
+		return top[i].ID < top[j].ID
+	})
+	order(rising, 1)
+	order(falling, -1)
+	if limit > 0 {
+		if len(top) > limit {
+			top = top[:limit]
+		}
+		if len(rising) > limit {
+			rising = rising[:limit]
+		}
+		if len(falling) > limit {
+			falling = falling[:limit]
+		}
+	}
+	return top, rising, falling
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWeekOf(t *testing.T) {
+	monday := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+	for _, when := range []time.Time{
+		monday,
+		time.Date(2026, 10, 14, 15, 30, 0, 0, time.UTC),
+		time.Date(2026, 10, 18, 23, 59, 59, 0, time.UTC),
+		time.Date(2026, 10, 18, 20, 0, 0, 0, time.FixedZone("EDT", -4*3600)).Add(-time.Hour),
+	} {
+		if got := WeekOf(when); !got.Equal(monday) {
+			t.Errorf("%s: expected %s, got %s", when, monday, got)
+		}
+	}
+	if got := WeekOf(time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)); got.Equal(monday) {
+		t.Errorf("expected the next Monday to start a new week")
+	}
+}
+
+func TestCompareWeeks(t *testing.T) {
+	current := map[int]int{1: 10, 2: 4, 3: 7, 5: 2}
+	previous := map[int]int{1: 6, 2: 9, 3: 7, 4: 3}
+	top, rising, falling := compareWeeks(current, previous, 0)
+
+	ids := func(trends []*GameTrend) []int {
+		var rval []int
+		for _, t := range trends {
+			rval = append(rval, t.ID)
+		}
+		return rval
+	}
+	for _, test := range []struct {
+		name     string
+		got      []int
+		expected []int
+	}{
+		{"top", ids(top), []int{1, 3, 2, 5}},
+		{"rising", ids(rising), []int{1, 5}},
+		{"falling", ids(falling), []int{2, 4}},
+	} {
+		if len(test.got) != len(test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, test.got)
+			continue
+		}
+		for i := range test.got {
+			if test.got[i] != test.expected[i] {
+				t.Errorf("%s: expected %v, got %v", test.name, test.expected, test.got)
+				break
+			}
+		}
+	}
+	if falling[0].Delta != -5 || falling[1].Players != 0 || falling[1].PreviousPlayers != 3 {
+		t.Errorf("unexpected falling deltas %+v %+v", falling[0], falling[1])
+	}
+
+	if top, rising, _ := compareWeeks(current, previous, 1); len(top) != 1 || len(rising) != 1 {
+		t.Errorf("expected the limit to apply, got %d and %d", len(top), len(rising))
+	}
+}
@@ -0,0 +1,474 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+// Game is an entry in the games catalog. Platform and PlatformID are where the game was first seen, and
+// Platforms lists every platform it is known on, including that one
+type Game struct {
+	ID         int    `gorm:"primary_key" json:"id"`
+	Name       string `gorm:"type:varchar(191);not null;default:'';index" json:"name"`
+	Image      string `gorm:"type:varchar(255);not null;default:''" json:"image"`
+	Platform   int    `gorm:"not null;default:0" json:"platform"`
+	PlatformID int    `gorm:"not null;default:0" json:"platform_id"`
+	// RoleID and ChannelID link the game to its Discord role and channel
+	RoleID    string `gorm:"type:varchar(32);not null;default:''" json:"roleID"`
+	ChannelID string `gorm:"type:varchar(32);not null;default:''" json:"channelID"`
+	// AutoRole gives RoleID to members who play the game, and may take it away once they stop
+	AutoRole bool `gorm:"not null;default:false" json:"autoRole"`
+	// PendingReview is true for games added automatically, such as from Discord presence, until an admin saves them
+	PendingReview bool `gorm:"not null;default:false;index" json:"pendingReview"`
+	// PlatformInfo describes Platform
+	PlatformInfo *GamingPlatform `gorm:"-" json:"platformInfo,omitempty"`
+	Platforms    []*GamePlatform `gorm:"-" json:"platforms"`
+	Aliases      []string        `gorm:"-" json:"aliases"`
+}
+
+// TableName is the table games have always been kept in
+func (Game) TableName() string {
+	return "games"
+}
+
+// GamePlatform is a platform a game is available on. PlatformID is the game's id on that platform, or 0 when unknown
+type GamePlatform struct {
+	ID         int `gorm:"primary_key" json:"-"`
+	GameID     int `gorm:"not null;unique_index:game_platform" json:"-"`
+	Platform   int `gorm:"not null;unique_index:game_platform;index:platform_id" json:"platform"`
+	PlatformID int `gorm:"not null;default:0;unique_index:game_platform;index:platform_id" json:"platform_id"`
+	// PlatformInfo describes Platform
+	PlatformInfo *GamingPlatform `gorm:"-" json:"platformInfo,omitempty"`
+}
+
+// GameAlias is another name a game is known by
+type GameAlias struct {
+	ID     int    `gorm:"primary_key"`
+	GameID int    `gorm:"not null;index"`
+	Alias  string `gorm:"type:varchar(191);not null;unique_index"`
+}
+
+// MemberGame records when a member last played a game
+type MemberGame struct {
+	Member int       `gorm:"primary_key;auto_increment:false"`
+	Game   int       `gorm:"primary_key;auto_increment:false;index"`
+	Played time.Time `gorm:"index"`
+	// Source is where the play was last recorded from. Rows written by the Xbox scraper leave it at its default
+	Source string `gorm:"type:varchar(16);not null;default:'xbox';index"`
+}
+
+// Where played games are recorded from
+const (
+	GameSourceXbox    = "xbox"
+	GameSourceDiscord = "discord"
+	GameSourceSteam   = "steam"
+)
+
+// TableName is the table played games have always been kept in
+func (MemberGame) TableName() string {
+	return "membergames"
+}
+
+// GameDuplicate is a name shared by more than one game
+type GameDuplicate struct {
+	Name  string  `json:"name"`
+	Games []*Game `json:"games"`
+}
+
+// cleanAliases trims aliases and drops blanks, repeats and the game's own name, ignoring case
+func cleanAliases(name string, aliases []string) ([]string, error) {
+	var rval = []string{}
+	var seen = map[string]bool{strings.ToLower(strings.TrimSpace(name)): true}
+	for _, alias := range aliases {
+		alias = strings.TrimSpace(alias)
+		if alias == "" || seen[strings.ToLower(alias)] {
+			continue
+		}
+		if len([]rune(alias)) > 191 {
+			return nil, invalidError("invalid_alias", "aliases must be at most 191 characters")
+		}
+		seen[strings.ToLower(alias)] = true
+		rval = append(rval, alias)
+	}
+	return rval, nil
+}
+
+// loadGameDetails fills in the platforms and aliases of games, and describes each platform
+func (d *DB) loadGameDetails(games ...*Game) error {
+	if len(games) == 0 {
+		return nil
+	}
+	var ids []int
+	var byID = map[int]*Game{}
+	for _, g := range games {
+		ids = append(ids, g.ID)
+		byID[g.ID] = g
+		g.Platforms = []*GamePlatform{}
+		g.Aliases = []string{}
+	}
+	var platforms []*GamePlatform
+	if err := d.Where("game_id IN (?)", ids).Order("platform, platform_id").Find(&platforms).Error; err != nil {
+		return err
+	}
+	for _, p := range platforms {
+		byID[p.GameID].Platforms = append(byID[p.GameID].Platforms, p)
+	}
+	var aliases []*GameAlias
+	if err := d.Where("game_id IN (?)", ids).Order("alias").Find(&aliases).Error; err != nil {
+		return err
+	}
+	for _, a := range aliases {
+		byID[a.GameID].Aliases = append(byID[a.GameID].Aliases, a.Alias)
+	}
+	// games written by the platform scrapers only have their own platform
+	for _, g := range games {
+		if g.Platform == 0 {
+			continue
+		}
+		var found bool
+		for _, p := range g.Platforms {
+			found = found || (p.Platform == g.Platform && p.PlatformID == g.PlatformID)
+		}
+		if !found {
+			g.Platforms = append(g.Platforms, &GamePlatform{GameID: g.ID, Platform: g.Platform, PlatformID: g.PlatformID})
+		}
+	}
+	known, err := d.gamingPlatformsByCode()
+	if err != nil {
+		return err
+	}
+	for _, g := range games {
+		g.PlatformInfo = known[g.Platform]
+		for _, p := range g.Platforms {
+			p.PlatformInfo = known[p.Platform]
+		}
+	}
+	return nil
+}
+
+// GameByID returns a game with its platforms and aliases
+func (d *DB) GameByID(id int) (*Game, error) {
+	var g Game
+	if err := d.First(&g, id).Error; err != nil {
+		return nil, notFound(err, "game_not_found", "no game with id %d", id)
+	}
+	return &g, d.loadGameDetails(&g)
+}
+
+// GameByName returns the game with the given name or alias, ignoring case. Where several games share
+// the name the oldest is returned
+func (d *DB) GameByName(name string) (*Game, error) {
+	var g Game
+	err := d.Where("name = ? OR id IN (SELECT game_id FROM game_aliases WHERE alias = ?)", name, name).Order("id").First(&g).Error
+	if err != nil {
+		return nil, notFound(err, "game_not_found", "no game named %s", name)
+	}
+	return &g, d.loadGameDetails(&g)
+}
+
+// GameByPlatformID returns the game with the given id on a platform, including games it was merged into
+func (d *DB) GameByPlatformID(platform int, platformID int) (*Game, error) {
+	var g Game
+	err := d.
+		Where("(platform = ? AND platform_id = ?) OR id IN (SELECT game_id FROM game_platforms WHERE platform = ? AND platform_id = ?)", platform, platformID, platform, platformID).
+		Order("id").
+		First(&g).Error
+	if err != nil {
+		return nil, notFound(err, "game_not_found", "no game with id %d on platform %d", platformID, platform)
+	}
+	return &g, d.loadGameDetails(&g)
+}
+
+// SearchGames returns up to limit games whose name or an alias contains q, exact matches first
+func (d *DB) SearchGames(q string, limit int) ([]*Game, error) {
+	var games = []*Game{}
+	query := d.Model(&Game{})
+	if q = strings.TrimSpace(q); q != "" {
+		if err := d.Where("name = ? OR id IN (SELECT game_id FROM game_aliases WHERE alias = ?)", q, q).Order("id").Limit(limit).Find(&games).Error; err != nil {
+			return nil, err
+		}
+		like := "%" + likeEscaper.Replace(q) + "%"
+		query = query.Where("name LIKE ? OR id IN (SELECT game_id FROM game_aliases WHERE alias LIKE ?)", like, like)
+		if len(games) > 0 {
+			var exact []int
+			for _, g := range games {
+				exact = append(exact, g.ID)
+			}
+			query = query.Where("id NOT IN (?)", exact)
+		}
+	}
+	if len(games) < limit {
+		var rest []*Game
+		if err := query.Order("name, id").Limit(limit - len(games)).Find(&rest).Error; err != nil {
+			return nil, err
+		}
+		games = append(games, rest...)
+	}
+	return games, d.loadGameDetails(games...)
+}
+
+// checkGameName returns a conflict error if another game than id already uses name as its name or an alias
+func (d *DB) checkGameName(id int, name string) error {
+	var count int
+	err := d.Model(&Game{}).
+		Where("id != ? AND (name = ? OR id IN (SELECT game_id FROM game_aliases WHERE alias = ?))", id, name, name).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return conflictError("game_exists", "another game is already called %s", name)
+	}
+	return nil
+}
+
+// SaveGame creates or updates a game along with its platforms and aliases, which replace any it had
+func (d *DB) SaveGame(g *Game) error {
+	tx := &DB{DB: d.Begin()}
+	if err := tx.Error; err != nil {
+		return err
+	}
+	if err := tx.saveGame(g); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func (d *DB) saveGame(g *Game) error {
+	g.Name = strings.TrimSpace(g.Name)
+	if g.Name == "" || len([]rune(g.Name)) > 191 {
+		return invalidError("invalid_name", "a game needs a name of at most 191 characters")
+	}
+	if g.AutoRole && g.RoleID == "" {
+		return invalidError("missing_role", "a game needs a role to give it out automatically")
+	}
+	for _, id := range []string{g.RoleID, g.ChannelID} {
+		if strings.Trim(id, "0123456789") != "" || len(id) > 32 {
+			return invalidError("invalid_discord_id", "%q is not a Discord id", id)
+		}
+	}
+	aliases, err := cleanAliases(g.Name, g.Aliases)
+	if err != nil {
+		return err
+	}
+	var names = aliases
+	if g.ID == 0 {
+		names = append(names, g.Name)
+	} else {
+		// games which already share a name, usually across platforms, may still be edited
+		var stored Game
+		if err := d.First(&stored, g.ID).Error; err != nil {
+			return notFound(err, "game_not_found", "no game with id %d", g.ID)
+		}
+		if !strings.EqualFold(stored.Name, g.Name) {
+			names = append(names, g.Name)
+		}
+	}
+	for _, name := range names {
+		if err := d.checkGameName(g.ID, name); err != nil {
+			return err
+		}
+	}
+	if g.ID == 0 {
+		err = d.Create(g).Error
+	} else {
+		err = d.Model(g).Updates(map[string]interface{}{
+			"name":           g.Name,
+			"image":          g.Image,
+			"role_id":        g.RoleID,
+			"channel_id":     g.ChannelID,
+			"auto_role":      g.AutoRole,
+			"pending_review": g.PendingReview,
+		}).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := d.Where("game_id = ?", g.ID).Delete(&GameAlias{}).Error; err != nil {
+		return err
+	}
+	for _, alias := range aliases {
+		if err := d.Create(&GameAlias{GameID: g.ID, Alias: alias}).Error; err != nil {
+			return err
+		}
+	}
+
+	if g.Platforms != nil {
+		known, err := d.gamingPlatformsByCode()
+		if err != nil {
+			return err
+		}
+		if err := d.Where("game_id = ?", g.ID).Delete(&GamePlatform{}).Error; err != nil {
+			return err
+		}
+		var seen = map[[2]int]bool{}
+		for _, p := range g.Platforms {
+			if known[p.Platform] == nil {
+				return invalidError("unknown_platform", "%d is not a known platform", p.Platform)
+			}
+			if seen[[2]int{p.Platform, p.PlatformID}] {
+				continue
+			}
+			seen[[2]int{p.Platform, p.PlatformID}] = true
+			if err := d.Create(&GamePlatform{GameID: g.ID, Platform: p.Platform, PlatformID: p.PlatformID}).Error; err != nil {
+				return err
+			}
+		}
+		if g.Platform == 0 && len(g.Platforms) > 0 {
+			g.Platform, g.PlatformID = g.Platforms[0].Platform, g.Platforms[0].PlatformID
+			if err := d.Model(g).Updates(map[string]interface{}{"platform": g.Platform, "platform_id": g.PlatformID}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return d.loadGameDetails(g)
+}
+
+// CreatePendingGame adds a game seen somewhere automatically, to be reviewed by an admin
+func (d *DB) CreatePendingGame(name string) (*Game, error) {
+	var g = &Game{Name: name, PendingReview: true}
+	if err := d.SaveGame(g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// PendingGames returns the games waiting for an admin to review them, oldest first
+func (d *DB) PendingGames() ([]*Game, error) {
+	var games = []*Game{}
+	if err := d.Where("pending_review = ?", true).Order("id").Find(&games).Error; err != nil {
+		return nil, err
+	}
+	return games, d.loadGameDetails(games...)
+}
+
+// AddGamePlatform records that a game is also available on a platform
+func (d *DB) AddGamePlatform(gameID, platform, platformID int) error {
+	return d.Exec("INSERT IGNORE INTO game_platforms (game_id, platform, platform_id) VALUES(?,?,?)", gameID, platform, platformID).Error
+}
+
+// RecordPlayed notes that a member played a game at the given time, and where that was seen
+func (d *DB) RecordPlayed(memberID, gameID int, played time.Time, source string) error {
+	return d.Exec(
+		"INSERT INTO membergames (member, game, played, source) VALUES(?,?,?,?) ON DUPLICATE KEY UPDATE played = GREATEST(played, VALUES(played)), source = VALUES(source)",
+		memberID,
+		gameID,
+		played,
+		source,
+	).Error
+}
+
+// DeleteMemberGames forgets the games recorded for a member from one source
+func (d *DB) DeleteMemberGames(memberID int, source string) error {
+	return d.Exec("DELETE FROM membergames WHERE member = ? AND source = ?", memberID, source).Error
+}
+
+// DeleteGame removes a game along with its platforms, aliases, who played it and its weekly player counts
+func (d *DB) DeleteGame(id int) error {
+	if _, err := d.GameByID(id); err != nil {
+		return err
+	}
+	for _, statement := range []string{
+		"DELETE FROM membergames WHERE game = ?",
+		"DELETE FROM game_weeks WHERE game_id = ?",
+		"UPDATE lfgs SET game_id = 0 WHERE game_id = ?",
+		"DELETE FROM game_platforms WHERE game_id = ?",
+		"DELETE FROM game_aliases WHERE game_id = ?",
+		"DELETE FROM games WHERE id = ?",
+	} {
+		if err := d.Exec(statement, id).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DuplicateGames returns the names used by more than one game, usually once per platform
+func (d *DB) DuplicateGames() ([]*GameDuplicate, error) {
+	var games []*Game
+	err := d.Where("name IN (SELECT name FROM (SELECT name FROM games GROUP BY name HAVING COUNT(*) > 1) dupes)").Order("name, id").Find(&games).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := d.loadGameDetails(games...); err != nil {
+		return nil, err
+	}
+	var rval = []*GameDuplicate{}
+	for _, g := range games {
+		if n := len(rval); n > 0 && strings.EqualFold(rval[n-1].Name, g.Name) {
+			rval[n-1].Games = append(rval[n-1].Games, g)
+			continue
+		}
+		rval = append(rval, &GameDuplicate{Name: g.Name, Games: []*Game{g}})
+	}
+	return rval, nil
+}
+
+// MergeGames merges the game fromID into the game intoID in a single transaction. From's players, platforms and
+// aliases move to Into, From's name becomes an alias of Into, Into keeps its own image and Discord links where it
+// has them, and From is deleted
+func (d *DB) MergeGames(fromID, intoID int) (*Game, error) {
+	if fromID == intoID {
+		return nil, invalidError("same_game", "a game cannot be merged into itself")
+	}
+	tx := &DB{DB: d.Begin()}
+	if err := tx.Error; err != nil {
+		return nil, err
+	}
+	game, err := tx.mergeGames(fromID, intoID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return game, tx.Commit().Error
+}
+
+func (d *DB) mergeGames(fromID, intoID int) (*Game, error) {
+	from, err := d.GameByID(fromID)
+	if err != nil {
+		return nil, err
+	}
+	into, err := d.GameByID(intoID)
+	if err != nil {
+		return nil, err
+	}
+
+	var statements = []struct {
+		query string
+		args  []interface{}
+	}{
+		{"UPDATE membergames i JOIN membergames f ON (f.member = i.member AND f.game = ?) SET i.played = GREATEST(i.played, f.played) WHERE i.game = ?", []interface{}{from.ID, into.ID}},
+		{"DELETE f FROM membergames f JOIN membergames i ON (i.member = f.member AND i.game = ?) WHERE f.game = ?", []interface{}{into.ID, from.ID}},
+		{"UPDATE membergames SET game = ? WHERE game = ?", []interface{}{into.ID, from.ID}},
+		{"UPDATE lfgs SET game_id = ? WHERE game_id = ?", []interface{}{into.ID, from.ID}},
+		{"INSERT INTO game_weeks (week, game_id, players) SELECT week, ?, players FROM game_weeks WHERE game_id = ? ON DUPLICATE KEY UPDATE players = GREATEST(game_weeks.players, VALUES(players))", []interface{}{into.ID, from.ID}},
+		{"DELETE FROM game_weeks WHERE game_id = ?", []interface{}{from.ID}},
+		{"DELETE FROM game_platforms WHERE game_id = ?", []interface{}{from.ID}},
+		{"DELETE FROM game_aliases WHERE game_id = ?", []interface{}{from.ID}},
+		{"DELETE FROM games WHERE id = ?", []interface{}{from.ID}},
+	}
+	for _, statement := range statements {
+		if err := d.Exec(statement.query, statement.args...).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	into.Platforms = append(into.Platforms, from.Platforms...)
+	into.Aliases = append(append(into.Aliases, from.Name), from.Aliases...)
+	if into.Image == "" {
+		into.Image = from.Image
+	}
+	if into.RoleID == "" {
+		into.RoleID, into.AutoRole = from.RoleID, from.AutoRole
+	}
+	if into.ChannelID == "" {
+		into.ChannelID = from.ChannelID
+	}
+	into.PendingReview = false
+	if err := d.saveGame(into); err != nil {
+		return nil, err
+	}
+	return into, nil
+}
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCleanAliases(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		aliases  []string
+		expected []string
+	}{
+		{"Destiny 2", nil, []string{}},
+		{"Destiny 2", []string{" D2 ", "d2", "", "destiny 2"}, []string{"D2"}},
+		{"Halo", []string{"Halo: CE", "Halo Infinite", "HALO: ce"}, []string{"Halo: CE", "Halo Infinite"}},
+	} {
+		got, err := cleanAliases(test.name, test.aliases)
+		if err != nil {
+			t.Errorf("%q %v: unexpected error %v", test.name, test.aliases, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q %v: expected %v, got %v", test.name, test.aliases, test.expected, got)
+		}
+	}
+	if _, err := cleanAliases("x", []string{strings.Repeat("a", 192)}); err == nil {
+		t.Error("expected an error for a long alias")
+	}
+}
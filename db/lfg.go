@@ -0,0 +1,302 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+// Limits on looking for group entries
+const (
+	LFGMinSize = 2
+	LFGMaxSize = 16
+	// LFGMaxDuration is the longest an entry may stay open
+	LFGMaxDuration = 6 * time.Hour
+)
+
+// LFG is a member looking for others to play a game with right now. It is full once Size members, the host
+// included, have joined, and expires at ExpiresAt whether or not it filled
+type LFG struct {
+	ID     int `gorm:"primary_key" json:"id"`
+	HostID int `gorm:"not null;index" json:"hostID"`
+	// GameID is the game from the catalog, or 0 when the host named one it doesn't know
+	GameID    int       `gorm:"not null;default:0" json:"gameID"`
+	Game      string    `gorm:"type:varchar(191);not null;default:''" json:"game"`
+	Size      int       `gorm:"not null" json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expiresAt"`
+	// FilledAt is when the last member joined, and ClosedAt when the host closed the entry or it expired
+	FilledAt *time.Time `json:"filledAt"`
+	ClosedAt *time.Time `gorm:"index" json:"closedAt"`
+	// ChannelID and MessageID are the Discord message with the join buttons
+	ChannelID string `gorm:"type:varchar(32);not null;default:''" json:"channelID"`
+	MessageID string `gorm:"type:varchar(32);not null;default:''" json:"messageID"`
+	// VoiceChannelID is the temporary voice channel made for the group once it filled, until it is removed
+	VoiceChannelID string       `gorm:"type:varchar(32);not null;default:''" json:"voiceChannelID"`
+	Members        []*LFGMember `gorm:"-" json:"members"`
+}
+
+// TableName is the table LFG entries are kept in
+func (LFG) TableName() string {
+	return "lfgs"
+}
+
+// LFGMember is a member who joined an LFG. Name and Discord are filled in from their member record
+type LFGMember struct {
+	LFGID    int       `gorm:"primary_key;auto_increment:false" json:"-"`
+	MemberID int       `gorm:"primary_key;auto_increment:false;index" json:"memberID"`
+	JoinedAt time.Time `gorm:"not null" json:"joinedAt"`
+	Name     string    `gorm:"-" json:"name"`
+	Discord  string    `gorm:"-" json:"discordID"`
+}
+
+// TableName is the table LFG members are kept in
+func (LFGMember) TableName() string {
+	return "lfg_members"
+}
+
+// Full reports whether every place in the group is taken
+func (l *LFG) Full() bool {
+	return len(l.Members) >= l.Size
+}
+
+// Open reports whether members may still join or leave as of now
+func (l *LFG) Open(now time.Time) bool {
+	return l.ClosedAt == nil && l.FilledAt == nil && now.Before(l.ExpiresAt)
+}
+
+// Has reports whether a member has joined
+func (l *LFG) Has(memberID int) bool {
+	for _, m := range l.Members {
+		if m.MemberID == memberID {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateLFG opens an entry for its host, who joins it straight away. A host may only have one entry open at a time
+func (d *DB) CreateLFG(l *LFG, duration time.Duration) error {
+	l.Game = strings.TrimSpace(l.Game)
+	if l.Game == "" || len([]rune(l.Game)) > 191 {
+		return invalidError("invalid_game", "a game needs a name of at most 191 characters")
+	}
+	if l.Size < LFGMinSize || l.Size > LFGMaxSize {
+		return invalidError("invalid_size", "size must be from %d to %d players", LFGMinSize, LFGMaxSize)
+	}
+	if duration < time.Minute || duration > LFGMaxDuration {
+		return invalidError("invalid_duration", "an entry may stay open for a minute up to %s", LFGMaxDuration)
+	}
+
+	tx := &DB{DB: d.Begin()}
+	if err := tx.Error; err != nil {
+		return err
+	}
+	now := time.Now()
+	var open int
+	err := tx.Model(&LFG{}).
+		Where("host_id = ? AND closed_at IS NULL AND filled_at IS NULL AND expires_at > ?", l.HostID, now).
+		Count(&open).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if open > 0 {
+		tx.Rollback()
+		return conflictError("lfg_open", "you are already looking for a group")
+	}
+	l.ID, l.CreatedAt, l.ExpiresAt, l.FilledAt, l.ClosedAt = 0, now, now.Add(duration), nil, nil
+	if err := tx.Create(l).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Create(&LFGMember{LFGID: l.ID, MemberID: l.HostID, JoinedAt: now}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	return d.loadLFGMembers(l)
+}
+
+// LFGByID returns an entry with its members
+func (d *DB) LFGByID(id int) (*LFG, error) {
+	var l LFG
+	if err := d.First(&l, id).Error; err != nil {
+		return nil, notFound(err, "lfg_not_found", "no LFG with id %d", id)
+	}
+	if err := d.loadLFGMembers(&l); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// ActiveLFGs lists the entries which have neither expired nor been closed as of now, soonest to expire first
+func (d *DB) ActiveLFGs(now time.Time) ([]*LFG, error) {
+	var rval = []*LFG{}
+	if err := d.Where("closed_at IS NULL AND expires_at > ?", now).Order("expires_at, id").Find(&rval).Error; err != nil {
+		return nil, err
+	}
+	if err := d.loadLFGMembers(rval...); err != nil {
+		return nil, err
+	}
+	return rval, nil
+}
+
+func (d *DB) loadLFGMembers(lfgs ...*LFG) error {
+	if len(lfgs) == 0 {
+		return nil
+	}
+	var ids []int
+	var byID = map[int]*LFG{}
+	for _, l := range lfgs {
+		ids = append(ids, l.ID)
+		byID[l.ID] = l
+		l.Members = []*LFGMember{}
+	}
+	rows, err := d.Raw(strings.Join([]string{
+		"SELECT lm.lfg_id, lm.member_id, lm.joined_at, m.name, COALESCE(m.discord, '')",
+		"FROM lfg_members lm",
+		"JOIN members m ON (lm.member_id = m.id)",
+		"WHERE lm.lfg_id IN (?)",
+		"ORDER BY lm.joined_at, lm.member_id",
+	}, " "), ids).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m = &LFGMember{}
+		if err := rows.Scan(&m.LFGID, &m.MemberID, &m.JoinedAt, &m.Name, &m.Discord); err != nil {
+			return err
+		}
+		byID[m.LFGID].Members = append(byID[m.LFGID].Members, m)
+	}
+	return rows.Err()
+}
+
+// lockLFG loads an entry and its members inside a transaction, holding its row until the transaction ends
+func (d *DB) lockLFG(id int) (*LFG, error) {
+	var l LFG
+	if err := d.Set("gorm:query_option", "FOR UPDATE").First(&l, id).Error; err != nil {
+		return nil, notFound(err, "lfg_not_found", "no LFG with id %d", id)
+	}
+	if err := d.loadLFGMembers(&l); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// changeLFG runs change against a locked entry in a transaction, and returns the entry as it stands afterwards
+func (d *DB) changeLFG(id int, change func(tx *DB, l *LFG, now time.Time) error) (*LFG, error) {
+	tx := &DB{DB: d.Begin()}
+	if err := tx.Error; err != nil {
+		return nil, err
+	}
+	l, err := tx.lockLFG(id)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := change(tx, l, time.Now()); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return d.LFGByID(id)
+}
+
+// lfgClosedError explains why an entry can no longer be joined or left
+func lfgClosedError(l *LFG) error {
+	switch {
+	case l.FilledAt != nil:
+		return conflictError("lfg_full", "that group is already full")
+	case l.ClosedAt != nil:
+		return conflictError("lfg_closed", "that group was closed")
+	}
+	return conflictError("lfg_expired", "that group has expired")
+}
+
+// JoinLFG adds a member to an open entry. The member who takes the last place fills it
+func (d *DB) JoinLFG(id, memberID int) (*LFG, error) {
+	return d.changeLFG(id, func(tx *DB, l *LFG, now time.Time) error {
+		if l.Has(memberID) {
+			return conflictError("already_joined", "you are already in that group")
+		}
+		if !l.Open(now) {
+			return lfgClosedError(l)
+		}
+		if err := tx.Create(&LFGMember{LFGID: l.ID, MemberID: memberID, JoinedAt: now}).Error; err != nil {
+			return err
+		}
+		if len(l.Members)+1 >= l.Size {
+			return tx.Model(l).Update("filled_at", now).Error
+		}
+		return nil
+	})
+}
+
+// LeaveLFG takes a member out of an open entry. The host can't leave, but may close it instead
+func (d *DB) LeaveLFG(id, memberID int) (*LFG, error) {
+	return d.changeLFG(id, func(tx *DB, l *LFG, now time.Time) error {
+		if !l.Has(memberID) {
+			return conflictError("not_joined", "you are not in that group")
+		}
+		if l.HostID == memberID {
+			return conflictError("host_cannot_leave", "the host can't leave their own group, close it instead")
+		}
+		if !l.Open(now) {
+			return lfgClosedError(l)
+		}
+		return tx.Where("lfg_id = ? AND member_id = ?", l.ID, memberID).Delete(&LFGMember{}).Error
+	})
+}
+
+// CloseLFG closes an entry before it expires. Only its host, or an admin, may close it
+func (d *DB) CloseLFG(id, memberID int, admin bool) (*LFG, error) {
+	return d.changeLFG(id, func(tx *DB, l *LFG, now time.Time) error {
+		if l.HostID != memberID && !admin {
+			return forbiddenError("not_host", "only the host can close that group")
+		}
+		if l.ClosedAt != nil || !now.Before(l.ExpiresAt) {
+			return lfgClosedError(l)
+		}
+		return tx.Model(l).Update("closed_at", now).Error
+	})
+}
+
+// SetLFGMessage records the Discord message showing an entry
+func (d *DB) SetLFGMessage(id int, channelID, messageID string) error {
+	return d.Model(&LFG{ID: id}).Updates(map[string]interface{}{"channel_id": channelID, "message_id": messageID}).Error
+}
+
+// SetLFGVoiceChannel records the temporary voice channel made for an entry, or clears it once it is removed
+func (d *DB) SetLFGVoiceChannel(id int, channelID string) error {
+	return d.Model(&LFG{ID: id}).Update("voice_channel_id", channelID).Error
+}
+
+// ExpireLFGs closes the entries which expired by now and returns them
+func (d *DB) ExpireLFGs(now time.Time) ([]*LFG, error) {
+	var rval = []*LFG{}
+	if err := d.Where("closed_at IS NULL AND expires_at <= ?", now).Find(&rval).Error; err != nil {
+		return nil, err
+	}
+	for _, l := range rval {
+		if err := d.Model(l).Update("closed_at", l.ExpiresAt).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := d.loadLFGMembers(rval...); err != nil {
+		return nil, err
+	}
+	return rval, nil
+}
+
+// EndedLFGVoiceChannels lists closed or expired entries which still have a temporary voice channel
+func (d *DB) EndedLFGVoiceChannels(now time.Time) ([]*LFG, error) {
+	var rval = []*LFG{}
+	err := d.Where("voice_channel_id <> '' AND (closed_at IS NOT NULL OR expires_at <= ?)", now).Find(&rval).Error
+	return rval, err
+}
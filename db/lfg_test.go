@@ -0,0 +1,34 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLFGOpen(t *testing.T) {
+	now := time.Date(2026, time.October, 19, 20, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Minute)
+	for _, test := range []struct {
+		name string
+		lfg  LFG
+		open bool
+		code string
+	}{
+		{"open", LFG{ExpiresAt: now.Add(time.Hour)}, true, ""},
+		{"full", LFG{ExpiresAt: now.Add(time.Hour), FilledAt: &earlier}, false, "lfg_full"},
+		{"closed", LFG{ExpiresAt: now.Add(time.Hour), ClosedAt: &earlier}, false, "lfg_closed"},
+		{"expired", LFG{ExpiresAt: now}, false, "lfg_expired"},
+	} {
+		if got := test.lfg.Open(now); got != test.open {
+			t.Errorf("%s: expected open %v, got %v", test.name, test.open, got)
+		}
+		if test.open {
+			continue
+		}
+		var dbErr *Error
+		if err := lfgClosedError(&test.lfg); !errors.As(err, &dbErr) || dbErr.Code != test.code || !errors.Is(err, ErrConflict) {
+			t.Errorf("%s: expected a %s conflict, got %v", test.name, test.code, err)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package db
+
+import (
+	"time"
+)
+
+// LoginHistory records a member logging in to the dashboard
+type LoginHistory struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+	MemberID  int       `gorm:"not null;index" json:"memberID"`
+	// Via is the login route used
+	Via       string `gorm:"type:varchar(64);not null;default:''" json:"via"`
+	IP        string `gorm:"type:varchar(64);not null;default:''" json:"ip"`
+	UserAgent string `gorm:"type:varchar(255);not null;default:''" json:"userAgent"`
+}
+
+// RecordLogin saves a login to the member's login history
+func (d *DB) RecordLogin(l *LoginHistory) error {
+	return d.Create(l).Error
+}
+
+// MemberLoginHistory returns a member's logins, newest first
+func (d *DB) MemberLoginHistory(memberID int) ([]*LoginHistory, error) {
+	var rval = []*LoginHistory{}
+	err := d.Where("member_id = ?", memberID).Order("created_at DESC").Find(&rval).Error
+	return rval, err
+}
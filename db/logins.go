@@ -18,7 +18,7 @@ func (d *DB) GetLoginForCode(code string) (Logins, error) {
 
 	var login Logins
 	err := d.Raw("SELECT * FROM logins WHERE code = ? LIMIT 1", code).Scan(&login).Error
-	return login, err
+	return login, notFound(err, "login_not_found", "no login for code %s", code)
 }
 
 // DeleteLoginForCode deletes login rows with the matching code
@@ -0,0 +1,158 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Deletion request statuses
+const (
+	DeletionPending   = "pending"
+	DeletionApproved  = "approved"
+	DeletionRejected  = "rejected"
+	DeletionCancelled = "cancelled"
+)
+
+// DeletionRequest is a member asking for their data to be deleted. An admin approves or rejects it
+type DeletionRequest struct {
+	ID         uint       `gorm:"primary_key" json:"id"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	MemberID   int        `gorm:"not null;index" json:"memberID"`
+	Reason     string     `gorm:"type:text" json:"reason"`
+	Status     string     `gorm:"type:varchar(16);not null;default:'pending';index" json:"status"`
+	ReviewerID int        `gorm:"not null;default:0" json:"reviewerID"`
+	ReviewedAt *time.Time `json:"reviewedAt"`
+}
+
+// RequestDeletion files a deletion request for a member. A member may only have one pending request
+func (d *DB) RequestDeletion(memberID int, reason string) (*DeletionRequest, error) {
+	if _, err := d.PendingDeletionRequest(memberID); err == nil {
+		return nil, conflictError("deletion_pending", "member %d already has a pending deletion request", memberID)
+	}
+	r := &DeletionRequest{MemberID: memberID, Reason: reason, Status: DeletionPending}
+	return r, d.Create(r).Error
+}
+
+// PendingDeletionRequest returns the member's pending deletion request
+func (d *DB) PendingDeletionRequest(memberID int) (*DeletionRequest, error) {
+	r := new(DeletionRequest)
+	err := d.Where("member_id = ? AND status = ?", memberID, DeletionPending).First(r).Error
+	return r, notFound(err, "deletion_not_found", "member %d has no pending deletion request", memberID)
+}
+
+// PendingDeletionRequests returns every pending deletion request, oldest first
+func (d *DB) PendingDeletionRequests() ([]*DeletionRequest, error) {
+	var rval = []*DeletionRequest{}
+	err := d.Where("status = ?", DeletionPending).Order("created_at ASC").Find(&rval).Error
+	return rval, err
+}
+
+// DeletionRequestByID returns the deletion request with the given id
+func (d *DB) DeletionRequestByID(id uint) (*DeletionRequest, error) {
+	r := new(DeletionRequest)
+	err := d.First(r, id).Error
+	return r, notFound(err, "deletion_not_found", "no deletion request %d", id)
+}
+
+// CancelDeletion withdraws the member's pending deletion request
+func (d *DB) CancelDeletion(memberID int) error {
+	r, err := d.PendingDeletionRequest(memberID)
+	if err != nil {
+		return err
+	}
+	return d.Model(r).Update("status", DeletionCancelled).Error
+}
+
+// ReviewDeletion approves or rejects a pending deletion request. Approving anonymizes the member in the same transaction
+func (d *DB) ReviewDeletion(requestID uint, reviewerID int, approve bool) (*DeletionRequest, error) {
+	tx := &DB{DB: d.Begin()}
+	if err := tx.Error; err != nil {
+		return nil, err
+	}
+	r, err := tx.reviewDeletion(requestID, reviewerID, approve)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return r, tx.Commit().Error
+}
+
+func (d *DB) reviewDeletion(requestID uint, reviewerID int, approve bool) (*DeletionRequest, error) {
+	r, err := d.DeletionRequestByID(requestID)
+	if err != nil {
+		return nil, err
+	}
+	if r.Status != DeletionPending {
+		return nil, conflictError("deletion_not_pending", "deletion request %d is already %s", requestID, r.Status)
+	}
+	now := time.Now()
+	r.ReviewerID = reviewerID
+	r.ReviewedAt = &now
+	r.Status = DeletionRejected
+	if approve {
+		r.Status = DeletionApproved
+		if err := d.anonymizeMember(r.MemberID); err != nil {
+			return nil, err
+		}
+	}
+	return r, d.Save(r).Error
+}
+
+// discordTombstone hashes a Discord id, so an anonymized member can be recognized without keeping the id
+func discordTombstone(discordID string) string {
+	sum := sha256.Sum256([]byte("discord:" + discordID))
+	return hex.EncodeToString(sum[:])
+}
+
+// anonymizeMember removes everything identifying about a member. The member row stays, under a placeholder
+// name, so that the events they took part in still add up, and keeps a tombstone of their Discord id so the
+// guild sync does not add them back
+func (d *DB) anonymizeMember(memberID int) error {
+	var member Member
+	if err := d.Unscoped().First(&member, memberID).Error; err != nil {
+		return notFound(err, "member_not_found", "no member %d", memberID)
+	}
+	now := time.Now()
+	var updates = map[string]interface{}{
+		"name":          fmt.Sprintf("Deleted member #%d", memberID),
+		"slack":         nil,
+		"discord":       nil,
+		"destiny":       "",
+		"tz":            "",
+		"seen":          0,
+		"departed_at":   now,
+		"anonymized_at": now,
+	}
+	if member.Discord != "" {
+		updates["discord_tombstone"] = discordTombstone(member.Discord)
+	}
+	for _, p := range Platforms {
+		updates[p.Key] = ""
+	}
+	if err := d.Model(&Member{ID: memberID}).Updates(updates).Error; err != nil {
+		return err
+	}
+	for _, statement := range []string{
+		"DELETE FROM membermeta WHERE member_id = ?",
+		"DELETE FROM streams WHERE member_id = ?",
+		"DELETE FROM membergames WHERE member = ?",
+		"DELETE FROM game_role_grants WHERE member_id = ?",
+		"DELETE lm FROM lfg_members lm JOIN lfgs l ON (lm.lfg_id = l.id) WHERE l.host_id = ?",
+		"DELETE FROM lfgs WHERE host_id = ?",
+		"DELETE FROM lfg_members WHERE member_id = ?",
+		"DELETE FROM member_privacies WHERE member_id = ?",
+		"DELETE FROM member_activities WHERE member_id = ?",
+		"DELETE FROM login_histories WHERE member_id = ?",
+		"DELETE FROM logins WHERE member_id = ?",
+		"UPDATE audit_logs SET `before` = NULL, `after` = NULL WHERE target_id = ?",
+		"UPDATE audit_logs SET ip = '', user_agent = '' WHERE actor_id = ?",
+	} {
+		if err := d.Exec(statement, memberID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// MemberExport is everything the dashboard stores about a member, apart from the friends roster which lives in bolt
+type MemberExport struct {
+	ExportedAt time.Time                  `json:"exportedAt"`
+	Member     *Member                    `json:"member"`
+	Platforms  map[string]string          `json:"platforms"`
+	TZ         string                     `json:"tz"`
+	LastSeen   *time.Time                 `json:"lastSeen"`
+	Meta       map[string]json.RawMessage `json:"meta"`
+	Privacy    map[string]Visibility      `json:"privacy"`
+	Stream     *Stream                    `json:"stream"`
+	Events     []ExportedEvent            `json:"events"`
+	PastEvents []*EventAttendance         `json:"pastEvents"`
+	Games      []ExportedGame             `json:"games"`
+	Activity   []*MemberActivity          `json:"activity"`
+	Logins     []*LoginHistory            `json:"logins"`
+	Deletions  []*DeletionRequest         `json:"deletionRequests"`
+}
+
+// ExportedEvent is one slot a member filled in an event
+type ExportedEvent struct {
+	EventID  uint       `json:"eventID"`
+	Title    string     `json:"title"`
+	When     *time.Time `json:"when"`
+	Type     int        `json:"type"`
+	JoinedAt time.Time  `json:"joinedAt"`
+}
+
+// ExportedGame is a game a member has played
+type ExportedGame struct {
+	GameID int       `json:"gameID"`
+	Name   string    `json:"name"`
+	Played time.Time `json:"played"`
+}
+
+// ExportMember gathers everything stored about a member
+func (d *DB) ExportMember(memberID int) (*MemberExport, error) {
+	member, err := d.MemberByID(memberID)
+	if err != nil {
+		return nil, err
+	}
+	var rval = &MemberExport{
+		ExportedAt: time.Now(),
+		Member:     member,
+		Platforms:  member.PlatformIDs(),
+		TZ:         member.TZ,
+		Events:     []ExportedEvent{},
+		PastEvents: []*EventAttendance{},
+		Games:      []ExportedGame{},
+		Activity:   []*MemberActivity{},
+		Deletions:  []*DeletionRequest{},
+	}
+	if member.Seen > 0 {
+		seen := time.Unix(int64(member.Seen), 0)
+		rval.LastSeen = &seen
+	}
+	if rval.Meta, err = d.MemberMetaJSON(memberID); err != nil {
+		return nil, err
+	}
+	if rval.Privacy, err = d.MemberPrivacySettings(memberID); err != nil {
+		return nil, err
+	}
+	if stream, err := d.StreamByMemberID(memberID); err == nil {
+		rval.Stream = stream
+	}
+
+	rows, err := d.Raw("SELECT e.id, e.title, e.`when`, em.type, em.created_at FROM event_members em JOIN events e ON (e.id = em.event_id) WHERE em.member_id = ? AND em.deleted_at IS NULL ORDER BY em.created_at", memberID).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e ExportedEvent
+		if err := rows.Scan(&e.EventID, &e.Title, &e.When, &e.Type, &e.JoinedAt); err != nil {
+			return nil, err
+		}
+		rval.Events = append(rval.Events, e)
+	}
+
+	if err := d.Where("member_id = ?", memberID).Order("`when`").Find(&rval.PastEvents).Error; err != nil {
+		return nil, err
+	}
+
+	games, err := d.Raw("SELECT g.id, g.name, mg.played FROM membergames mg JOIN games g ON (g.id = mg.game) WHERE mg.member = ? ORDER BY mg.played DESC", memberID).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer games.Close()
+	for games.Next() {
+		var g ExportedGame
+		if err := games.Scan(&g.GameID, &g.Name, &g.Played); err != nil {
+			return nil, err
+		}
+		rval.Games = append(rval.Games, g)
+	}
+
+	if err := d.Where("member_id = ?", memberID).Order("hour").Find(&rval.Activity).Error; err != nil {
+		return nil, err
+	}
+	if rval.Logins, err = d.MemberLoginHistory(memberID); err != nil {
+		return nil, err
+	}
+	if err := d.Where("member_id = ?", memberID).Order("created_at").Find(&rval.Deletions).Error; err != nil {
+		return nil, err
+	}
+	return rval, nil
+}
@@ -0,0 +1,261 @@
+package db
+
+import (
+	"encoding/json"
+
+	"github.com/FederationOfFathers/dashboard/roster"
+	"go.uber.org/zap"
+)
+
+// MergePlan describes what merging one member into another changes. Where both members have
+// a value the member being merged into keeps its own
+type MergePlan struct {
+	From *Member `json:"from"`
+	Into *Member `json:"into"`
+	// Fields lists the profile fields copied from From because Into has no value, by column name
+	Fields map[string]string `json:"fields"`
+	// StreamMoved is true when From's stream row moves to Into. StreamMerged is true when both
+	// have one and From's identifiers fill in Into's blanks before it is deleted
+	StreamMoved  bool `json:"streamMoved"`
+	StreamMerged bool `json:"streamMerged"`
+	Meta         int  `json:"meta"`
+	// MetaConflicts lists the meta keys both members have, which keep Into's value
+	MetaConflicts []string `json:"metaConflicts"`
+	Games         int      `json:"games"`
+	// GameConflicts counts games both members played, which keep the latest played time
+	GameConflicts int `json:"gameConflicts"`
+	Events        int `json:"events"`
+	// EventConflicts counts events both members joined, where From's spot is dropped
+	EventConflicts int `json:"eventConflicts"`
+	Logins         int `json:"logins"`
+	Privacy        int `json:"privacy"`
+	// Friends counts the members on From's roster, and Followers the rosters From is on. Both move to Into
+	Friends   int  `json:"friends"`
+	Followers int  `json:"followers"`
+	DryRun    bool `json:"dryRun"`
+}
+
+// mergeFields lists the member columns a merge fills in, with their accessors
+var mergeFields = []struct {
+	column string
+	field  func(m *Member) *string
+}{
+	{"slack", func(m *Member) *string { return &m.Slack }},
+	{"discord", func(m *Member) *string { return &m.Discord }},
+	{"name", func(m *Member) *string { return &m.Name }},
+	{"tz", func(m *Member) *string { return &m.TZ }},
+	{"destiny", func(m *Member) *string { return &m.Destiny }},
+}
+
+// PlanMemberMerge describes what merging the member fromID into the member intoID would change, without changing anything
+func (d *DB) PlanMemberMerge(fromID, intoID int) (*MergePlan, error) {
+	if fromID == intoID {
+		return nil, invalidError("same_member", "a member cannot be merged into itself")
+	}
+	from, err := d.MemberByID(fromID)
+	if err != nil {
+		return nil, err
+	}
+	into, err := d.MemberByID(intoID)
+	if err != nil {
+		return nil, err
+	}
+	var plan = &MergePlan{
+		From:          from,
+		Into:          into,
+		Fields:        map[string]string{},
+		MetaConflicts: []string{},
+		DryRun:        true,
+	}
+	for _, f := range mergeFields {
+		if *f.field(into) == "" && *f.field(from) != "" {
+			plan.Fields[f.column] = *f.field(from)
+		}
+	}
+	for _, p := range Platforms {
+		if *p.field(into) == "" && *p.field(from) != "" {
+			plan.Fields[p.Key] = *p.field(from)
+		}
+	}
+
+	plan.Friends = len(roster.Get(from.ID))
+	plan.Followers = len(roster.Followers(from.ID))
+
+	var streams int
+	if err := d.Model(&Stream{}).Where("member_id = ?", from.ID).Count(&streams).Error; err != nil {
+		return nil, err
+	}
+	if streams > 0 {
+		var intoStreams int
+		if err := d.Model(&Stream{}).Where("member_id = ?", into.ID).Count(&intoStreams).Error; err != nil {
+			return nil, err
+		}
+		plan.StreamMerged = intoStreams > 0
+		plan.StreamMoved = !plan.StreamMerged
+	}
+
+	var counts = []struct {
+		dst   *int
+		query string
+		args  []interface{}
+	}{
+		{&plan.Meta, "SELECT COUNT(*) FROM membermeta WHERE member_id = ?", []interface{}{from.ID}},
+		{&plan.Games, "SELECT COUNT(*) FROM membergames WHERE member = ?", []interface{}{from.ID}},
+		{&plan.GameConflicts, "SELECT COUNT(*) FROM membergames f JOIN membergames i ON (i.game = f.game AND i.member = ?) WHERE f.member = ?", []interface{}{into.ID, from.ID}},
+		{&plan.Events, "SELECT COUNT(*) FROM event_members WHERE member_id = ? AND deleted_at IS NULL", []interface{}{from.ID}},
+		{&plan.EventConflicts, "SELECT COUNT(*) FROM event_members f JOIN event_members i ON (i.event_id = f.event_id AND i.member_id = ? AND i.deleted_at IS NULL) WHERE f.member_id = ? AND f.deleted_at IS NULL", []interface{}{into.ID, from.ID}},
+		{&plan.Logins, "SELECT COUNT(*) FROM logins WHERE member_id = ?", []interface{}{from.ID}},
+		{&plan.Privacy, "SELECT COUNT(*) FROM member_privacies WHERE member_id = ?", []interface{}{from.ID}},
+	}
+	for _, c := range counts {
+		if err := d.Raw(c.query, c.args...).Row().Scan(c.dst); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := d.Raw("SELECT f.meta_key FROM membermeta f JOIN membermeta i ON (i.meta_key = f.meta_key AND i.member_id = ?) WHERE f.member_id = ?", into.ID, from.ID).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		plan.MetaConflicts = append(plan.MetaConflicts, key)
+	}
+	return plan, rows.Err()
+}
+
+// MergeMembers merges the member fromID into the member intoID in a single transaction. Everything belonging to
+// From moves to Into, Into keeps its own value wherever both have one, and From is deleted. When audit is not nil
+// it is completed and recorded in the same transaction. The friends roster lives outside the database, so it is
+// moved once the transaction has committed and a failure there is logged rather than failing the merge
+func (d *DB) MergeMembers(fromID, intoID int, audit *AuditLog) (*MergePlan, error) {
+	tx := &DB{DB: d.Begin()}
+	if err := tx.Error; err != nil {
+		return nil, err
+	}
+	plan, err := tx.mergeMembers(fromID, intoID, audit)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	if plan.Friends, plan.Followers, err = roster.Merge(fromID, intoID); err != nil {
+		Logger.Error("unable to merge roster", zap.Int("from", fromID), zap.Int("into", intoID), zap.Error(err))
+	}
+	return plan, nil
+}
+
+func (d *DB) mergeMembers(fromID, intoID int, audit *AuditLog) (*MergePlan, error) {
+	plan, err := d.PlanMemberMerge(fromID, intoID)
+	if err != nil {
+		return nil, err
+	}
+	plan.DryRun = false
+	from, into := plan.From, plan.Into
+	before, err := json.Marshal(map[string]*Member{"from": from, "into": into})
+	if err != nil {
+		return nil, err
+	}
+
+	if plan.StreamMerged {
+		var fromStream, intoStream Stream
+		if err := d.Where("member_id = ?", from.ID).First(&fromStream).Error; err != nil {
+			return nil, err
+		}
+		if err := d.Where("member_id = ?", into.ID).First(&intoStream).Error; err != nil {
+			return nil, err
+		}
+		var fill = map[string]interface{}{}
+		if intoStream.Twitch == "" && fromStream.Twitch != "" {
+			fill["twitch"] = fromStream.Twitch
+		}
+		if intoStream.Youtube == "" && fromStream.Youtube != "" {
+			fill["youtube"] = fromStream.Youtube
+		}
+		// the unique service ids must leave From's row before Into's can take them
+		if err := d.Delete(&fromStream).Error; err != nil {
+			return nil, err
+		}
+		if len(fill) > 0 {
+			if err := d.Model(&intoStream).Updates(fill).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var statements = []struct {
+		query string
+		args  []interface{}
+	}{
+		{"UPDATE streams SET member_id = ? WHERE member_id = ?", []interface{}{into.ID, from.ID}},
+		{"DELETE f FROM membermeta f JOIN membermeta i ON (i.meta_key = f.meta_key AND i.member_id = ?) WHERE f.member_id = ?", []interface{}{into.ID, from.ID}},
+		{"UPDATE membermeta SET member_id = ? WHERE member_id = ?", []interface{}{into.ID, from.ID}},
+		{"UPDATE membergames i JOIN membergames f ON (f.game = i.game AND f.member = ?) SET i.played = GREATEST(i.played, f.played) WHERE i.member = ?", []interface{}{from.ID, into.ID}},
+		{"DELETE f FROM membergames f JOIN membergames i ON (i.game = f.game AND i.member = ?) WHERE f.member = ?", []interface{}{into.ID, from.ID}},
+		{"UPDATE membergames SET member = ? WHERE member = ?", []interface{}{into.ID, from.ID}},
+		{"DELETE f FROM game_role_grants f JOIN game_role_grants i ON (i.role_id = f.role_id AND i.member_id = ?) WHERE f.member_id = ?", []interface{}{into.ID, from.ID}},
+		{"UPDATE game_role_grants SET member_id = ? WHERE member_id = ?", []interface{}{into.ID, from.ID}},
+		{"UPDATE lfgs SET host_id = ? WHERE host_id = ?", []interface{}{into.ID, from.ID}},
+		{"DELETE f FROM lfg_members f JOIN lfg_members i ON (i.lfg_id = f.lfg_id AND i.member_id = ?) WHERE f.member_id = ?", []interface{}{into.ID, from.ID}},
+		{"UPDATE lfg_members SET member_id = ? WHERE member_id = ?", []interface{}{into.ID, from.ID}},
+		{"UPDATE event_members f JOIN event_members i ON (i.event_id = f.event_id AND i.member_id = ? AND i.deleted_at IS NULL) SET f.deleted_at = NOW() WHERE f.member_id = ? AND f.deleted_at IS NULL", []interface{}{into.ID, from.ID}},
+		{"UPDATE event_members SET member_id = ? WHERE member_id = ?", []interface{}{into.ID, from.ID}},
+		{"DELETE f FROM event_attendances f JOIN event_attendances i ON (i.event_id = f.event_id AND i.member_id = ?) WHERE f.member_id = ?", []interface{}{into.ID, from.ID}},
+		{"UPDATE event_attendances SET member_id = ? WHERE member_id = ?", []interface{}{into.ID, from.ID}},
+		{"UPDATE logins SET member_id = ? WHERE member_id = ?", []interface{}{into.ID, from.ID}},
+		{"UPDATE login_histories SET member_id = ? WHERE member_id = ?", []interface{}{into.ID, from.ID}},
+		{"DELETE f FROM member_privacies f JOIN member_privacies i ON (i.field = f.field AND i.member_id = ?) WHERE f.member_id = ?", []interface{}{into.ID, from.ID}},
+		{"UPDATE member_privacies SET member_id = ? WHERE member_id = ?", []interface{}{into.ID, from.ID}},
+		{"UPDATE member_activities i JOIN member_activities f ON (f.hour = i.hour AND f.source = i.source AND f.member_id = ?) SET i.count = i.count + f.count WHERE i.member_id = ?", []interface{}{from.ID, into.ID}},
+		{"DELETE f FROM member_activities f JOIN member_activities i ON (i.hour = f.hour AND i.source = f.source AND i.member_id = ?) WHERE f.member_id = ?", []interface{}{into.ID, from.ID}},
+		{"UPDATE member_activities SET member_id = ? WHERE member_id = ?", []interface{}{into.ID, from.ID}},
+	}
+	for _, statement := range statements {
+		if err := d.Exec(statement.query, statement.args...).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	// From gives up its unique slack and discord ids before it is deleted so that Into can take them
+	if err := d.Model(from).Updates(map[string]interface{}{"slack": nil, "discord": nil}).Error; err != nil {
+		return nil, err
+	}
+	if err := d.Delete(from).Error; err != nil {
+		return nil, err
+	}
+	if len(plan.Fields) > 0 {
+		var updates = map[string]interface{}{}
+		for k, v := range plan.Fields {
+			updates[k] = v
+		}
+		if err := d.Model(into).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+	if from.Seen > into.Seen {
+		if err := d.Model(into).Update("seen", from.Seen).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if audit != nil {
+		after, err := json.Marshal(plan)
+		if err != nil {
+			return nil, err
+		}
+		audit.TargetID = into.ID
+		audit.Action = "member.merge"
+		audit.Before = before
+		audit.After = after
+		if err := d.RecordAudit(audit); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
@@ -1,18 +1,47 @@
 package db
 
-import "time"
+import (
+	"time"
 
+	"go.uber.org/zap"
+)
+
+// MemberMeta is one meta value of a member. MetaValue holds JSON, apart from plain strings written before meta was typed
 type MemberMeta struct {
 	ID        int    `sql:"bigint(20) NOT NULL AUTO_INCREMENT"`
 	MemberID  int    `sql:"bigint(20) NOT NULL" gorm:"unique_index:user_meta_key"`
 	MetaKey   string `gorm:"type:varchar(191);not null;unique_index:user_meta_key;index:meta_key"`
-	MetaJSON  []byte
+	MetaValue string `gorm:"type:text"`
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	DeletedAt *time.Time
 	db        *DB `gorm:"-"`
 }
 
+// TableName is the table the meta values have always been kept in
+func (MemberMeta) TableName() string {
+	return "membermeta"
+}
+
 func (m *MemberMeta) Save() error {
 	return m.db.Save(m).Error
 }
+
+// MemberMetaValues returns the meta values for a member keyed by meta key
+func (d *DB) MemberMetaValues(memberID int) (map[string]string, error) {
+	var out = map[string]string{}
+	rows, err := d.Raw("SELECT meta_key,meta_value FROM membermeta WHERE member_id = ?", memberID).Rows()
+	if err != nil {
+		return out, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k string
+		var v string
+		if err := rows.Scan(&k, &v); err != nil {
+			Logger.Error("scanning member meta", zap.Int("member", memberID), zap.Error(err))
+			continue
+		}
+		out[k] = v
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,231 @@
+package db
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Platform describes a gaming platform identity which members can add to their profile
+type Platform struct {
+	// Key is the name used for the platform in the API and the column name in the members table
+	Key     string
+	Label   string
+	Example string
+	// pattern is the format identities must match. Platforms without one accept anything
+	pattern   *regexp.Regexp
+	normalize func(string) string
+	field     func(m *Member) *string
+}
+
+// Platforms lists every platform identity a member may have, in display order
+var Platforms = []Platform{
+	{
+		Key:   "xbl",
+		Label: "Xbox Live",
+		field: func(m *Member) *string { return &m.Xbl },
+	},
+	{
+		Key:   "psn",
+		Label: "PlayStation Network",
+		field: func(m *Member) *string { return &m.Psn },
+	},
+	{
+		Key:     "steam",
+		Label:   "Steam",
+		Example: "76561197960287930",
+		pattern: regexp.MustCompile(`^7656119[0-9]{10}$`),
+		field:   func(m *Member) *string { return &m.Steam },
+	},
+	{
+		Key:     "battlenet",
+		Label:   "Battle.net",
+		Example: "Player#1234",
+		pattern: regexp.MustCompile(`^\pL[\pL\pN]{2,11}#[0-9]{4,6}$`),
+		field:   func(m *Member) *string { return &m.Battlenet },
+	},
+	{
+		Key:     "epic",
+		Label:   "Epic Games",
+		Example: "Player_One",
+		pattern: regexp.MustCompile(`^[\pL\pN ._-]{3,16}$`),
+		field:   func(m *Member) *string { return &m.Epic },
+	},
+	{
+		Key:       "switch",
+		Label:     "Nintendo Switch",
+		Example:   "SW-1234-5678-9012",
+		pattern:   regexp.MustCompile(`^SW-[0-9]{4}-[0-9]{4}-[0-9]{4}$`),
+		normalize: normalizeSwitchCode,
+		field:     func(m *Member) *string { return &m.Switch },
+	},
+	{
+		Key:     "ea",
+		Label:   "EA",
+		Example: "Player-One",
+		pattern: regexp.MustCompile(`^[A-Za-z0-9_-]{4,16}$`),
+		field:   func(m *Member) *string { return &m.EA },
+	},
+	{
+		Key:     "riot",
+		Label:   "Riot",
+		Example: "Player#EUW",
+		pattern: regexp.MustCompile(`^[\pL\pN ]{3,16}#[\pL\pN]{3,5}$`),
+		field:   func(m *Member) *string { return &m.Riot },
+	},
+}
+
+var switchDigits = regexp.MustCompile(`^[0-9]{12}$`)
+
+// normalizeSwitchCode accepts friend codes with or without the SW- prefix and dashes
+func normalizeSwitchCode(code string) string {
+	digits := strings.NewReplacer("-", "", " ", "").Replace(strings.TrimPrefix(strings.ToUpper(code), "SW"))
+	if !switchDigits.MatchString(digits) {
+		return code
+	}
+	return "SW-" + digits[0:4] + "-" + digits[4:8] + "-" + digits[8:12]
+}
+
+// PlatformByKey returns the platform with the given key
+func PlatformByKey(key string) (Platform, bool) {
+	key = strings.ToLower(key)
+	for _, p := range Platforms {
+		if p.Key == key {
+			return p, true
+		}
+	}
+	return Platform{}, false
+}
+
+// Normalize trims an identity and puts it in the platform's canonical format, then validates it.
+// An empty identity is always valid, and clears the platform from a profile
+func (p Platform) Normalize(id string) (string, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return "", nil
+	}
+	if p.normalize != nil {
+		id = p.normalize(id)
+	}
+	if p.pattern != nil && !p.pattern.MatchString(id) {
+		return "", invalidError("invalid_"+p.Key, "%q is not a valid %s id, expected something like %s", id, p.Label, p.Example)
+	}
+	return id, nil
+}
+
+// PlatformID returns the member's identity on the platform with the given key
+func (m *Member) PlatformID(key string) string {
+	if p, ok := PlatformByKey(key); ok {
+		return *p.field(m)
+	}
+	return ""
+}
+
+// PlatformIDs returns every platform identity of the member, keyed by platform key
+func (m *Member) PlatformIDs() map[string]string {
+	var rval = map[string]string{}
+	for _, p := range Platforms {
+		rval[p.Key] = *p.field(m)
+	}
+	return rval
+}
+
+// SavePlatformIDs writes only the member's platform identities. Unlike Save it leaves the other columns alone, so
+// a Discord only member keeps a NULL slack id rather than an empty one which would collide with the unique index
+func (m *Member) SavePlatformIDs() error {
+	var updates = map[string]interface{}{}
+	for key, id := range m.PlatformIDs() {
+		updates[key] = id
+	}
+	return m.db.Model(m).Updates(updates).Error
+}
+
+// SetPlatformID validates and sets the member's identity on the platform with the given key. It does not save the member
+func (m *Member) SetPlatformID(key string, id string) error {
+	p, ok := PlatformByKey(key)
+	if !ok {
+		return invalidError("unknown_platform", "unknown platform %q", key)
+	}
+	id, err := p.Normalize(id)
+	if err != nil {
+		return err
+	}
+	*p.field(m) = id
+	return nil
+}
+
+// MemberByPlatformID finds the member with the given identity on the platform with the given key
+func (d *DB) MemberByPlatformID(key string, id string) (*Member, error) {
+	p, ok := PlatformByKey(key)
+	if !ok {
+		return nil, invalidError("unknown_platform", "unknown platform %q", key)
+	}
+	if normalized, err := p.Normalize(id); err == nil {
+		id = normalized
+	}
+	if id == "" {
+		return nil, notFoundError("member_not_found", "no member with an empty %s id", p.Label)
+	}
+	m := new(Member)
+	err := d.DB.Where(p.Key+" = ?", id).First(&m).Error
+	m.db = d
+	return m, notFound(err, "member_not_found", "no member with %s id %s", p.Label, id)
+}
+
+// PlatformCheck is a current member with an identity on a platform, and when a job last checked it
+type PlatformCheck struct {
+	Member     *Member
+	PlatformID string
+	Checked    time.Time
+}
+
+// PlatformChecks returns the current members with an identity on the platform, least recently checked first.
+// Checked is read from the system meta key checkedKey and is zero for members never checked
+func (d *DB) PlatformChecks(key string, checkedKey string) ([]*PlatformCheck, error) {
+	p, ok := PlatformByKey(key)
+	if !ok {
+		return nil, invalidError("unknown_platform", "unknown platform %q", key)
+	}
+	var members []*Member
+	if err := d.Where(p.Key + " != '' AND departed_at IS NULL AND anonymized_at IS NULL").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, m := range members {
+		ids = append(ids, m.ID)
+	}
+	var checked = map[int]time.Time{}
+	if len(ids) > 0 {
+		rows, err := d.Raw("SELECT member_id, meta_value FROM membermeta WHERE meta_key = ? AND member_id IN (?)", checkedKey, ids).Rows()
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var memberID int
+			var value string
+			if err := rows.Scan(&memberID, &value); err != nil {
+				return nil, err
+			}
+			var ts string
+			if json.Unmarshal([]byte(value), &ts) != nil {
+				ts = value
+			}
+			if t, err := time.Parse(time.RFC3339, ts); err == nil {
+				checked[memberID] = t
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	var rval = []*PlatformCheck{}
+	for _, m := range members {
+		m.db = d
+		rval = append(rval, &PlatformCheck{Member: m, PlatformID: *p.field(m), Checked: checked[m.ID]})
+	}
+	sort.SliceStable(rval, func(i, j int) bool { return rval[i].Checked.Before(rval[j].Checked) })
+	return rval, nil
+}
@@ -0,0 +1,61 @@
+package db
+
+import "testing"
+
+func TestPlatformNormalize(t *testing.T) {
+	for _, test := range []struct {
+		platform string
+		id       string
+		expected string
+		valid    bool
+	}{
+		{"steam", "76561197960287930", "76561197960287930", true},
+		{"steam", " 76561197960287930 ", "76561197960287930", true},
+		{"steam", "12345", "", false},
+		{"battlenet", "Player#1234", "Player#1234", true},
+		{"battlenet", "Player", "", false},
+		{"epic", "Player_One", "Player_One", true},
+		{"epic", "ab", "", false},
+		{"switch", "SW-1234-5678-9012", "SW-1234-5678-9012", true},
+		{"switch", "sw 1234 5678 9012", "SW-1234-5678-9012", true},
+		{"switch", "123456789012", "SW-1234-5678-9012", true},
+		{"switch", "SW-1234-5678", "", false},
+		{"ea", "Player-One", "Player-One", true},
+		{"ea", "Player One", "", false},
+		{"riot", "Player One#EUW", "Player One#EUW", true},
+		{"riot", "Player One", "", false},
+		{"xbl", "Any Thing", "Any Thing", true},
+		{"steam", "", "", true},
+	} {
+		p, ok := PlatformByKey(test.platform)
+		if !ok {
+			t.Fatalf("unknown platform %s", test.platform)
+		}
+		got, err := p.Normalize(test.id)
+		if test.valid && err != nil {
+			t.Errorf("%s %q: expected valid, got %s", test.platform, test.id, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s %q: expected invalid, got %q", test.platform, test.id, got)
+		}
+		if got != test.expected {
+			t.Errorf("%s %q: expected %q, got %q", test.platform, test.id, test.expected, got)
+		}
+	}
+}
+
+func TestSetPlatformID(t *testing.T) {
+	m := &Member{}
+	if err := m.SetPlatformID("Switch", "123456789012"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if m.Switch != "SW-1234-5678-9012" || m.PlatformID("switch") != m.Switch {
+		t.Errorf("expected the normalized friend code to be set, got %q", m.Switch)
+	}
+	if err := m.SetPlatformID("myspace", "tom"); err == nil {
+		t.Errorf("expected an error for an unknown platform")
+	}
+	if err := m.SetPlatformID("steam", "nope"); err == nil || m.Steam != "" {
+		t.Errorf("expected an invalid id to be rejected and left unset")
+	}
+}
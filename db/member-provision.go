@@ -0,0 +1,224 @@
+package db
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SyncDiscordMember makes sure a guild member has a member record with their current name. Members who had
+// left the guild are marked as back. It reports whether a new member was created. Members whose data was
+// deleted at their request are not added again, and a forbidden error is returned for them
+func (d *DB) SyncDiscordMember(discordID, name string) (*Member, bool, error) {
+	m, err := d.MemberByDiscordID(discordID)
+	if err == nil {
+		var updates = map[string]interface{}{}
+		if name != "" && m.Name != name {
+			updates["name"] = name
+		}
+		if m.DepartedAt != nil {
+			updates["departed_at"] = nil
+		}
+		if len(updates) == 0 {
+			return m, false, nil
+		}
+		if err := d.Model(m).Updates(updates).Error; err != nil {
+			return m, false, err
+		}
+		return m, false, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, false, err
+	}
+	var anonymized int
+	if err := d.Model(&Member{}).Where("discord_tombstone = ?", discordTombstone(discordID)).Count(&anonymized).Error; err != nil {
+		return nil, false, err
+	}
+	if anonymized > 0 {
+		return nil, false, forbiddenError("member_anonymized", "the member with Discord id %s asked for their data to be deleted", discordID)
+	}
+	m = NewMember(d)
+	m.Discord = discordID
+	m.Name = name
+	// slack is left NULL, as an empty string would collide with the unique index
+	if err := d.Omit("slack").Create(m).Error; err != nil {
+		return nil, false, err
+	}
+	return m, true, nil
+}
+
+// MarkMemberDeparted flags the member with the Discord id as having left the guild
+func (d *DB) MarkMemberDeparted(discordID string) error {
+	return d.Model(&Member{}).
+		Where("discord = ? AND departed_at IS NULL", discordID).
+		Update("departed_at", time.Now()).Error
+}
+
+// MarkMembersDepartedExcept flags every member with a Discord id not in discordIDs as having left the guild, and
+// returns how many were flagged. An empty list flags nobody, since it more likely means the guild could not be read
+func (d *DB) MarkMembersDepartedExcept(discordIDs []string) (int64, error) {
+	if len(discordIDs) == 0 {
+		return 0, nil
+	}
+	rval := d.Model(&Member{}).
+		Where("discord IS NOT NULL AND discord != '' AND departed_at IS NULL AND discord NOT IN (?)", discordIDs).
+		Update("departed_at", time.Now())
+	return rval.RowsAffected, rval.Error
+}
+
+// GuildMember is a Discord guild member to provision, by Discord id and the name they go by
+type GuildMember struct {
+	Discord string
+	Name    string
+}
+
+// GuildSync counts what SyncDiscordMembers changed
+type GuildSync struct {
+	Created  int
+	Renamed  int
+	Returned int
+	// Anonymized counts guild members who asked for their data to be deleted, and were not added again
+	Anonymized int
+}
+
+// guildSyncBatch is how many rows are read or written in one statement
+const guildSyncBatch = 500
+
+// guildSyncPlan is what a guild sync writes
+type guildSyncPlan struct {
+	create   []GuildMember
+	rename   map[int]string
+	returned []int
+	skipped  int
+}
+
+// planGuildSync works out which guild members need a member record, which have been renamed and which came
+// back. existing maps Discord ids to their members, and anonymized holds the tombstones of deleted members
+func planGuildSync(guild []GuildMember, existing map[string]*Member, anonymized map[string]bool) *guildSyncPlan {
+	var plan = &guildSyncPlan{rename: map[int]string{}}
+	var seen = map[string]bool{}
+	for _, g := range guild {
+		if g.Discord == "" || seen[g.Discord] {
+			continue
+		}
+		seen[g.Discord] = true
+		m, ok := existing[g.Discord]
+		switch {
+		case ok && m.DeletedAt != nil:
+		case ok:
+			if g.Name != "" && m.Name != g.Name {
+				plan.rename[m.ID] = g.Name
+			}
+			if m.DepartedAt != nil {
+				plan.returned = append(plan.returned, m.ID)
+			}
+		case anonymized[discordTombstone(g.Discord)]:
+			plan.skipped++
+		default:
+			plan.create = append(plan.create, g)
+		}
+	}
+	return plan
+}
+
+// SyncDiscordMembers does what SyncDiscordMember does for a whole guild roster, reading and writing members in
+// batches rather than one at a time
+func (d *DB) SyncDiscordMembers(guild []GuildMember) (*GuildSync, error) {
+	var ids, tombstones []string
+	for _, g := range guild {
+		ids = append(ids, g.Discord)
+		tombstones = append(tombstones, discordTombstone(g.Discord))
+	}
+	var existing = map[string]*Member{}
+	var anonymized = map[string]bool{}
+	for start := 0; start < len(ids); start += guildSyncBatch {
+		end := start + guildSyncBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		var members []*Member
+		if err := d.Unscoped().Where("discord IN (?)", ids[start:end]).Find(&members).Error; err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			existing[m.Discord] = m
+		}
+		var hashes []string
+		if err := d.Model(&Member{}).Where("discord_tombstone IN (?)", tombstones[start:end]).Pluck("discord_tombstone", &hashes).Error; err != nil {
+			return nil, err
+		}
+		for _, hash := range hashes {
+			anonymized[hash] = true
+		}
+	}
+	plan := planGuildSync(guild, existing, anonymized)
+	var rval = &GuildSync{Created: len(plan.create), Renamed: len(plan.rename), Returned: len(plan.returned), Anonymized: plan.skipped}
+	if len(plan.create) == 0 && len(plan.rename) == 0 && len(plan.returned) == 0 {
+		return rval, nil
+	}
+
+	tx := &DB{DB: d.Begin()}
+	if err := tx.Error; err != nil {
+		return nil, err
+	}
+	if err := tx.writeGuildSync(plan); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return rval, tx.Commit().Error
+}
+
+func (d *DB) writeGuildSync(plan *guildSyncPlan) error {
+	now := time.Now()
+	// slack is left NULL, as an empty string would collide with the unique index
+	for start := 0; start < len(plan.create); start += guildSyncBatch {
+		end := start + guildSyncBatch
+		if end > len(plan.create) {
+			end = len(plan.create)
+		}
+		var rows []string
+		var args []interface{}
+		for _, g := range plan.create[start:end] {
+			rows = append(rows, "(?,?,?,?)")
+			args = append(args, now, now, g.Discord, g.Name)
+		}
+		if err := d.Exec("INSERT INTO members (created_at,updated_at,discord,name) VALUES "+strings.Join(rows, ","), args...).Error; err != nil {
+			return err
+		}
+	}
+
+	var renamed []int
+	for id := range plan.rename {
+		renamed = append(renamed, id)
+	}
+	sort.Ints(renamed)
+	for start := 0; start < len(renamed); start += guildSyncBatch {
+		end := start + guildSyncBatch
+		if end > len(renamed) {
+			end = len(renamed)
+		}
+		var cases []string
+		var args []interface{}
+		for _, id := range renamed[start:end] {
+			cases = append(cases, "WHEN ? THEN ?")
+			args = append(args, id, plan.rename[id])
+		}
+		args = append(args, now, renamed[start:end])
+		query := "UPDATE members SET name = CASE id " + strings.Join(cases, " ") + " END, updated_at = ? WHERE id IN (?)"
+		if err := d.Exec(query, args...).Error; err != nil {
+			return err
+		}
+	}
+
+	for start := 0; start < len(plan.returned); start += guildSyncBatch {
+		end := start + guildSyncBatch
+		if end > len(plan.returned) {
+			end = len(plan.returned)
+		}
+		if err := d.Exec("UPDATE members SET departed_at = NULL, updated_at = ? WHERE id IN (?)", now, plan.returned[start:end]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPlanGuildSync(t *testing.T) {
+	then := time.Now()
+	existing := map[string]*Member{
+		"1": {ID: 10, Discord: "1", Name: "same"},
+		"2": {ID: 20, Discord: "2", Name: "old"},
+		"3": {ID: 30, Discord: "3", Name: "back", DepartedAt: &then},
+		"4": {ID: 40, Discord: "4", Name: "merged", DeletedAt: &then},
+	}
+	anonymized := map[string]bool{discordTombstone("6"): true}
+	plan := planGuildSync([]GuildMember{
+		{"1", "same"},
+		{"2", "new"},
+		{"3", "back"},
+		{"4", "merged"},
+		{"5", "joined"},
+		{"5", "joined"},
+		{"6", "deleted"},
+		{"", "nobody"},
+	}, existing, anonymized)
+
+	if !reflect.DeepEqual(plan.create, []GuildMember{{"5", "joined"}}) {
+		t.Errorf("expected only the new member to be created, got %v", plan.create)
+	}
+	if !reflect.DeepEqual(plan.rename, map[int]string{20: "new"}) {
+		t.Errorf("expected member 20 to be renamed, got %v", plan.rename)
+	}
+	if !reflect.DeepEqual(plan.returned, []int{30}) {
+		t.Errorf("expected member 30 to be back, got %v", plan.returned)
+	}
+	if plan.skipped != 1 {
+		t.Errorf("expected the anonymized member to be skipped, got %d", plan.skipped)
+	}
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+// MemberSearch narrows and orders a search of the members table. Zero values are not filtered on
+type MemberSearch struct {
+	// Query matches anywhere in the member's name or any of their platform ids the viewer may see
+	Query string
+	// Platforms only matches members who have an id the viewer may see on every one of these platforms
+	Platforms []string
+	// SeenSince only matches members seen at or after this time
+	SeenSince time.Time
+	// DiscordIn only matches members with one of these Discord ids when it is not nil
+	DiscordIn []string
+	// DiscordNotIn excludes members with any of these Discord ids
+	DiscordNotIn []string
+	// Sort is name, seen or id, prefixed with - to sort descending. Defaults to name
+	Sort    string
+	Page    int
+	PerPage int
+	// Viewer is who the search is for. Platform ids they may not see are neither matched nor filtered on
+	Viewer MemberSearchViewer
+}
+
+// MemberSearchViewer is the member a search is run for
+type MemberSearchViewer struct {
+	ID    int
+	Admin bool
+	// FriendOf lists the members who have the viewer on their roster
+	FriendOf []int
+}
+
+// platformClause returns a condition, and its args, for a platform id being visible to the viewer. Admins and the
+// owner see everything, friends see what is limited to friends, and everyone else only sees what has no setting
+func (v MemberSearchViewer) platformClause(key string) (string, []interface{}) {
+	if v.Admin {
+		return "TRUE", nil
+	}
+	hidden := "mp.visibility IN (?)"
+	args := []interface{}{[]string{string(VisibilityFriends), string(VisibilityAdmins)}}
+	if len(v.FriendOf) > 0 {
+		hidden = "(mp.visibility = ? OR (mp.visibility = ? AND mp.member_id NOT IN (?)))"
+		args = []interface{}{string(VisibilityAdmins), string(VisibilityFriends), v.FriendOf}
+	}
+	clause := "(members.id = ? OR NOT EXISTS (SELECT 1 FROM member_privacies mp " +
+		"WHERE mp.member_id = members.id AND mp.field = ? AND " + hidden + "))"
+	return clause, append([]interface{}{v.ID, key}, args...)
+}
+
+var memberSortColumns = map[string]string{
+	"name": "name",
+	"seen": "seen",
+	"id":   "id",
+}
+
+// ValidMemberSort reports whether sort may be used as MemberSearch.Sort
+func ValidMemberSort(sort string) bool {
+	_, ok := memberSortColumns[strings.TrimPrefix(sort, "-")]
+	return ok || sort == ""
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchMembers returns a page of members matching s and the total number of matching members
+func (d *DB) SearchMembers(s MemberSearch) ([]*Member, int, error) {
+	q := d.Model(&Member{}).Where("anonymized_at IS NULL")
+	if query := strings.TrimSpace(s.Query); query != "" {
+		like := "%" + likeEscaper.Replace(query) + "%"
+		var clauses = []string{"name LIKE ?"}
+		var args = []interface{}{like}
+		for _, p := range Platforms {
+			visible, visibleArgs := s.Viewer.platformClause(p.Key)
+			clauses = append(clauses, "("+p.Key+" LIKE ? AND "+visible+")")
+			args = append(append(args, like), visibleArgs...)
+		}
+		q = q.Where(strings.Join(clauses, " OR "), args...)
+	}
+	for _, key := range s.Platforms {
+		p, ok := PlatformByKey(key)
+		if !ok {
+			return nil, 0, invalidError("unknown_platform", "unknown platform %q", key)
+		}
+		visible, args := s.Viewer.platformClause(p.Key)
+		q = q.Where(p.Key+" != '' AND "+visible, args...)
+	}
+	if !s.SeenSince.IsZero() {
+		q = q.Where("seen >= ?", s.SeenSince.Unix())
+	}
+	if s.DiscordIn != nil {
+		if len(s.DiscordIn) == 0 {
+			return []*Member{}, 0, nil
+		}
+		q = q.Where("discord IN (?)", s.DiscordIn)
+	}
+	if len(s.DiscordNotIn) > 0 {
+		q = q.Where("(discord IS NULL OR discord NOT IN (?))", s.DiscordNotIn)
+	}
+
+	var total int
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	column, ok := memberSortColumns[strings.TrimPrefix(s.Sort, "-")]
+	if !ok {
+		column = "name"
+	}
+	order := column + " ASC"
+	if strings.HasPrefix(s.Sort, "-") {
+		order = column + " DESC"
+	}
+	if s.PerPage < 1 {
+		s.PerPage = 50
+	}
+	if s.Page < 1 {
+		s.Page = 1
+	}
+
+	var members = []*Member{}
+	err := q.Order(order).Order("id ASC").Offset((s.Page - 1) * s.PerPage).Limit(s.PerPage).Find(&members).Error
+	for _, m := range members {
+		m.db = d
+	}
+	return members, total, err
+}
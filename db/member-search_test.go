@@ -0,0 +1,33 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearchHidesPrivatePlatforms(t *testing.T) {
+	// a guild member with no friends can't match a gamertag limited to friends or admins
+	clause, args := MemberSearchViewer{ID: 7}.platformClause("xbl")
+	if !strings.Contains(clause, "NOT EXISTS") || !strings.Contains(clause, "mp.visibility IN (?)") {
+		t.Errorf("expected a stranger's search to exclude private ids, got %s", clause)
+	}
+	expected := []interface{}{7, "xbl", []string{"friends", "admins"}}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected args %v, got %v", expected, args)
+	}
+
+	// a friend may match ids limited to friends, but only of the members whose roster they are on
+	clause, args = MemberSearchViewer{ID: 7, FriendOf: []int{3, 4}}.platformClause("steam")
+	if !strings.Contains(clause, "mp.member_id NOT IN (?)") {
+		t.Errorf("expected friends limited ids to be excluded for everyone else, got %s", clause)
+	}
+	expected = []interface{}{7, "steam", "admins", "friends", []int{3, 4}}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected args %v, got %v", expected, args)
+	}
+
+	if clause, args := (MemberSearchViewer{ID: 7, Admin: true}).platformClause("psn"); clause != "TRUE" || len(args) != 0 {
+		t.Errorf("expected admins to match every id, got %s %v", clause, args)
+	}
+}
@@ -18,10 +18,22 @@ type Member struct {
 	Xbl       string     `gorm:"type:varchar(191);not null;default:'';index" json:"-"`
 	Psn       string     `gorm:"type:varchar(191);not null;default:''" json:"-"`
 	Destiny   string     `gorm:"type:varchar(191);not null;default:''" json:"-"`
+	Steam     string     `gorm:"type:varchar(191);not null;default:'';index" json:"steam"`
+	Battlenet string     `gorm:"type:varchar(191);not null;default:'';index" json:"battlenet"`
+	Epic      string     `gorm:"type:varchar(191);not null;default:'';index" json:"epic"`
+	Switch    string     `gorm:"type:varchar(191);not null;default:'';index" json:"switch"`
+	EA        string     `gorm:"type:varchar(191);not null;default:'';index" json:"ea"`
+	Riot      string     `gorm:"type:varchar(191);not null;default:'';index" json:"riot"`
 	Seen      int        `gorm:"type:bigint;not null;index;default:0" json:"-"`
 	Name      string     `gorm:"type:varchar(191);not null;default:''" json:"name"`
 	TZ        string     `gorm:"type:varchar(191);not null;default:''" json:"-"`
-	db        *DB        `gorm:"-"`
+	// DepartedAt is when the member left the Discord guild, nil while they are in it
+	DepartedAt *time.Time `gorm:"index" json:"departedAt,omitempty"`
+	// AnonymizedAt is when the member's data was deleted at their request, nil for everyone else
+	AnonymizedAt *time.Time `gorm:"index" json:"-"`
+	// DiscordTombstone is a hash of an anonymized member's Discord id, so they are not provisioned again
+	DiscordTombstone string `gorm:"type:varchar(64);not null;default:'';index" json:"-"`
+	db               *DB    `gorm:"-"`
 }
 
 func NewMember(db *DB) *Member {
@@ -41,7 +53,7 @@ func (d *DB) MemberByAny(some string) (*Member, error) {
 		}
 		return d.MemberByID(i)
 	}
-	if member, _ := d.MemberBySlackID(some); member != nil {
+	if member, err := d.MemberBySlackID(some); err == nil {
 		return member, nil
 	}
 	return d.MemberByName(some)
@@ -51,28 +63,28 @@ func (d *DB) MemberByID(id int) (*Member, error) {
 	m := new(Member)
 	err := d.First(&m, id).Error
 	m.db = d
-	return m, err
+	return m, notFound(err, "member_not_found", "no member with id %d", id)
 }
 
 func (d *DB) MemberByDiscordID(id string) (*Member, error) {
 	m := new(Member)
 	err := d.DB.Where("discord = ?", id).First(&m).Error
 	m.db = d
-	return m, err
+	return m, notFound(err, "member_not_found", "no member with discord id %s", id)
 }
 
 func (d *DB) MemberByName(name string) (*Member, error) {
 	m := new(Member)
 	err := d.DB.Where("name = ?", name).First(&m).Error
 	m.db = d
-	return m, err
+	return m, notFound(err, "member_not_found", "no member named %s", name)
 }
 
 func (d *DB) MemberBySlackID(id string) (*Member, error) {
 	m := new(Member)
 	err := d.DB.Where("slack = ?", id).First(&m).Error
 	m.db = d
-	return m, err
+	return m, notFound(err, "member_not_found", "no member with slack id %s", id)
 }
 
 func (d *DB) MembersActive(since time.Time) ([]*Member, error) {
@@ -86,7 +98,20 @@ func (d *DB) MembersActive(since time.Time) ([]*Member, error) {
 
 func (d *DB) Members() ([]*Member, error) {
 	m := []*Member{}
-	err := d.Find(&m).Error
+	err := d.Where("anonymized_at IS NULL").Find(&m).Error
+	for _, i := range m {
+		i.db = d
+	}
+	return m, err
+}
+
+// MembersByIDs returns the members with the given ids, by name. Anonymized members are left out
+func (d *DB) MembersByIDs(ids []int) ([]*Member, error) {
+	m := []*Member{}
+	if len(ids) == 0 {
+		return m, nil
+	}
+	err := d.Where("id IN (?) AND anonymized_at IS NULL", ids).Order("name").Find(&m).Error
 	for _, i := range m {
 		i.db = d
 	}
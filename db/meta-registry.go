@@ -0,0 +1,384 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// MetaWriter is who may write a meta key
+type MetaWriter string
+
+const (
+	// MetaWriteSelf keys may be written by the member and by admins
+	MetaWriteSelf MetaWriter = "self"
+	// MetaWriteAdmin keys may only be written by admins
+	MetaWriteAdmin MetaWriter = "admin"
+	// MetaWriteSystem keys are only written by the dashboard itself
+	MetaWriteSystem MetaWriter = "system"
+)
+
+// MetaReader is who may read a meta key
+type MetaReader string
+
+const (
+	// MetaReadAll keys may be read by any member, subject to the owner's privacy settings. It is the default
+	MetaReadAll MetaReader = ""
+	// MetaReadAdmin keys may only be read by admins, whatever the owner's privacy settings say
+	MetaReadAdmin MetaReader = "admin"
+)
+
+// MetaSchema is the subset of JSON schema used to validate meta values
+type MetaSchema struct {
+	Type      string      `json:"type"`
+	MaxLength int         `json:"maxLength,omitempty"`
+	Pattern   string      `json:"pattern,omitempty"`
+	Enum      []string    `json:"enum,omitempty"`
+	Minimum   *float64    `json:"minimum,omitempty"`
+	Maximum   *float64    `json:"maximum,omitempty"`
+	Items     *MetaSchema `json:"items,omitempty"`
+	MaxItems  int         `json:"maxItems,omitempty"`
+	pattern   *regexp.Regexp
+}
+
+// MetaKey describes a known member meta key
+type MetaKey struct {
+	Key         string      `json:"key"`
+	Description string      `json:"description"`
+	Schema      MetaSchema  `json:"schema"`
+	Default     interface{} `json:"default"`
+	Write       MetaWriter  `json:"write"`
+	Read        MetaReader  `json:"read,omitempty"`
+}
+
+// MetaKeys is the registry of meta keys members may have. Keys starting with _ are always system keys
+var MetaKeys = []MetaKey{
+	{
+		Key:         "bio",
+		Description: "A short introduction shown on the member's profile",
+		Schema:      MetaSchema{Type: "string", MaxLength: 500},
+		Default:     "",
+		Write:       MetaWriteSelf,
+	},
+	{
+		Key:         "pronouns",
+		Description: "The member's pronouns",
+		Schema:      MetaSchema{Type: "string", MaxLength: 40},
+		Default:     "",
+		Write:       MetaWriteSelf,
+	},
+	{
+		Key:         "timezone",
+		Description: "The member's IANA time zone, such as America/Chicago",
+		Schema:      MetaSchema{Type: "string", MaxLength: 64, Pattern: `^([A-Za-z_]+(/[A-Za-z0-9_+-]+)*|UTC)?$`},
+		Default:     "",
+		Write:       MetaWriteSelf,
+	},
+	{
+		Key:         "birthday",
+		Description: "The member's birthday as MM-DD",
+		Schema:      MetaSchema{Type: "string", Pattern: `^((0[1-9]|1[0-2])-(0[1-9]|[12][0-9]|3[01]))?$`},
+		Default:     "",
+		Write:       MetaWriteSelf,
+	},
+	{
+		Key:         "favoriteGames",
+		Description: "Names of the member's favorite games",
+		Schema:      MetaSchema{Type: "array", MaxItems: 10, Items: &MetaSchema{Type: "string", MaxLength: 100}},
+		Default:     []string{},
+		Write:       MetaWriteSelf,
+	},
+	{
+		Key:         "notifyFriendStreams",
+		Description: "Send the member a DM when someone on their friends list goes live",
+		Schema:      MetaSchema{Type: "boolean"},
+		Default:     false,
+		Write:       MetaWriteSelf,
+	},
+	{
+		Key:         "notifyFriendEvents",
+		Description: "Send the member a DM when someone on their friends list creates or joins an event",
+		Schema:      MetaSchema{Type: "boolean"},
+		Default:     false,
+		Write:       MetaWriteSelf,
+	},
+	{
+		Key:         "hideMilestones",
+		Description: "Leave the member out of membership anniversary and milestone announcements",
+		Schema:      MetaSchema{Type: "boolean"},
+		Default:     false,
+		Write:       MetaWriteSelf,
+	},
+	{
+		Key:         "hidePresenceGames",
+		Description: "Don't record the games the member plays from their Discord status",
+		Schema:      MetaSchema{Type: "boolean"},
+		Default:     false,
+		Write:       MetaWriteSelf,
+	},
+	{
+		Key:         NoGameRolesKey,
+		Description: "Never give the member game roles automatically from what they play",
+		Schema:      MetaSchema{Type: "boolean"},
+		Default:     false,
+		Write:       MetaWriteSelf,
+	},
+	{
+		Key:         "adminNote",
+		Description: "A note about the member kept by admins",
+		Schema:      MetaSchema{Type: "string", MaxLength: 2000},
+		Default:     "",
+		Write:       MetaWriteAdmin,
+		Read:        MetaReadAdmin,
+	},
+	{
+		Key:         "xuid",
+		Description: "The member's Xbox Live user id, looked up from their gamertag",
+		Schema:      MetaSchema{Type: "string"},
+		Write:       MetaWriteSystem,
+	},
+	{
+		Key:         "_xbl_corrected",
+		Description: "When the member last changed their gamertag, as a MySQL datetime read by the Xbox scraper",
+		Schema:      MetaSchema{Type: "string"},
+		Write:       MetaWriteSystem,
+	},
+	{
+		Key:         "_games_last_check",
+		Description: "When the member's Xbox games were last checked, written by the Xbox scraper",
+		Schema:      MetaSchema{Type: "string"},
+		Write:       MetaWriteSystem,
+	},
+	{
+		Key:         "_steam_last_check",
+		Description: "When the member's Steam library was last imported",
+		Schema:      MetaSchema{Type: "string"},
+		Write:       MetaWriteSystem,
+	},
+	{
+		Key:         "_xuid_last_check",
+		Description: "When the member's xuid was last looked up, written by the Xbox scraper",
+		Schema:      MetaSchema{Type: "string"},
+		Write:       MetaWriteSystem,
+	},
+}
+
+func init() {
+	for i := range MetaKeys {
+		MetaKeys[i].Schema.compile()
+	}
+}
+
+func (s *MetaSchema) compile() {
+	if s.Pattern != "" {
+		s.pattern = regexp.MustCompile(s.Pattern)
+	}
+	if s.Items != nil {
+		s.Items.compile()
+	}
+}
+
+// MetaKeyByName returns the registry entry for a meta key. Unknown keys starting with _ are treated as system keys
+func MetaKeyByName(key string) (MetaKey, bool) {
+	for _, k := range MetaKeys {
+		if k.Key == key {
+			return k, true
+		}
+	}
+	if strings.HasPrefix(key, "_") {
+		return MetaKey{Key: key, Write: MetaWriteSystem}, true
+	}
+	return MetaKey{}, false
+}
+
+// CanWrite reports whether a member writing their own meta, or an admin, may write the key through the API
+func (k MetaKey) CanWrite(self, admin bool) bool {
+	switch k.Write {
+	case MetaWriteSelf:
+		return self || admin
+	case MetaWriteAdmin:
+		return admin
+	}
+	return false
+}
+
+// CanRead reports whether a member, or an admin, may read the key through the API
+func (k MetaKey) CanRead(admin bool) bool {
+	return k.Read != MetaReadAdmin || admin
+}
+
+// ReadableMeta removes the values of keys only admins may read from meta values, unless admin is set
+func ReadableMeta(values map[string]json.RawMessage, admin bool) map[string]json.RawMessage {
+	var rval = map[string]json.RawMessage{}
+	for key, v := range values {
+		if k, ok := MetaKeyByName(key); !ok || k.CanRead(admin) {
+			rval[key] = v
+		}
+	}
+	return rval
+}
+
+// Validate checks a JSON encoded value against the key's schema
+func (k MetaKey) Validate(value json.RawMessage) error {
+	var v interface{}
+	if err := json.Unmarshal(value, &v); err != nil {
+		return invalidError("invalid_meta_value", "%s must be valid JSON", k.Key)
+	}
+	if k.Schema.Type == "" {
+		return nil
+	}
+	if err := k.Schema.validate(v); err != nil {
+		return invalidError("invalid_meta_value", "%s %s", k.Key, err)
+	}
+	return nil
+}
+
+func (s *MetaSchema) validate(v interface{}) error {
+	switch s.Type {
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("must be a string")
+		}
+		if s.MaxLength > 0 && len([]rune(str)) > s.MaxLength {
+			return fmt.Errorf("must be at most %d characters", s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(str) {
+			return fmt.Errorf("must match %s", s.Pattern)
+		}
+		if len(s.Enum) > 0 {
+			for _, e := range s.Enum {
+				if e == str {
+					return nil
+				}
+			}
+			return fmt.Errorf("must be one of %s", strings.Join(s.Enum, ", "))
+		}
+	case "integer", "number":
+		n, ok := v.(float64)
+		if !ok || (s.Type == "integer" && n != float64(int64(n))) {
+			return fmt.Errorf("must be a %s", s.Type)
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			return fmt.Errorf("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			return fmt.Errorf("must be at most %v", *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("must be true or false")
+		}
+	case "object":
+		if _, ok := v.(map[string]interface{}); !ok {
+			return fmt.Errorf("must be an object")
+		}
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("must be an array")
+		}
+		if s.MaxItems > 0 && len(items) > s.MaxItems {
+			return fmt.Errorf("must have at most %d items", s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range items {
+				if err := s.Items.validate(item); err != nil {
+					return fmt.Errorf("item %d %s", i, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// metaJSON returns a stored meta value as JSON. Values written before meta was typed are plain strings,
+// which are returned as JSON strings
+func metaJSON(key string, stored string) json.RawMessage {
+	k, known := MetaKeyByName(key)
+	isString := known && k.Schema.Type == "string"
+	if json.Valid([]byte(stored)) && (!isString || strings.HasPrefix(stored, `"`)) {
+		return json.RawMessage(stored)
+	}
+	buf, _ := json.Marshal(stored)
+	return buf
+}
+
+// MemberMetaJSON returns a member's meta values as JSON keyed by meta key, with the defaults of
+// unset registry keys filled in
+func (d *DB) MemberMetaJSON(memberID int) (map[string]json.RawMessage, error) {
+	var rval = map[string]json.RawMessage{}
+	values, err := d.MemberMetaValues(memberID)
+	if err != nil {
+		return rval, err
+	}
+	for k, v := range values {
+		rval[k] = metaJSON(k, v)
+	}
+	for _, k := range MetaKeys {
+		if _, ok := rval[k.Key]; ok || k.Default == nil {
+			continue
+		}
+		if buf, err := json.Marshal(k.Default); err == nil {
+			rval[k.Key] = buf
+		}
+	}
+	return rval, nil
+}
+
+// SetMemberMeta validates a JSON value against the registry and stores it. Write permissions are up to the caller
+func (d *DB) SetMemberMeta(memberID int, key string, value json.RawMessage) error {
+	k, ok := MetaKeyByName(key)
+	if !ok {
+		return invalidError("unknown_meta_key", "%q is not a known meta key", key)
+	}
+	if err := k.Validate(value); err != nil {
+		return err
+	}
+	return d.Exec(
+		"INSERT INTO membermeta (`member_id`,`meta_key`,`meta_value`) VALUES(?,?,?) ON DUPLICATE KEY UPDATE `meta_value` = ?",
+		memberID,
+		key,
+		string(value),
+		string(value),
+	).Error
+}
+
+// SetSystemMetaNow records the current time in a system meta key the dashboard owns, as a JSON RFC3339 string
+func (d *DB) SetSystemMetaNow(memberID int, key string) error {
+	buf, _ := json.Marshal(time.Now().UTC().Format(time.RFC3339))
+	return d.SetMemberMeta(memberID, key, buf)
+}
+
+// SetLegacyMetaNow records the current time in a system meta key also read by the Xbox scraper, in the MySQL
+// datetime format it has always been written in
+func (d *DB) SetLegacyMetaNow(memberID int, key string) error {
+	return d.Exec(
+		"INSERT INTO membermeta (`member_id`,`meta_key`,`meta_value`) VALUES(?,?,NOW()) ON DUPLICATE KEY UPDATE `meta_value` = NOW()",
+		memberID,
+		key,
+	).Error
+}
+
+// DeleteMemberMeta removes meta keys from a member
+func (d *DB) DeleteMemberMeta(memberID int, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return d.Exec("DELETE FROM membermeta WHERE member_id = ? AND meta_key IN (?)", memberID, keys).Error
+}
+
+// MembersWithMetaTrue returns the current members among memberIDs whose boolean meta key is set to true
+func (d *DB) MembersWithMetaTrue(key string, memberIDs []int) ([]*Member, error) {
+	var members = []*Member{}
+	if len(memberIDs) == 0 {
+		return members, nil
+	}
+	err := d.
+		Where("id IN (?) AND departed_at IS NULL AND anonymized_at IS NULL", memberIDs).
+		Where("id IN (SELECT member_id FROM membermeta WHERE meta_key = ? AND meta_value = 'true')", key).
+		Find(&members).Error
+	return members, err
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMetaKeyValidate(t *testing.T) {
+	for _, test := range []struct {
+		key   string
+		value string
+		valid bool
+	}{
+		{"bio", `"hello"`, true},
+		{"bio", `42`, false},
+		{"bio", `hello`, false},
+		{"birthday", `"04-21"`, true},
+		{"birthday", `"13-01"`, false},
+		{"birthday", `""`, true},
+		{"timezone", `"America/Chicago"`, true},
+		{"timezone", `"not a zone"`, false},
+		{"favoriteGames", `["Destiny 2","Halo"]`, true},
+		{"favoriteGames", `["Destiny 2",3]`, false},
+		{"favoriteGames", `"Destiny 2"`, false},
+		{"favoriteGames", `["1","2","3","4","5","6","7","8","9","10","11"]`, false},
+	} {
+		k, ok := MetaKeyByName(test.key)
+		if !ok {
+			t.Fatalf("unknown meta key %s", test.key)
+		}
+		err := k.Validate(json.RawMessage(test.value))
+		if test.valid && err != nil {
+			t.Errorf("%s %s: expected valid, got %s", test.key, test.value, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s %s: expected invalid", test.key, test.value)
+		}
+	}
+}
+
+func TestMetaKeyCanWrite(t *testing.T) {
+	bio, _ := MetaKeyByName("bio")
+	note, _ := MetaKeyByName("adminNote")
+	xuid, _ := MetaKeyByName("xuid")
+	internal, ok := MetaKeyByName("_anything")
+	if !ok {
+		t.Fatalf("expected _ keys to be known system keys")
+	}
+	if _, ok := MetaKeyByName("whatever"); ok {
+		t.Errorf("expected unknown keys to be rejected")
+	}
+	if !bio.CanWrite(true, false) || bio.CanWrite(false, false) || !bio.CanWrite(false, true) {
+		t.Errorf("expected bio to be writable by self and admins only")
+	}
+	if note.CanWrite(true, false) || !note.CanWrite(true, true) {
+		t.Errorf("expected adminNote to be writable by admins only")
+	}
+	if xuid.CanWrite(true, true) || internal.CanWrite(true, true) {
+		t.Errorf("expected system keys not to be writable")
+	}
+}
+
+func TestMetaJSON(t *testing.T) {
+	for _, test := range []struct {
+		key      string
+		stored   string
+		expected string
+	}{
+		{"bio", `"hello"`, `"hello"`},
+		{"bio", `hello`, `"hello"`},
+		{"xuid", `2533274800000000`, `"2533274800000000"`},
+		{"_xbl_corrected", `2020-01-01 00:00:00`, `"2020-01-01 00:00:00"`},
+		{"favoriteGames", `["Halo"]`, `["Halo"]`},
+		{"legacy", `true`, `true`},
+	} {
+		if got := string(metaJSON(test.key, test.stored)); got != test.expected {
+			t.Errorf("%s %s: expected %s, got %s", test.key, test.stored, test.expected, got)
+		}
+	}
+}
+
+func TestReadableMeta(t *testing.T) {
+	values := map[string]json.RawMessage{
+		"bio":       json.RawMessage(`"hi"`),
+		"adminNote": json.RawMessage(`"keep an eye out"`),
+		"legacy":    json.RawMessage(`"x"`),
+	}
+	got := ReadableMeta(values, false)
+	if _, ok := got["adminNote"]; ok || len(got) != 2 {
+		t.Errorf("expected the admin note to be hidden from members, got %v", got)
+	}
+	if got := ReadableMeta(values, true); len(got) != 3 {
+		t.Errorf("expected admins to read every key, got %v", got)
+	}
+}
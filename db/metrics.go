@@ -0,0 +1,23 @@
+package db
+
+import (
+	"github.com/FederationOfFathers/dashboard/metrics"
+	"github.com/jinzhu/gorm"
+)
+
+// countErrors returns a gorm callback which counts failed operations of the given kind. Missing records
+// are an expected outcome of lookups and are not counted
+func countErrors(operation string) func(scope *gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		if err := scope.DB().Error; err != nil && err != gorm.ErrRecordNotFound {
+			metrics.DBErrors.WithLabelValues(operation).Inc()
+		}
+	}
+}
+
+func (d *DB) registerMetrics() {
+	d.Callback().Create().Register("metrics:create_errors", countErrors("create"))
+	d.Callback().Update().Register("metrics:update_errors", countErrors("update"))
+	d.Callback().Delete().Register("metrics:delete_errors", countErrors("delete"))
+	d.Callback().Query().Register("metrics:query_errors", countErrors("query"))
+}
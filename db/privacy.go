@@ -0,0 +1,115 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+// Visibility is who may see a profile field or meta value
+type Visibility string
+
+const (
+	// VisibilityGuild is the default, every member of the guild may see the field
+	VisibilityGuild Visibility = "guild"
+	// VisibilityFriends limits the field to members on the owner's roster
+	VisibilityFriends Visibility = "friends"
+	// VisibilityAdmins limits the field to admins
+	VisibilityAdmins Visibility = "admins"
+)
+
+// metaPrivacyPrefix is prepended to meta keys to tell them apart from profile fields
+const metaPrivacyPrefix = "meta:"
+
+// MemberPrivacy is a member's visibility setting for one profile field or meta key. Fields without a row are visible to the guild
+type MemberPrivacy struct {
+	ID         uint       `gorm:"primary_key" json:"-"`
+	MemberID   int        `gorm:"not null;unique_index:member_privacy_field" json:"-"`
+	Field      string     `gorm:"type:varchar(191);not null;unique_index:member_privacy_field" json:"field"`
+	Visibility Visibility `gorm:"type:varchar(16);not null;default:'guild'" json:"visibility"`
+	UpdatedAt  time.Time  `json:"-"`
+}
+
+// ValidVisibility reports whether v is a known visibility
+func ValidVisibility(v Visibility) bool {
+	switch v {
+	case VisibilityGuild, VisibilityFriends, VisibilityAdmins:
+		return true
+	}
+	return false
+}
+
+// VisibleTo reports whether a field with this visibility may be seen by a viewer who is the
+// owner, is on the owner's roster or is an admin. Unknown visibilities are treated as guild
+func (v Visibility) VisibleTo(self, friend, admin bool) bool {
+	switch {
+	case self, admin:
+		return true
+	case v == VisibilityAdmins:
+		return false
+	case v == VisibilityFriends:
+		return friend
+	}
+	return true
+}
+
+// MetaPrivacyField returns the privacy field name for a meta key
+func MetaPrivacyField(key string) string {
+	return metaPrivacyPrefix + key
+}
+
+// ValidPrivacyField reports whether field is a platform key or a meta privacy field
+func ValidPrivacyField(field string) bool {
+	if strings.HasPrefix(field, metaPrivacyPrefix) {
+		return len(field) > len(metaPrivacyPrefix)
+	}
+	_, ok := PlatformByKey(field)
+	return ok && field == strings.ToLower(field)
+}
+
+// MemberPrivacySettings returns a member's visibility settings keyed by field
+func (d *DB) MemberPrivacySettings(memberID int) (map[string]Visibility, error) {
+	settings, err := d.MembersPrivacySettings([]int{memberID})
+	if err != nil {
+		return map[string]Visibility{}, err
+	}
+	if s, ok := settings[memberID]; ok {
+		return s, nil
+	}
+	return map[string]Visibility{}, nil
+}
+
+// MembersPrivacySettings returns the visibility settings of several members, keyed by member id and then field.
+// Members without any settings are left out
+func (d *DB) MembersPrivacySettings(memberIDs []int) (map[int]map[string]Visibility, error) {
+	var rval = map[int]map[string]Visibility{}
+	if len(memberIDs) == 0 {
+		return rval, nil
+	}
+	var rows []*MemberPrivacy
+	if err := d.Where("member_id IN (?)", memberIDs).Find(&rows).Error; err != nil {
+		return rval, err
+	}
+	for _, row := range rows {
+		if _, ok := rval[row.MemberID]; !ok {
+			rval[row.MemberID] = map[string]Visibility{}
+		}
+		rval[row.MemberID][row.Field] = row.Visibility
+	}
+	return rval, nil
+}
+
+// SetMemberPrivacy changes who may see one of a member's fields. Setting a field back to guild removes its row
+func (d *DB) SetMemberPrivacy(memberID int, field string, v Visibility) error {
+	if !ValidPrivacyField(field) {
+		return invalidError("unknown_field", "%q is not a platform or meta key", field)
+	}
+	if !ValidVisibility(v) {
+		return invalidError("invalid_visibility", "visibility must be guild, friends or admins, not %q", v)
+	}
+	if v == VisibilityGuild {
+		return d.Where("member_id = ? AND field = ?", memberID, field).Delete(MemberPrivacy{}).Error
+	}
+	var row MemberPrivacy
+	err := d.Where(MemberPrivacy{MemberID: memberID, Field: field}).Assign(MemberPrivacy{Visibility: v}).FirstOrCreate(&row).Error
+	return err
+}
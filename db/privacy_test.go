@@ -0,0 +1,42 @@
+package db
+
+import "testing"
+
+func TestVisibleTo(t *testing.T) {
+	for _, test := range []struct {
+		visibility Visibility
+		self       bool
+		friend     bool
+		admin      bool
+		expected   bool
+	}{
+		{VisibilityGuild, false, false, false, true},
+		{"", false, false, false, true},
+		{VisibilityFriends, false, false, false, false},
+		{VisibilityFriends, false, true, false, true},
+		{VisibilityFriends, true, false, false, true},
+		{VisibilityAdmins, false, true, false, false},
+		{VisibilityAdmins, false, false, true, true},
+		{VisibilityAdmins, true, false, false, true},
+	} {
+		if got := test.visibility.VisibleTo(test.self, test.friend, test.admin); got != test.expected {
+			t.Errorf("%q self=%v friend=%v admin=%v: expected %v, got %v", test.visibility, test.self, test.friend, test.admin, test.expected, got)
+		}
+	}
+}
+
+func TestValidPrivacyField(t *testing.T) {
+	for field, expected := range map[string]bool{
+		"steam":               true,
+		"xbl":                 true,
+		"Steam":               false,
+		"myspace":             false,
+		"meta:timezone":       true,
+		"meta:":               false,
+		MetaPrivacyField("x"): true,
+	} {
+		if got := ValidPrivacyField(field); got != expected {
+			t.Errorf("%q: expected %v, got %v", field, expected, got)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package db
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Recommendation is a member suggested to play with, and why
+type Recommendation struct {
+	MemberID int `json:"memberID"`
+	// Score orders recommendations, higher first. Each shared game counts for more than each hour of the day both
+	// members are usually active in. Those hours only rank members, as other members' activity is not shown
+	Score int `json:"score"`
+	// SharedGames are the games both members played recently
+	SharedGames []string `json:"sharedGames"`
+	Reasons     []string `json:"reasons"`
+}
+
+// GamePlayer is a member who played a game, and when they last did
+type GamePlayer struct {
+	Member *Member
+	Played time.Time
+}
+
+// Recommendations suggests members for memberID to play with, from the games they both played and the hours they
+// were both active since since. Departed and anonymized members, and those in exclude, are left out
+func (d *DB) Recommendations(memberID int, since time.Time, exclude []int, limit int) ([]*Recommendation, error) {
+	shared, err := d.sharedRecentGames(memberID, since)
+	if err != nil {
+		return nil, err
+	}
+	hours, err := d.activeHours(since)
+	if err != nil {
+		return nil, err
+	}
+	var current []int
+	err = d.Model(&Member{}).
+		Where("departed_at IS NULL AND anonymized_at IS NULL AND id <> ?", memberID).
+		Pluck("id", &current).Error
+	if err != nil {
+		return nil, err
+	}
+	return rankRecommendations(memberID, shared, hours, current, exclude, limit), nil
+}
+
+// sharedRecentGames maps other members to the names of games both they and memberID played since since
+func (d *DB) sharedRecentGames(memberID int, since time.Time) (map[int][]string, error) {
+	rows, err := d.Raw(strings.Join([]string{
+		"SELECT other.member, g.name",
+		"FROM membergames mine",
+		"JOIN membergames other ON (other.game = mine.game AND other.member <> mine.member)",
+		"JOIN games g ON (g.id = mine.game)",
+		"WHERE mine.member = ? AND mine.played >= ? AND other.played >= ?",
+		"ORDER BY g.name",
+	}, " "), memberID, since, since).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rval = map[int][]string{}
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		rval[id] = append(rval[id], name)
+	}
+	return rval, rows.Err()
+}
+
+// activeHours maps members to the hours of the day they were active in since since
+func (d *DB) activeHours(since time.Time) (map[int]map[int]bool, error) {
+	rows, err := d.Raw(
+		"SELECT member_id, HOUR(hour) FROM member_activities WHERE hour >= ? GROUP BY member_id, HOUR(hour)",
+		since,
+	).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rval = map[int]map[int]bool{}
+	for rows.Next() {
+		var id, hour int
+		if err := rows.Scan(&id, &hour); err != nil {
+			return nil, err
+		}
+		if rval[id] == nil {
+			rval[id] = map[int]bool{}
+		}
+		rval[id][hour] = true
+	}
+	return rval, rows.Err()
+}
+
+// rankRecommendations scores the current members other than memberID and those in exclude. Members with no shared
+// games are not recommended, and the hours both are usually active in break ties between the rest
+func rankRecommendations(memberID int, shared map[int][]string, hours map[int]map[int]bool, current, exclude []int, limit int) []*Recommendation {
+	var skip = map[int]bool{memberID: true}
+	for _, id := range exclude {
+		skip[id] = true
+	}
+	var rval = []*Recommendation{}
+	for _, id := range current {
+		if skip[id] {
+			continue
+		}
+		games := shared[id]
+		if len(games) == 0 {
+			continue
+		}
+		var overlap int
+		for hour := 0; hour < 24; hour++ {
+			if hours[memberID][hour] && hours[id][hour] {
+				overlap++
+			}
+		}
+		rval = append(rval, &Recommendation{
+			MemberID:    id,
+			Score:       25*len(games) + overlap,
+			SharedGames: append([]string{}, games...),
+			Reasons:     []string{"also played " + joinNames(games) + " recently"},
+		})
+	}
+	sort.SliceStable(rval, func(i, j int) bool {
+		if rval[i].Score != rval[j].Score {
+			return rval[i].Score > rval[j].Score
+		}
+		return rval[i].MemberID < rval[j].MemberID
+	})
+	if limit > 0 && len(rval) > limit {
+		rval = rval[:limit]
+	}
+	return rval
+}
+
+// joinNames lists names as "a", "a and b" or "a, b and c"
+func joinNames(names []string) string {
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+// GamePlayers lists the current members who played a game since since, most recent first
+func (d *DB) GamePlayers(gameID int, since time.Time) ([]GamePlayer, error) {
+	rows, err := d.Raw(strings.Join([]string{
+		"SELECT member, played FROM membergames",
+		"WHERE game = ? AND played >= ?",
+		"ORDER BY played DESC",
+	}, " "), gameID, since).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	var played = map[int]time.Time{}
+	for rows.Next() {
+		var id int
+		var when time.Time
+		if err := rows.Scan(&id, &when); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+		played[id] = when
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	var rval = []GamePlayer{}
+	if len(ids) == 0 {
+		return rval, nil
+	}
+	var members []*Member
+	if err := d.Where("id IN (?) AND departed_at IS NULL AND anonymized_at IS NULL", ids).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		m.db = d
+		rval = append(rval, GamePlayer{Member: m, Played: played[m.ID]})
+	}
+	sort.SliceStable(rval, func(i, j int) bool { return rval[i].Played.After(rval[j].Played) })
+	return rval, nil
+}
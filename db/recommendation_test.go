@@ -0,0 +1,45 @@
+package db
+
+import "testing"
+
+func TestRankRecommendations(t *testing.T) {
+	hours := func(hs ...int) map[int]bool {
+		var rval = map[int]bool{}
+		for _, h := range hs {
+			rval[h] = true
+		}
+		return rval
+	}
+	shared := map[int][]string{
+		2: {"Halo Infinite"},
+		3: {"Apex Legends", "Halo Infinite"},
+		4: {"Destiny 2"},
+		6: {"Halo Infinite"},
+	}
+	active := map[int]map[int]bool{
+		1: hours(18, 19, 20, 21),
+		2: hours(19, 20, 21),
+		5: hours(18, 19, 20),
+		6: hours(20),
+	}
+	got := rankRecommendations(1, shared, active, []int{1, 2, 3, 4, 5, 6}, []int{4}, 0)
+
+	var ids []int
+	for _, r := range got {
+		ids = append(ids, r.MemberID)
+	}
+	// 3 shares two games, 2 and 6 one game each with 2 ahead on hours, 4 is a friend and 5 only shares hours
+	if len(ids) != 3 || ids[0] != 3 || ids[1] != 2 || ids[2] != 6 {
+		t.Fatalf("unexpected order %v", ids)
+	}
+	if len(got[1].Reasons) != 1 || got[1].Score != 28 {
+		t.Errorf("expected only a game reason for member 2, got %+v", got[1])
+	}
+	if got[0].Reasons[0] != "also played Apex Legends and Halo Infinite recently" {
+		t.Errorf("unexpected reason %q", got[0].Reasons[0])
+	}
+
+	if limited := rankRecommendations(1, shared, active, []int{2, 3, 5}, nil, 1); len(limited) != 1 || limited[0].MemberID != 3 {
+		t.Errorf("expected only the top recommendation, got %+v", limited)
+	}
+}
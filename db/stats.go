@@ -0,0 +1,131 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+// TopGame is a game and how many recently seen members played it
+type TopGame struct {
+	ID           int             `json:"id"`
+	Name         string          `json:"name"`
+	Image        string          `json:"image"`
+	Platform     int             `json:"platform"`
+	PlatformInfo *GamingPlatform `json:"platformInfo,omitempty"`
+	Players      int             `json:"players"`
+}
+
+// PlayedGame is a game a member played, and when they last did
+type PlayedGame struct {
+	ID           int             `json:"id"`
+	Platform     int             `json:"platform"`
+	PlatformID   int             `json:"platform_id"`
+	PlatformInfo *GamingPlatform `json:"platformInfo,omitempty"`
+	Name         string          `json:"name"`
+	Image        string          `json:"image"`
+	Played       time.Time       `json:"played"`
+}
+
+// MemberCounts are head counts of the current membership
+type MemberCounts struct {
+	// Total counts members who have not left the Discord guild or been anonymized
+	Total int `json:"total"`
+	// Discord counts the members with a linked Discord account
+	Discord int `json:"discord"`
+	// Active7 and Active30 count the members seen in the last 7 and 30 days
+	Active7  int `json:"active7"`
+	Active30 int `json:"active30"`
+	// Joined30 counts the members added in the last 30 days
+	Joined30 int `json:"joined30"`
+	// Departed counts the members who left the Discord guild
+	Departed int `json:"departed"`
+}
+
+// TopGames ranks games by how many members seen since seenSince played them since since, most first
+func (d *DB) TopGames(since, seenSince time.Time, limit int) ([]*TopGame, error) {
+	rows, err := d.Raw(strings.Join([]string{
+		"SELECT g.id, g.name, g.platform, COUNT(mg.member) AS players",
+		"FROM membergames mg",
+		"JOIN games g ON (mg.game = g.id)",
+		"JOIN members m ON (mg.member = m.id)",
+		"WHERE mg.played > ? AND m.seen > ?",
+		"GROUP BY g.id ORDER BY players DESC LIMIT ?",
+	}, " "), since, seenSince.Unix(), limit).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rval = []*TopGame{}
+	for rows.Next() {
+		var row = &TopGame{}
+		if err := rows.Scan(&row.ID, &row.Name, &row.Platform, &row.Players); err != nil {
+			return nil, err
+		}
+		rval = append(rval, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	known, err := d.gamingPlatformsByCode()
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rval {
+		row.PlatformInfo = known[row.Platform]
+	}
+	return rval, nil
+}
+
+// MemberPlayedGames lists the games a member played since since, most recent first
+func (d *DB) MemberPlayedGames(memberID int, since time.Time) ([]*PlayedGame, error) {
+	rows, err := d.Raw(strings.Join([]string{
+		"SELECT g.id, g.platform, g.platform_id, g.name, g.image, mg.played",
+		"FROM membergames mg",
+		"JOIN games g ON (mg.game = g.id)",
+		"WHERE mg.member = ? AND mg.played >= ?",
+		"ORDER BY mg.played DESC",
+	}, " "), memberID, since).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rval = []*PlayedGame{}
+	for rows.Next() {
+		var row = &PlayedGame{}
+		if err := rows.Scan(&row.ID, &row.Platform, &row.PlatformID, &row.Name, &row.Image, &row.Played); err != nil {
+			return nil, err
+		}
+		rval = append(rval, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	known, err := d.gamingPlatformsByCode()
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rval {
+		row.PlatformInfo = known[row.Platform]
+	}
+	return rval, nil
+}
+
+// MemberCounts counts the current membership as of now
+func (d *DB) MemberCounts(now time.Time) (*MemberCounts, error) {
+	var rval = &MemberCounts{}
+	err := d.Raw(strings.Join([]string{
+		"SELECT",
+		"COALESCE(SUM(departed_at IS NULL), 0),",
+		"COALESCE(SUM(departed_at IS NULL AND discord IS NOT NULL AND discord <> ''), 0),",
+		"COALESCE(SUM(departed_at IS NULL AND seen > ?), 0),",
+		"COALESCE(SUM(departed_at IS NULL AND seen > ?), 0),",
+		"COALESCE(SUM(departed_at IS NULL AND created_at > ?), 0),",
+		"COALESCE(SUM(departed_at IS NOT NULL), 0)",
+		"FROM members WHERE anonymized_at IS NULL AND deleted_at IS NULL",
+	}, " "),
+		now.AddDate(0, 0, -7).Unix(),
+		now.AddDate(0, 0, -30).Unix(),
+		now.AddDate(0, 0, -30),
+	).Row().Scan(&rval.Total, &rval.Discord, &rval.Active7, &rval.Active30, &rval.Joined30, &rval.Departed)
+	return rval, err
+}
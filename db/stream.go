@@ -25,20 +25,29 @@ func (s *Stream) Save() error {
 	return s.db.Save(s).Error
 }
 
+// Identifier returns the stream's identifier on the given service, twitch or youtube
+func (s *Stream) Identifier(kind string) string {
+	switch kind {
+	case "twitch":
+		return s.Twitch
+	case "youtube":
+		return s.Youtube
+	}
+	return ""
+}
+
 func (d *DB) StreamByID(id int) (*Stream, error) {
 	s := new(Stream)
 	err := d.First(&s, id).Error
-	if err != nil {
-		s.db = d
-	}
-	return s, err
+	s.db = d
+	return s, notFound(err, "stream_not_found", "no stream with id %d", id)
 }
 
 func (d *DB) StreamByMemberID(memberID int) (*Stream, error) {
 	s := new(Stream)
 	err := d.Where("member_id = ?", memberID).First(&s).Error
 	s.db = d
-	return s, err
+	return s, notFound(err, "stream_not_found", "no stream for member %d", memberID)
 }
 
 func (d *DB) Streams() ([]*Stream, error) {
@@ -52,6 +52,9 @@ func purgeOldEvents() {
 
 	for _, e := range events {
 		if time.Since(*e.When) > time.Duration(time.Hour * 2) {
+			if err := DB.RecordEventAttendance(e); err != nil {
+				Logger.Error("unable to record event attendance", zap.Uint("event_id", e.ID), zap.Error(err))
+			}
 			DB.DeleteEvent(*e)
 		}
 	}
@@ -0,0 +1,175 @@
+package gamesync
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/FederationOfFathers/dashboard/clients/steam"
+	"github.com/FederationOfFathers/dashboard/db"
+	"go.uber.org/zap"
+)
+
+// SteamPlatform is the games platform code Steam games are recorded under. It is kept apart from PC so Steam app
+// ids never collide with the title ids the Xbox scraper records for PC games
+const SteamPlatform = db.PlatformSteam
+
+const steamCheckedKey = "_steam_last_check"
+
+var DB *db.DB
+var Logger *zap.Logger
+
+// Steam is the client used to import libraries. Importing is off while it is nil
+var Steam *steam.Client
+
+// Interval is how often each member's Steam library is imported
+var Interval = 24 * time.Hour
+
+// PerRun is how many members are imported each time the job runs, to stay well inside the Steam API limits
+var PerRun = 50
+
+// Mind imports Steam libraries every hour
+func Mind() {
+	go func() {
+		Run()
+		for range time.Tick(time.Hour) {
+			Run()
+		}
+	}()
+}
+
+// Run imports the Steam libraries of up to PerRun members who have not been imported within Interval. Members
+// who keep their Steam id from the guild are skipped, since what they play is shown to everyone
+func Run() {
+	if Steam == nil {
+		return
+	}
+	checks, err := DB.PlatformChecks("steam", steamCheckedKey)
+	if err != nil {
+		Logger.Error("unable to list steam members", zap.Error(err))
+		return
+	}
+	var ids []int
+	for _, check := range checks {
+		ids = append(ids, check.Member.ID)
+	}
+	settings, err := DB.MembersPrivacySettings(ids)
+	if err != nil {
+		Logger.Error("unable to read steam privacy", zap.Error(err))
+		return
+	}
+	checks = shared(checks, settings)
+	var imported int
+	for _, check := range checks {
+		if imported >= PerRun || time.Since(check.Checked) < Interval {
+			break
+		}
+		imported++
+		if err := ImportSteam(check.Member.ID, check.PlatformID); err != nil {
+			Logger.Error("unable to import steam library", zap.Int("member", check.Member.ID), zap.String("steam", check.PlatformID), zap.Error(err))
+			// record the attempt anyway so a library which keeps failing waits its turn instead of using up every run
+			if err := DB.SetSystemMetaNow(check.Member.ID, steamCheckedKey); err != nil {
+				Logger.Error("unable to record steam check", zap.Int("member", check.Member.ID), zap.Error(err))
+			}
+		}
+	}
+	if imported > 0 {
+		Logger.Info("imported steam libraries", zap.Int("members", imported))
+	}
+}
+
+// shared leaves out the members whose Steam id is not visible to the whole guild
+func shared(checks []*db.PlatformCheck, settings map[int]map[string]db.Visibility) []*db.PlatformCheck {
+	var rval = []*db.PlatformCheck{}
+	for _, check := range checks {
+		if v := settings[check.Member.ID]["steam"]; v == "" || v == db.VisibilityGuild {
+			rval = append(rval, check)
+		}
+	}
+	return rval
+}
+
+// ImportSteam records the games a member has played from their Steam library and recently played games.
+// Games the member owns but has never played are left out
+func ImportSteam(memberID int, steamID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	owned, err := Steam.OwnedGames(ctx, steamID)
+	if err != nil {
+		return err
+	}
+	recent, err := Steam.RecentlyPlayedGames(ctx, steamID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, g := range playedGames(owned, recent, now) {
+		game, err := steamGame(g)
+		if err != nil {
+			return err
+		}
+		if err := DB.RecordPlayed(memberID, game.ID, g.LastPlayedTime(), db.GameSourceSteam); err != nil {
+			return err
+		}
+	}
+	return DB.SetSystemMetaNow(memberID, steamCheckedKey)
+}
+
+// playedGames merges owned and recently played games, keeping those with a last played time. Recently played
+// games Steam gives no time for are taken as played now
+func playedGames(owned, recent []steam.Game, now time.Time) []steam.Game {
+	var byApp = map[int]steam.Game{}
+	var order []int
+	for _, g := range owned {
+		if g.LastPlayed > 0 {
+			byApp[g.AppID] = g
+			order = append(order, g.AppID)
+		}
+	}
+	for _, g := range recent {
+		existing, ok := byApp[g.AppID]
+		if !ok {
+			order = append(order, g.AppID)
+			existing = g
+		}
+		if existing.LastPlayed <= 0 {
+			existing.LastPlayed = now.Unix()
+		}
+		byApp[g.AppID] = existing
+	}
+	var rval = []steam.Game{}
+	for _, id := range order {
+		rval = append(rval, byApp[id])
+	}
+	return rval
+}
+
+// steamGame finds the catalog entry for a Steam game by app id, then by name, adding it when neither is known
+func steamGame(g steam.Game) (*db.Game, error) {
+	game, err := DB.GameByPlatformID(SteamPlatform, g.AppID)
+	if err == nil || !errors.Is(err, db.ErrNotFound) {
+		return game, err
+	}
+	name := g.Name
+	if name == "" {
+		name = "Steam app " + strconv.Itoa(g.AppID)
+	}
+	game, err = DB.GameByName(name)
+	if err == nil {
+		return game, DB.AddGamePlatform(game.ID, SteamPlatform, g.AppID)
+	}
+	if !errors.Is(err, db.ErrNotFound) {
+		return nil, err
+	}
+	game = &db.Game{
+		Name:       name,
+		Platform:   SteamPlatform,
+		PlatformID: g.AppID,
+		Platforms:  []*db.GamePlatform{{Platform: SteamPlatform, PlatformID: g.AppID}},
+	}
+	if err := DB.SaveGame(game); err != nil {
+		return nil, err
+	}
+	return game, nil
+}
@@ -0,0 +1,48 @@
+package gamesync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FederationOfFathers/dashboard/clients/steam"
+	"github.com/FederationOfFathers/dashboard/db"
+)
+
+func TestShared(t *testing.T) {
+	var checks []*db.PlatformCheck
+	for _, id := range []int{1, 2, 3, 4} {
+		checks = append(checks, &db.PlatformCheck{Member: &db.Member{ID: id}})
+	}
+	settings := map[int]map[string]db.Visibility{
+		2: {"steam": db.VisibilityFriends},
+		3: {"steam": db.VisibilityAdmins},
+		4: {"xbl": db.VisibilityAdmins, "steam": db.VisibilityGuild},
+	}
+	got := shared(checks, settings)
+	if len(got) != 2 || got[0].Member.ID != 1 || got[1].Member.ID != 4 {
+		t.Errorf("expected members 1 and 4 to be imported, got %+v", got)
+	}
+}
+
+func TestPlayedGames(t *testing.T) {
+	now := time.Unix(1760000000, 0)
+	owned := []steam.Game{
+		{AppID: 1, Name: "Played", LastPlayed: 1700000000},
+		{AppID: 2, Name: "Never played"},
+		{AppID: 3, Name: "Recent", LastPlayed: 1750000000},
+	}
+	recent := []steam.Game{
+		{AppID: 3, Name: "Recent"},
+		{AppID: 4, Name: "Recent, not owned"},
+	}
+	got := playedGames(owned, recent, now)
+	expected := map[int]int64{1: 1700000000, 3: 1750000000, 4: now.Unix()}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d games, got %+v", len(expected), got)
+	}
+	for _, g := range got {
+		if want, ok := expected[g.AppID]; !ok || g.LastPlayed != want {
+			t.Errorf("app %d: expected last played %d, got %d", g.AppID, want, g.LastPlayed)
+		}
+	}
+}
@@ -0,0 +1,418 @@
+package imagecache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Errors returned by Get, so callers can tell a bad request from an upstream failure
+var (
+	ErrNotAllowed = errors.New("image host is not allowed")
+	ErrPrivate    = errors.New("image host resolves to a private address")
+	ErrNotImage   = errors.New("upstream did not return an image")
+	ErrTooLarge   = errors.New("image is too large")
+	ErrVariant    = errors.New("unknown image size")
+)
+
+// contentTypes are the image formats the cache will store and serve
+var contentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// DefaultHosts are the upstream hosts game art and stream thumbnails come from. A leading dot allows subdomains
+var DefaultHosts = []string{
+	".s-microsoft.com",
+	".xboxlive.com",
+	".steamstatic.com",
+	".akamaihd.net",
+	".jtvnw.net",
+	".ytimg.com",
+	".ggpht.com",
+	".googleusercontent.com",
+}
+
+// PublicURL is where the cdn route can be reached from outside, such as by Discord. Link leaves URLs alone while
+// it is empty
+var PublicURL string
+
+// Link returns a URL for an image served through the cdn route, in the given size
+func Link(rawURL string, variant string) string {
+	if PublicURL == "" || rawURL == "" {
+		return rawURL
+	}
+	return strings.TrimRight(PublicURL, "/") + cdnPath(rawURL, variant)
+}
+
+// Href is Link for pages the dashboard serves itself. While PublicURL is empty the link is relative to the
+// dashboard rather than the upstream URL
+func Href(rawURL string, variant string) string {
+	if PublicURL != "" || rawURL == "" {
+		return Link(rawURL, variant)
+	}
+	return cdnPath(rawURL, variant)
+}
+
+func cdnPath(rawURL string, variant string) string {
+	q := url.Values{"url": {rawURL}}
+	if variant != "" {
+		q.Set("size", variant)
+	}
+	return "/api/v0/cdn/?" + q.Encode()
+}
+
+// Cache fetches images from allowed hosts and keeps them, and their resized variants, on disk
+type Cache struct {
+	Dir string
+	// Hosts are the allowed upstream hosts. A leading dot allows the domain and all of its subdomains
+	Hosts []string
+	// TTL is how long an image is served from disk before it is fetched again
+	TTL time.Duration
+	// Keep is how long an image nobody asks for stays on disk
+	Keep time.Duration
+	// MaxBytes is the largest upstream image accepted
+	MaxBytes int64
+	// MaxDiskBytes is the most the cache keeps on disk. The images fetched longest ago are removed to stay under it
+	MaxDiskBytes int64
+	// HTTP fetches upstream images. The client from New follows redirects only to allowed hosts, and never connects
+	// to loopback or private addresses
+	HTTP *http.Client
+
+	// locks keep two requests for the same image from fetching it twice. Keys share them by their first byte
+	locks [256]sync.Mutex
+	// used is roughly how many bytes are on disk, measured once and then added to as images are written
+	used     int64
+	measured sync.Once
+	sweeping sync.Mutex
+	// allowPrivate lets tests fetch from a local server
+	allowPrivate bool
+}
+
+// Image is a cached image on disk
+type Image struct {
+	Path        string    `json:"-"`
+	ContentType string    `json:"contentType"`
+	ETag        string    `json:"etag"`
+	Fetched     time.Time `json:"fetched"`
+}
+
+// New returns a cache in dir for the given hosts, fetching images again after a day and removing them after a week
+// or once it holds a gigabyte
+func New(dir string, hosts []string) *Cache {
+	c := &Cache{
+		Dir:          dir,
+		Hosts:        hosts,
+		TTL:          24 * time.Hour,
+		Keep:         7 * 24 * time.Hour,
+		MaxBytes:     8 << 20,
+		MaxDiskBytes: 1 << 30,
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: c.checkAddress}
+	c.HTTP = &http.Client{
+		Timeout: 15 * time.Second,
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			if !c.Allowed(req.URL) {
+				return ErrNotAllowed
+			}
+			return nil
+		},
+	}
+	return c
+}
+
+// checkAddress refuses connections to loopback, private, link local and unspecified addresses. It runs after
+// the host name is resolved, so an allowed host can't be pointed at the internal network
+func (c *Cache) checkAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ErrPrivate
+	}
+	if c.allowPrivate {
+		return nil
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return ErrPrivate
+	}
+	return nil
+}
+
+// Allowed reports whether an image URL is on an allowed host and fetched over http or https
+func (c *Cache) Allowed(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range c.Hosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed == "" {
+			continue
+		}
+		if host == strings.TrimPrefix(allowed, ".") || (strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns the cached image for rawURL in the given size, "" for the original. It is fetched when missing or
+// older than TTL. A stale copy is served when fetching it again fails
+func (c *Cache) Get(ctx context.Context, rawURL string, variant string) (*Image, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !c.Allowed(u) {
+		return nil, ErrNotAllowed
+	}
+	if _, ok := Variants[variant]; variant != "" && !ok {
+		return nil, ErrVariant
+	}
+
+	key := cacheKey(u.String(), variant)
+	lock := &c.locks[keyShard(key)]
+	lock.Lock()
+	defer lock.Unlock()
+
+	cached, _ := c.load(key)
+	if cached != nil && time.Since(cached.Fetched) < c.TTL {
+		return cached, nil
+	}
+	img, err := c.fetch(ctx, u.String(), variant, key)
+	if err != nil && cached != nil && !errors.Is(err, ErrNotImage) && !errors.Is(err, ErrNotAllowed) && !errors.Is(err, ErrPrivate) {
+		return cached, nil
+	}
+	return img, err
+}
+
+func cacheKey(rawURL, variant string) string {
+	sum := sha256.Sum256([]byte(variant + " " + rawURL))
+	return hex.EncodeToString(sum[:])
+}
+
+func keyShard(key string) byte {
+	b, _ := hex.DecodeString(key[:2])
+	return b[0]
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.Dir, key[:2], key)
+}
+
+// load reads an image's metadata from disk, or returns nil if it is not cached
+func (c *Cache) load(key string) (*Image, error) {
+	buf, err := ioutil.ReadFile(c.path(key) + ".json")
+	if err != nil {
+		return nil, err
+	}
+	var img Image
+	if err := json.Unmarshal(buf, &img); err != nil {
+		return nil, err
+	}
+	img.Path = c.path(key)
+	if _, err := os.Stat(img.Path); err != nil {
+		return nil, err
+	}
+	return &img, nil
+}
+
+// fetch downloads an image, resizes it if asked to, and writes it to disk
+func (c *Cache) fetch(ctx context.Context, rawURL, variant, key string) (*Image, error) {
+	body, contentType, err := c.download(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if variant != "" {
+		if body, contentType, err = resize(body, contentType, Variants[variant]); err != nil {
+			return nil, err
+		}
+	}
+	sum := sha256.Sum256(body)
+	img := &Image{
+		Path:        c.path(key),
+		ContentType: contentType,
+		ETag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+		Fetched:     time.Now(),
+	}
+	meta, err := json.Marshal(img)
+	if err != nil {
+		return nil, err
+	}
+	c.measured.Do(func() { c.Prune(0) })
+	if err := os.MkdirAll(filepath.Dir(img.Path), 0755); err != nil {
+		return nil, err
+	}
+	if err := writeFile(img.Path, body); err != nil {
+		return nil, err
+	}
+	if err := writeFile(img.Path+".json", meta); err != nil {
+		return nil, err
+	}
+	if used := atomic.AddInt64(&c.used, int64(len(body)+len(meta))); c.MaxDiskBytes > 0 && used > c.MaxDiskBytes && c.sweeping.TryLock() {
+		go func() {
+			defer c.sweeping.Unlock()
+			c.sweep(0)
+		}()
+	}
+	return img, nil
+}
+
+// download fetches an upstream image. It must claim to be an image, and its bytes must show one of contentTypes
+func (c *Cache) download(ctx context.Context, rawURL string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	rsp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetching %s: %s", rawURL, rsp.Status)
+	}
+	if rsp.ContentLength > c.MaxBytes {
+		return nil, "", ErrTooLarge
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(rsp.Body, c.MaxBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(body)) > c.MaxBytes {
+		return nil, "", ErrTooLarge
+	}
+	declared := strings.TrimSpace(strings.Split(rsp.Header.Get("Content-Type"), ";")[0])
+	sniffed := http.DetectContentType(body)
+	if !contentTypes[sniffed] || (declared != "" && declared != "application/octet-stream" && !strings.HasPrefix(declared, "image/")) {
+		return nil, "", ErrNotImage
+	}
+	return body, sniffed, nil
+}
+
+// writeFile replaces a file in one step, so a reader never sees it half written
+func writeFile(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Mind removes images older than Keep every hour
+func (c *Cache) Mind(logger *zap.Logger) {
+	go func() {
+		for range time.Tick(time.Hour) {
+			removed, err := c.Prune(c.Keep)
+			if err != nil {
+				logger.Error("unable to prune image cache", zap.String("dir", c.Dir), zap.Error(err))
+			} else if removed > 0 {
+				logger.Info("pruned image cache", zap.Int("removed", removed))
+			}
+		}
+	}()
+}
+
+// Prune removes cached images fetched longer than maxAge ago, unless it is 0, and then the oldest images while the
+// cache is larger than MaxDiskBytes
+func (c *Cache) Prune(maxAge time.Duration) (int, error) {
+	c.sweeping.Lock()
+	defer c.sweeping.Unlock()
+	return c.sweep(maxAge)
+}
+
+type cachedFile struct {
+	meta    string
+	fetched time.Time
+	size    int64
+}
+
+// sweep removes images fetched longer than maxAge ago, if it is set, and trims the cache to three quarters of
+// MaxDiskBytes once it is over. It also measures how much the cache holds. The caller holds sweeping
+func (c *Cache) sweep(maxAge time.Duration) (int, error) {
+	var files []*cachedFile
+	var total int64
+	var removed int
+	err := filepath.Walk(c.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".json") {
+			return err
+		}
+		image, err := os.Stat(strings.TrimSuffix(path, ".json"))
+		if maxAge > 0 && time.Since(info.ModTime()) >= maxAge {
+			os.Remove(strings.TrimSuffix(path, ".json"))
+			if err := os.Remove(path); err == nil {
+				removed++
+			}
+			return nil
+		}
+		f := &cachedFile{meta: path, fetched: info.ModTime(), size: info.Size()}
+		if err == nil {
+			f.size += image.Size()
+		}
+		files = append(files, f)
+		total += f.size
+		return nil
+	})
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	if c.MaxDiskBytes > 0 && total > c.MaxDiskBytes {
+		sort.Slice(files, func(i, j int) bool { return files[i].fetched.Before(files[j].fetched) })
+		for _, f := range files {
+			if total <= c.MaxDiskBytes/4*3 {
+				break
+			}
+			os.Remove(strings.TrimSuffix(f.meta, ".json"))
+			if err := os.Remove(f.meta); err == nil {
+				removed++
+				total -= f.size
+			}
+		}
+	}
+	atomic.StoreInt64(&c.used, total)
+	return removed, err
+}
@@ -0,0 +1,181 @@
+package imagecache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAllowed(t *testing.T) {
+	c := New("", []string{".steamstatic.com", "i.ytimg.com"})
+	for raw, expected := range map[string]bool{
+		"https://cdn.cloudflare.steamstatic.com/a.jpg": true,
+		"https://steamstatic.com/a.jpg":                true,
+		"https://i.ytimg.com/vi/x/mqdefault.jpg":       true,
+		"https://x.i.ytimg.com/a.jpg":                  false,
+		"https://evilsteamstatic.com/a.jpg":            false,
+		"ftp://i.ytimg.com/a.jpg":                      false,
+		"https://127.0.0.1/a.jpg":                      false,
+	} {
+		u, _ := url.Parse(raw)
+		if got := c.Allowed(u); got != expected {
+			t.Errorf("%s: expected %v, got %v", raw, expected, got)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 920, 430)))
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		switch r.URL.Path {
+		case "/art.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(buf.Bytes())
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><body>not an image</body></html>"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	dir, err := os.MkdirTemp("", "imagecache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	u, _ := url.Parse(server.URL)
+	c := New(dir, []string{u.Hostname()})
+	c.allowPrivate = true
+
+	img, err := c.Get(context.Background(), server.URL+"/art.png", "card")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(img.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cfg, err := png.DecodeConfig(f)
+	if err != nil || cfg.Width != 460 || cfg.Height != 215 || img.ContentType != "image/png" {
+		t.Errorf("expected a 460x215 png card, got %dx%d %s %v", cfg.Width, cfg.Height, img.ContentType, err)
+	}
+
+	again, err := c.Get(context.Background(), server.URL+"/art.png", "card")
+	if err != nil || again.ETag != img.ETag || atomic.LoadInt32(&fetches) != 1 {
+		t.Errorf("expected the card to come from disk, got %v after %d fetches", err, fetches)
+	}
+
+	if _, err := c.Get(context.Background(), server.URL+"/page.html", ""); !errors.Is(err, ErrNotImage) {
+		t.Errorf("expected a page to be refused, got %v", err)
+	}
+	if _, err := c.Get(context.Background(), server.URL+"/art.png", "poster"); !errors.Is(err, ErrVariant) {
+		t.Errorf("expected an unknown size to be refused, got %v", err)
+	}
+	if _, err := c.Get(context.Background(), "https://example.com/art.png", ""); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("expected an unlisted host to be refused, got %v", err)
+	}
+}
+
+func TestUpstreamChecks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	}))
+	defer server.Close()
+	dir, err := os.MkdirTemp("", "imagecache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	u, _ := url.Parse(server.URL)
+
+	c := New(dir, []string{u.Hostname()})
+	if _, err := c.Get(context.Background(), server.URL+"/art.png", ""); !errors.Is(err, ErrPrivate) {
+		t.Errorf("expected a loopback address to be refused, got %v", err)
+	}
+	c.allowPrivate = true
+	if _, err := c.Get(context.Background(), server.URL+"/art.png", ""); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("expected a redirect to an unlisted host to be refused, got %v", err)
+	}
+}
+
+func TestPruneToSize(t *testing.T) {
+	dir, err := os.MkdirTemp("", "imagecache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := New(dir, nil)
+	c.MaxDiskBytes = 4000
+	for i, age := range []time.Duration{3 * time.Hour, 2 * time.Hour, time.Hour} {
+		key := cacheKey(fmt.Sprintf("https://example.com/%d.png", i), "")
+		os.MkdirAll(filepath.Dir(c.path(key)), 0755)
+		os.WriteFile(c.path(key), make([]byte, 1900), 0644)
+		os.WriteFile(c.path(key)+".json", []byte("{}"), 0644)
+		then := time.Now().Add(-age)
+		os.Chtimes(c.path(key)+".json", then, then)
+	}
+	removed, err := c.Prune(0)
+	if err != nil || removed != 2 {
+		t.Errorf("expected the two oldest images to go, got %d removed %v", removed, err)
+	}
+	if _, err := c.load(cacheKey("https://example.com/2.png", "")); err != nil {
+		t.Errorf("expected the newest image to be kept, got %v", err)
+	}
+}
+
+func TestResizeTooLarge(t *testing.T) {
+	// a gif header claiming to be 60000x60000
+	header := []byte{'G', 'I', 'F', '8', '9', 'a', 0x60, 0xea, 0x60, 0xea, 0, 0, 0}
+	if _, _, err := resize(header, "image/gif", Variants["thumbnail"]); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected a huge image to be refused before decoding, got %v", err)
+	}
+}
+
+func TestFit(t *testing.T) {
+	for _, test := range [][6]int{
+		{920, 430, 460, 215, 460, 215},
+		{100, 50, 460, 215, 100, 50},
+		{1000, 1000, 160, 160, 160, 160},
+		{2000, 10, 160, 160, 160, 1},
+	} {
+		w, h := fit(test[0], test[1], test[2], test[3])
+		if w != test[4] || h != test[5] {
+			t.Errorf("%v: got %dx%d", test, w, h)
+		}
+	}
+}
+
+func TestHref(t *testing.T) {
+	defer func(u string) { PublicURL = u }(PublicURL)
+	raw := "https://i.ytimg.com/a.jpg?x=1&y=2"
+
+	PublicURL = ""
+	if got, want := Href(raw, "thumb"), "/api/v0/cdn/?size=thumb&url=https%3A%2F%2Fi.ytimg.com%2Fa.jpg%3Fx%3D1%26y%3D2"; got != want {
+		t.Errorf("relative link %q, want %q", got, want)
+	}
+	if got := Link(raw, ""); got != raw {
+		t.Errorf("Link without a public URL gave %q", got)
+	}
+
+	PublicURL = "https://dash.example/"
+	if got, want := Href(raw, ""), "https://dash.example/api/v0/cdn/?url=https%3A%2F%2Fi.ytimg.com%2Fa.jpg%3Fx%3D1%26y%3D2"; got != want {
+		t.Errorf("public link %q, want %q", got, want)
+	}
+}
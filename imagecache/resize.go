@@ -0,0 +1,130 @@
+package imagecache
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// Variant is a size images may be served in. Images are scaled down to fit inside Width by Height, keeping
+// their shape, and never scaled up
+type Variant struct {
+	Width  int
+	Height int
+}
+
+// MaxPixels is the most pixels an image may have to be resized. Larger images are refused before being decoded
+const MaxPixels = 4096 * 4096
+
+// Variants are the sizes images may be asked for by name
+var Variants = map[string]Variant{
+	"thumbnail": {Width: 160, Height: 160},
+	"card":      {Width: 460, Height: 215},
+}
+
+// resize scales an image to fit a variant. PNGs stay PNGs to keep transparency, and GIFs lose their animation.
+// Formats the standard library cannot decode, such as WebP, are served at their original size
+func resize(body []byte, contentType string, v Variant) ([]byte, string, error) {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return body, contentType, nil
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(body))
+	if err != nil {
+		return nil, "", ErrNotImage
+	}
+	if cfg.Width < 1 || cfg.Height < 1 || cfg.Width > MaxPixels/cfg.Height {
+		return nil, "", ErrTooLarge
+	}
+	var src image.Image
+	switch contentType {
+	case "image/jpeg":
+		src, err = jpeg.Decode(bytes.NewReader(body))
+	case "image/png":
+		src, err = png.Decode(bytes.NewReader(body))
+	case "image/gif":
+		src, err = gif.Decode(bytes.NewReader(body))
+	}
+	if err != nil {
+		return nil, "", ErrNotImage
+	}
+	w, h := fit(src.Bounds().Dx(), src.Bounds().Dy(), v.Width, v.Height)
+	dst := src
+	if w != src.Bounds().Dx() || h != src.Bounds().Dy() {
+		dst = scale(src, w, h)
+	}
+	var buf bytes.Buffer
+	if contentType == "image/png" {
+		err = png.Encode(&buf, dst)
+	} else {
+		contentType = "image/jpeg"
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+	}
+	return buf.Bytes(), contentType, err
+}
+
+// fit returns the largest size no bigger than the original which fits inside maxW by maxH with the same shape
+func fit(w, h, maxW, maxH int) (int, int) {
+	if w <= maxW && h <= maxH {
+		return w, h
+	}
+	if w*maxH > h*maxW {
+		return maxW, max1(h * maxW / w)
+	}
+	return max1(w * maxH / h), maxH
+}
+
+func max1(i int) int {
+	if i < 1 {
+		return 1
+	}
+	return i
+}
+
+// scale shrinks an image to w by h, averaging the source pixels that fall in each destination pixel. Source rows
+// are converted one at a time, so only the destination is allocated in full
+func scale(src image.Image, w, h int) *image.RGBA {
+	b := src.Bounds()
+	row := image.NewRGBA(image.Rect(0, 0, b.Dx(), 1))
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	sums := make([][4]int, w)
+	for y := 0; y < h; y++ {
+		y0, y1 := y*b.Dy()/h, max1((y+1)*b.Dy()/h)
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := range sums {
+			sums[x] = [4]int{}
+		}
+		for sy := y0; sy < y1; sy++ {
+			draw.Draw(row, row.Bounds(), src, image.Pt(b.Min.X, b.Min.Y+sy), draw.Src)
+			for x := 0; x < w; x++ {
+				x0, x1 := x*b.Dx()/w, (x+1)*b.Dx()/w
+				if x1 <= x0 {
+					x1 = x0 + 1
+				}
+				for sx := x0; sx < x1; sx++ {
+					for i := 0; i < 4; i++ {
+						sums[x][i] += int(row.Pix[sx*4+i])
+					}
+				}
+			}
+		}
+		for x := 0; x < w; x++ {
+			x0, x1 := x*b.Dx()/w, (x+1)*b.Dx()/w
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			n := (y1 - y0) * (x1 - x0)
+			off := y*dst.Stride + x*4
+			for i := 0; i < 4; i++ {
+				dst.Pix[off+i] = uint8(sums[x][i] / n)
+			}
+		}
+	}
+	return dst
+}
@@ -3,22 +3,31 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"io/ioutil"
 
+	"github.com/FederationOfFathers/dashboard/activity"
 	"github.com/FederationOfFathers/dashboard/api"
 	"github.com/FederationOfFathers/dashboard/bot"
 	"github.com/FederationOfFathers/dashboard/bridge"
+	"github.com/FederationOfFathers/dashboard/clients/steam"
 	"github.com/FederationOfFathers/dashboard/config"
 	"github.com/FederationOfFathers/dashboard/db"
 	"github.com/FederationOfFathers/dashboard/environment"
 	"github.com/FederationOfFathers/dashboard/events"
+	"github.com/FederationOfFathers/dashboard/gamesync"
+	"github.com/FederationOfFathers/dashboard/imagecache"
 	"github.com/FederationOfFathers/dashboard/messaging"
 	"github.com/FederationOfFathers/dashboard/metrics"
+	"github.com/FederationOfFathers/dashboard/milestones"
+	"github.com/FederationOfFathers/dashboard/roster"
 	"github.com/FederationOfFathers/dashboard/store"
 	"github.com/FederationOfFathers/dashboard/streams"
+	"github.com/FederationOfFathers/dashboard/trends"
 	"github.com/apokalyptik/cfg"
 	"github.com/bearcherian/rollzap"
 	"github.com/honeycombio/beeline-go"
@@ -41,6 +50,11 @@ var DB *db.DB
 var mysqlURI string
 var streamChannel = "-fof-dashboard"
 var mindStreams bool
+var cdnDir = "cdn-cache"
+var cdnHosts = strings.Join(imagecache.DefaultHosts, ",")
+var cdnMaxMB = 1024
+var steamAPIKey string
+var steamBaseURL = steam.DefaultBaseURL
 var honeycombToken string
 var honeycombDataset string = "unknown"
 
@@ -73,6 +87,10 @@ func init() {
 	db.Logger = logger.Named("db")
 	bridge.Logger = logger.Named("bridge")
 	messaging.Logger = logger.Named("messaging")
+	activity.Logger = logger.Named("activity")
+	milestones.Logger = logger.Named("milestones")
+	gamesync.Logger = logger.Named("gamesync")
+	trends.Logger = logger.Named("trends")
 
 	scfg := cfg.New("cfg-slack")
 	scfg.BoolVar(&mindStreams, "mindStreams", mindStreams, "should we mind streaming?")
@@ -86,6 +104,16 @@ func init() {
 	acfg.StringVar(&api.ListenOn, "listen", api.ListenOn, "API bind address (env: API_LISTEN)")
 	acfg.StringVar(&api.AuthSecret, "secret", api.AuthSecret, "Authentication secret for use in generating login tokens")
 	acfg.StringVar(&api.JWTSecret, "hmac", api.JWTSecret, "Authentication secret used for JWT tokens")
+	acfg.IntVar(&api.RateLimitRead.Requests, "readLimit", api.RateLimitRead.Requests, "requests allowed per client per readWindow for read only routes (0 disables)")
+	acfg.DurationVar(&api.RateLimitRead.Window, "readWindow", api.RateLimitRead.Window, "rate limit window for read only routes")
+	acfg.IntVar(&api.RateLimitWrite.Requests, "writeLimit", api.RateLimitWrite.Requests, "requests allowed per client per writeWindow for routes which change data (0 disables)")
+	acfg.DurationVar(&api.RateLimitWrite.Window, "writeWindow", api.RateLimitWrite.Window, "rate limit window for routes which change data")
+	acfg.IntVar(&api.RateLimitAnnounce.Requests, "announceLimit", api.RateLimitAnnounce.Requests, "requests allowed per client per announceWindow for routes which post to Discord (0 disables)")
+	acfg.DurationVar(&api.RateLimitAnnounce.Window, "announceWindow", api.RateLimitAnnounce.Window, "rate limit window for routes which post to Discord")
+	acfg.StringVar(&cdnDir, "cdnDir", cdnDir, "directory the image cache keeps game art and thumbnails in (empty disables /api/v0/cdn)")
+	acfg.StringVar(&cdnHosts, "cdnHosts", cdnHosts, "comma separated hosts the image cache may fetch from. A leading dot allows subdomains")
+	acfg.IntVar(&cdnMaxMB, "cdnMaxMB", cdnMaxMB, "most megabytes the image cache keeps on disk before removing the images fetched longest ago")
+	acfg.StringVar(&imagecache.PublicURL, "publicURL", imagecache.PublicURL, "public base URL of the API, used to send images in Discord messages through the image cache")
 
 	ecfg := cfg.New("cfg-events")
 	ecfg.StringVar(&events.SaveFile, "savefile", events.SaveFile, "path to the file in which events should be persisted")
@@ -100,9 +128,24 @@ func init() {
 	tcfg.StringVar(&twitchClientID, "clientID", "", "Twitch Client ID")
 	tcfg.StringVar(&twitchClientSecret, "clientSecret", "", "Twitch Client Secret")
 
+	stcfg := cfg.New("cfg-steam")
+	stcfg.StringVar(&steamAPIKey, "apiKey", "", "Steam Web API key. Steam libraries are not imported without one")
+	stcfg.StringVar(&steamBaseURL, "baseURL", steamBaseURL, "Steam Web API base URL")
+	stcfg.DurationVar(&gamesync.Interval, "interval", gamesync.Interval, "how often each member's Steam library is imported")
+
 	ytcfg := cfg.New("cfg-youtube")
 	ytcfg.StringVar(&youtubeAPIKey, "apiKey", "", "YouTube API Key")
 
+	mcfg := cfg.New("cfg-milestones")
+	mcfg.IntVar(&milestones.Hour, "hour", milestones.Hour, "hour of the day after which milestones are announced")
+	for _, kind := range milestones.Kinds {
+		mcfg.StringVar(milestones.Templates[kind], kind, *milestones.Templates[kind], "announcement template for the "+kind+" milestone")
+	}
+
+	trcfg := cfg.New("cfg-trends")
+	trcfg.IntVar(&trends.Hour, "digestHour", trends.Hour, "hour on Monday, in UTC, after which the weekly games digest is posted")
+	trcfg.IntVar(&trends.Games, "digestGames", trends.Games, "how many games each section of the weekly digest lists")
+
 	hcfg := cfg.New("cfg-honeycomb")
 	hcfg.StringVar(&honeycombToken, "token", honeycombToken, "Token for Honeycomb project reporting")
 	hcfg.StringVar(&honeycombDataset, "dataset", honeycombDataset, "Dataset for Honeycomb project reporting")
@@ -134,6 +177,42 @@ func main() {
 	api.DB = DB
 	bot.DB = DB
 	events.DB = DB
+	messaging.DB = DB
+	activity.DB = DB
+	activity.Mind()
+	milestones.DB = DB
+	gamesync.DB = DB
+	trends.DB = DB
+	trends.Mind()
+
+	if cdnDir != "" {
+		api.ImageCache = imagecache.New(cdnDir, strings.Split(cdnHosts, ","))
+		api.ImageCache.MaxDiskBytes = int64(cdnMaxMB) << 20
+		api.ImageCache.Mind(logger.Named("imagecache"))
+	}
+
+	if steamAPIKey != "" {
+		gamesync.Steam = steam.New(steamAPIKey)
+		gamesync.Steam.BaseURL = steamBaseURL
+		gamesync.Mind()
+	}
+
+	// friends used to be kept by slack id
+	moved, dropped, err := roster.Migrate(func(slackID string) (int, error) {
+		member, err := DB.MemberBySlackID(slackID)
+		if errors.Is(err, db.ErrNotFound) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		return member.ID, nil
+	})
+	if err != nil {
+		logger.Error("unable to migrate friends", zap.Error(err))
+	} else if moved+dropped > 0 {
+		logger.Info("migrated friends to member ids", zap.Int("moved", moved), zap.Int("dropped", dropped))
+	}
 
 	bridge.DiscordCoreDataUpdated = bot.DiscordCoreDataUpdated
 	bridge.OldEventToolLink = events.OldEventToolLink
@@ -195,6 +274,14 @@ func startDiscord(discordCfg bot.DiscordCfg, yt *youtube.Service) (*bot.DiscordA
 	discordApi.MindGuild()
 
 	messaging.AddMsgAPI(discordApi)
+	if discordCfg.MilestoneChannelId != "" {
+		milestones.Mind()
+	}
+	trends.Digest = discordCfg.DigestChannelId != ""
+	discordApi.MindLFG()
+	if discordCfg.GameRoles.Enabled {
+		discordApi.MindGameRoles()
+	}
 
 	return discordApi, nil
 }
@@ -1,15 +1,19 @@
 package messaging
 
 import (
+	"fmt"
 	"reflect"
 
 	"go.uber.org/zap"
 
 	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/FederationOfFathers/dashboard/metrics"
+	"github.com/FederationOfFathers/dashboard/roster"
 )
 
 var msgApis []MsgAPI
 var Logger *zap.Logger
+var DB *db.DB
 
 type StreamMessage struct {
 	Platform         string
@@ -26,14 +30,25 @@ type StreamMessage struct {
 
 // Add Messaging APIs that will be used to send messages
 func AddMsgAPI(msgApi MsgAPI) {
-	Logger.Info("Adding new message API", zap.String("type", reflect.TypeOf(msgApi).String()))
+	Logger.Info("Adding new message API", zap.String("type", apiName(msgApi)))
 	msgApis = append(msgApis, msgApi)
 }
 
+// apiName is the label used for a messaging API in metrics
+func apiName(msgApi MsgAPI) string {
+	return reflect.TypeOf(msgApi).String()
+}
+
 type MsgAPI interface {
 	PostStreamMessage(sm StreamMessage) error
 	PostNewEventMessage(e *db.Event) error
 	PostJoinEventMessage(e *db.Event, member string) error
+	PostAuditMessage(a *db.AuditLog, actor string, target string) error
+	PostDirectMessage(member *db.Member, message string) error
+	PostMilestoneMessage(message string) error
+	PostDigestMessage(trends *db.GameTrends) error
+	PostLFGMessage(l *db.LFG) error
+	PostLFGFilledMessage(l *db.LFG) error
 	//PostMessageToChannel(channel string, message string)
 }
 
@@ -44,25 +59,125 @@ func SendTwitchStreamMessage(sm StreamMessage) {
 func SendNewEventMessage(e *db.Event) {
 	for _, msgApi := range msgApis {
 		err := msgApi.PostNewEventMessage(e)
+		metrics.MessageSent(apiName(msgApi), "new_event", err)
 		if err != nil {
 			Logger.Error("unable to send event notice", zap.Any("event", e), zap.Error(err))
 		}
 	}
+	for _, em := range e.Members {
+		if em.Type != db.EventMemberTypeHost {
+			continue
+		}
+		host, err := DB.MemberByID(em.MemberID)
+		if err != nil {
+			Logger.Error("unable to find event host", zap.Int("member", em.MemberID), zap.Error(err))
+			return
+		}
+		NotifyFriends(host, "notifyFriendEvents", fmt.Sprintf("🌟 %s has created an event: %s", host.Name, e.Title))
+		return
+	}
 }
 
 func SendJoinEventMessage(e *db.Event, member *db.Member) {
 	for _, msgApi := range msgApis {
 		err := msgApi.PostJoinEventMessage(e, member.Name)
+		metrics.MessageSent(apiName(msgApi), "join_event", err)
 		if err != nil {
 			Logger.Error("unable to send event join message", zap.Any("event", e), zap.String("member", member.Discord), zap.Error(err))
 		}
 	}
+	NotifyFriends(member, "notifyFriendEvents", fmt.Sprintf("🔹 %s has joined an event: %s", member.Name, e.Title))
+}
+
+// SendMilestoneMessage announces a member milestone through every API. It returns the last error any API gave
+func SendMilestoneMessage(message string) error {
+	var rval error
+	for _, msgApi := range msgApis {
+		err := msgApi.PostMilestoneMessage(message)
+		metrics.MessageSent(apiName(msgApi), "milestone", err)
+		if err != nil {
+			Logger.Error("unable to send milestone message", zap.String("message", message), zap.Error(err))
+			rval = err
+		}
+	}
+	return rval
+}
+
+// SendDigestMessage posts the weekly summary of what the community played through every API. It returns the last
+// error any API gave
+func SendDigestMessage(trends *db.GameTrends) error {
+	var rval error
+	for _, msgApi := range msgApis {
+		err := msgApi.PostDigestMessage(trends)
+		metrics.MessageSent(apiName(msgApi), "digest", err)
+		if err != nil {
+			Logger.Error("unable to send weekly digest", zap.Time("week", trends.Week), zap.Error(err))
+			rval = err
+		}
+	}
+	return rval
+}
+
+// SendLFGMessage posts a looking for group entry, or updates it after members joined or left
+func SendLFGMessage(l *db.LFG) {
+	for _, msgApi := range msgApis {
+		err := msgApi.PostLFGMessage(l)
+		metrics.MessageSent(apiName(msgApi), "lfg", err)
+		if err != nil {
+			Logger.Error("unable to send lfg message", zap.Int("lfg", l.ID), zap.Error(err))
+		}
+	}
+}
+
+// SendLFGFilledMessage pings the members of a group which just filled
+func SendLFGFilledMessage(l *db.LFG) {
+	for _, msgApi := range msgApis {
+		err := msgApi.PostLFGFilledMessage(l)
+		metrics.MessageSent(apiName(msgApi), "lfg_filled", err)
+		if err != nil {
+			Logger.Error("unable to send lfg filled message", zap.Int("lfg", l.ID), zap.Error(err))
+		}
+	}
+}
+
+// NotifyFriends sends a DM to everyone with member on their friends list who has turned on the boolean meta key optIn
+func NotifyFriends(member *db.Member, optIn string, message string) {
+	followers := roster.Followers(member.ID)
+	if len(followers) == 0 {
+		return
+	}
+	recipients, err := DB.MembersWithMetaTrue(optIn, followers)
+	if err != nil {
+		Logger.Error("unable to find friends to notify", zap.Int("member", member.ID), zap.String("optIn", optIn), zap.Error(err))
+		return
+	}
+	for _, recipient := range recipients {
+		for _, msgApi := range msgApis {
+			err := msgApi.PostDirectMessage(recipient, message)
+			metrics.MessageSent(apiName(msgApi), "friend_notice", err)
+			if err != nil {
+				Logger.Error("unable to notify friend", zap.Int("member", member.ID), zap.Int("recipient", recipient.ID), zap.Error(err))
+			}
+		}
+	}
+}
+
+// SendAuditMessage mirrors an audit log entry to the mod log of every API
+func SendAuditMessage(a *db.AuditLog, actor *db.Member, target *db.Member) {
+	for _, msgApi := range msgApis {
+		err := msgApi.PostAuditMessage(a, actor.Name, target.Name)
+		metrics.MessageSent(apiName(msgApi), "audit", err)
+		if err != nil {
+			Logger.Error("unable to send audit message", zap.Uint("audit", a.ID), zap.String("action", a.Action), zap.Error(err))
+		}
+	}
 }
 
 func postStreamMessageToAllApis(sm StreamMessage) {
 	Logger.Info("sending stream message", zap.String("username", sm.Username), zap.String("platform", sm.Platform))
 	for _, msgApi := range msgApis {
 		err := msgApi.PostStreamMessage(sm)
+		metrics.MessageSent(apiName(msgApi), "stream", err)
 		if err != nil {
 			Logger.With(zap.Any("message", sm)).Error("unable to send stream update", zap.Error(err))
 		}
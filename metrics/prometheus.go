@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "dashboard"
+
+// HTTPRequests counts API requests by route template, method and response status
+var HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "http_requests_total",
+	Help:      "API requests by route template, method and response status",
+}, []string{"route", "method", "status"})
+
+// HTTPDuration observes API response times by route template and method
+var HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "http_request_duration_seconds",
+	Help:      "API response times by route template and method",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"route", "method"})
+
+// DBErrors counts failed database operations by kind (create, update, delete or query)
+var DBErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "db_errors_total",
+	Help:      "Failed database operations by kind",
+}, []string{"operation"})
+
+// StreamPollDuration observes how long each poll of a streaming service takes
+var StreamPollDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "stream_poll_duration_seconds",
+	Help:      "How long each poll of a streaming service takes",
+	Buckets:   []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+}, []string{"service"})
+
+// StreamPollFailures counts polls of a streaming service which failed
+var StreamPollFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "stream_poll_failures_total",
+	Help:      "Polls of a streaming service which failed",
+}, []string{"service"})
+
+// MessagesSent counts messages sent by each messaging API by kind of message
+var MessagesSent = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "messages_sent_total",
+	Help:      "Messages sent by each messaging API by kind of message",
+}, []string{"api", "kind"})
+
+// MessagesFailed counts messages each messaging API failed to send by kind of message
+var MessagesFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "messages_failed_total",
+	Help:      "Messages each messaging API failed to send by kind of message",
+}, []string{"api", "kind"})
+
+// DiscordEvents counts events received from the Discord gateway by event type
+var DiscordEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "discord_events_total",
+	Help:      "Events received from the Discord gateway by type",
+}, []string{"type"})
+
+// CacheRefreshDuration observes how long refreshing the cached Discord data takes
+var CacheRefreshDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "cache_refresh_duration_seconds",
+	Help:      "How long refreshing the cached Discord members, roles and channels takes",
+	Buckets:   []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+})
+
+// StreamPolled records a poll of a streaming service which started at start, and whether it failed
+func StreamPolled(service string, start time.Time, err error) {
+	StreamPollDuration.WithLabelValues(service).Observe(time.Since(start).Seconds())
+	if err != nil {
+		StreamPollFailures.WithLabelValues(service).Inc()
+	}
+}
+
+// MessageSent records the outcome of sending a message through a messaging API
+func MessageSent(api string, kind string, err error) {
+	if err != nil {
+		MessagesFailed.WithLabelValues(api, kind).Inc()
+	} else {
+		MessagesSent.WithLabelValues(api, kind).Inc()
+	}
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,264 @@
+package milestones
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/FederationOfFathers/dashboard/bot"
+	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/FederationOfFathers/dashboard/messaging"
+	"github.com/FederationOfFathers/dashboard/store"
+	"go.uber.org/zap"
+)
+
+// Kinds of milestone
+const (
+	KindAnniversary    = "anniversary"
+	KindFirstHosted    = "firstHosted"
+	KindEventsAttended = "eventsAttended"
+	KindFirstStream    = "firstStream"
+)
+
+// EventsAttended is how many events a member must have attended for the eventsAttended milestone
+const EventsAttended = 10
+
+// OptOutKey is the boolean meta key members set to be left out of announcements
+const OptOutKey = "hideMilestones"
+
+var DB *db.DB
+var Logger *zap.Logger
+
+// Hour is the hour of the day, in server time, after which the day's announcements are made
+var Hour = 12
+
+// Kinds lists every kind of milestone
+var Kinds = []string{KindAnniversary, KindFirstHosted, KindEventsAttended, KindFirstStream}
+
+// Templates are the text/template announcements for each kind of milestone. They are given a Data
+var Templates = map[string]*string{
+	KindAnniversary:    text("🎂 Happy {{.Years}} year anniversary, {{.Mention}}! Thanks for being part of FoF"),
+	KindFirstHosted:    text("🌟 {{.Mention}} hosted their first event. Thanks for getting everyone together!"),
+	KindEventsAttended: text("🎮 {{.Mention}} has made it to {{.Count}} events!"),
+	KindFirstStream:    text("📺 {{.Mention}} went live for the first time. Go say hi!"),
+}
+
+func text(s string) *string {
+	return &s
+}
+
+// Data is what announcement templates are executed with
+type Data struct {
+	Name    string
+	Mention string
+	Years   int
+	Count   int
+}
+
+type announcement struct {
+	key      string
+	kind     string
+	memberID int
+	data     Data
+}
+
+const lastRunKey = "lastRun"
+
+// Mind checks every few minutes whether today's announcements are due, and makes them once a day
+func Mind() {
+	go func() {
+		check()
+		for range time.Tick(10 * time.Minute) {
+			check()
+		}
+	}()
+}
+
+func check() {
+	now := time.Now()
+	// anniversaries come from the guild roster, so wait until it has been loaded
+	if now.Hour() < Hour || len(bot.GetMembers()) == 0 {
+		return
+	}
+	var lastRun string
+	store.DB.Milestones().Get(lastRunKey, &lastRun)
+	today := now.Format("2006-01-02")
+	if lastRun == today {
+		return
+	}
+	if err := run(now, lastRun == ""); err != nil {
+		Logger.Error("unable to announce milestones", zap.Error(err))
+		return
+	}
+	if err := store.DB.Milestones().Put(lastRunKey, today); err != nil {
+		Logger.Error("unable to record milestone run", zap.Error(err))
+	}
+}
+
+// run announces the milestones reached as of now which have not been announced before. On the first run
+// ever the one off milestones members have already reached are recorded without being announced. When an
+// announcement fails to send an error is returned so the day is not marked done and the next check retries it
+func run(now time.Time, firstRun bool) error {
+	pending, err := collect(now)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for _, a := range pending {
+		ids = append(ids, a.memberID)
+	}
+	optedOut, err := DB.MembersWithMetaTrue(OptOutKey, ids)
+	if err != nil {
+		return err
+	}
+	var hidden = map[int]bool{}
+	for _, m := range optedOut {
+		hidden[m.ID] = true
+	}
+
+	var announced, failed int
+	for _, a := range pending {
+		var done string
+		if store.DB.Milestones().Get(a.key, &done) == nil {
+			continue
+		}
+		if !hidden[a.memberID] && !(firstRun && a.kind != KindAnniversary) {
+			message, err := render(a.kind, a.data)
+			if err != nil {
+				Logger.Error("unable to render milestone", zap.String("kind", a.kind), zap.Error(err))
+				continue
+			}
+			if err := messaging.SendMilestoneMessage(message); err != nil {
+				Logger.Error("unable to send milestone", zap.String("key", a.key), zap.Error(err))
+				failed++
+				continue
+			}
+			announced++
+		}
+		if err := store.DB.Milestones().Put(a.key, now.Format("2006-01-02")); err != nil {
+			Logger.Error("unable to record milestone", zap.String("key", a.key), zap.Error(err))
+		}
+	}
+	Logger.Info("announced milestones", zap.Int("reached", len(pending)), zap.Int("announced", announced), zap.Bool("firstRun", firstRun))
+	if failed > 0 {
+		return fmt.Errorf("%d of %d milestone announcements failed to send", failed, len(pending))
+	}
+	return nil
+}
+
+// collect finds every milestone current members have reached. Anniversaries are only reached on the day
+func collect(now time.Time) ([]announcement, error) {
+	var rval []announcement
+
+	guild := bot.GetMembers()
+	var discordIDs []string
+	for _, gm := range guild {
+		discordIDs = append(discordIDs, gm.User.ID)
+	}
+	memberIDs, err := DB.MemberIDsByDiscordIDs(discordIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, gm := range guild {
+		memberID, ok := memberIDs[gm.User.ID]
+		if !ok || gm.User.Bot {
+			continue
+		}
+		years, ok := anniversary(gm.JoinedAt, now)
+		if !ok {
+			continue
+		}
+		name := gm.Nick
+		if name == "" {
+			name = gm.User.Username
+		}
+		rval = append(rval, announcement{
+			key:      fmt.Sprintf("%d/%s/%d", memberID, KindAnniversary, years),
+			kind:     KindAnniversary,
+			memberID: memberID,
+			data:     Data{Name: name, Mention: "<@" + gm.User.ID + ">", Years: years},
+		})
+	}
+
+	tallies, err := DB.MemberEventTallies()
+	if err != nil {
+		return nil, err
+	}
+	streamed, err := DB.MemberIDsWhoStreamed()
+	if err != nil {
+		return nil, err
+	}
+	var reached = map[int][]string{}
+	for memberID, tally := range tallies {
+		if tally.Hosted > 0 {
+			reached[memberID] = append(reached[memberID], KindFirstHosted)
+		}
+		if tally.Attended >= EventsAttended {
+			reached[memberID] = append(reached[memberID], KindEventsAttended)
+		}
+	}
+	for _, memberID := range streamed {
+		reached[memberID] = append(reached[memberID], KindFirstStream)
+	}
+	var ids []int
+	for memberID := range reached {
+		ids = append(ids, memberID)
+	}
+	members, err := DB.MembersByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		if m.DepartedAt != nil {
+			continue
+		}
+		data := Data{Name: m.Name, Mention: m.Name, Count: EventsAttended}
+		if m.Discord != "" {
+			data.Mention = "<@" + m.Discord + ">"
+		}
+		for _, kind := range reached[m.ID] {
+			rval = append(rval, announcement{
+				key:      fmt.Sprintf("%d/%s", m.ID, kind),
+				kind:     kind,
+				memberID: m.ID,
+				data:     data,
+			})
+		}
+	}
+	return rval, nil
+}
+
+// anniversary returns how many years ago joined was if now is its anniversary. Members who joined
+// on the 29th of February celebrate on the 28th in other years
+func anniversary(joined time.Time, now time.Time) (int, bool) {
+	if joined.IsZero() {
+		return 0, false
+	}
+	joined = joined.In(now.Location())
+	month, day := joined.Month(), joined.Day()
+	if month == time.February && day == 29 && !isLeap(now.Year()) {
+		day = 28
+	}
+	if now.Month() != month || now.Day() != day {
+		return 0, false
+	}
+	years := now.Year() - joined.Year()
+	return years, years > 0
+}
+
+func isLeap(year int) bool {
+	return year%4 == 0 && (year%100 != 0 || year%400 == 0)
+}
+
+func render(kind string, data Data) (string, error) {
+	t, err := template.New(kind).Parse(*Templates[kind])
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
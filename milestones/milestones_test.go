@@ -0,0 +1,43 @@
+package milestones
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnniversary(t *testing.T) {
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 15, 0, 0, 0, time.UTC)
+	}
+	var tests = []struct {
+		name   string
+		joined time.Time
+		now    time.Time
+		years  int
+		ok     bool
+	}{
+		{"anniversary", day(2020, time.October, 19), day(2026, time.October, 19), 6, true},
+		{"other day", day(2020, time.October, 18), day(2026, time.October, 19), 0, false},
+		{"join day", day(2026, time.October, 19), day(2026, time.October, 19), 0, false},
+		{"leap day in leap year", day(2020, time.February, 29), day(2024, time.February, 29), 4, true},
+		{"leap day in other year", day(2020, time.February, 29), day(2025, time.February, 28), 5, true},
+		{"feb 28 in leap year", day(2020, time.February, 29), day(2024, time.February, 28), 0, false},
+		{"never joined", time.Time{}, day(2026, time.October, 19), 0, false},
+	}
+	for _, test := range tests {
+		years, ok := anniversary(test.joined, test.now)
+		if years != test.years || ok != test.ok {
+			t.Errorf("%s: got %d %v, want %d %v", test.name, years, ok, test.years, test.ok)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	got, err := render(KindAnniversary, Data{Mention: "<@1>", Years: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "🎂 Happy 3 year anniversary, <@1>! Thanks for being part of FoF"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
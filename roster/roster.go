@@ -1,24 +1,254 @@
 package roster
 
 import (
+	"encoding/json"
+	"sort"
+	"strconv"
+
 	"github.com/FederationOfFathers/dashboard/store"
+	"github.com/boltdb/bolt"
 	stow "gopkg.in/djherbis/stow.v2"
 )
 
-func member(userID string) *stow.Store {
-	return store.DB.Friends().NewNestedStore([]byte(userID))
+var rosterBucket = []byte("roster")
+
+// legacyBucket is the roster kept before friends were keyed by member id, keyed by slack user id
+var legacyBucket = []byte("friends")
+
+func member(memberID int) *stow.Store {
+	return store.DB.Roster().NewNestedStore(key(memberID))
+}
+
+func key(memberID int) []byte {
+	return []byte(strconv.Itoa(memberID))
 }
 
-func Get(userID string) []string {
-	var friends = []string{}
-	member(userID).ForEach(func(userID string, status bool) {
-		if status {
-			friends = append(friends, userID)
+// Get returns the member ids on memberID's roster
+func Get(memberID int) []int {
+	var friends = []int{}
+	member(memberID).ForEach(func(friendID string, status bool) {
+		if id, err := strconv.Atoi(friendID); err == nil && status {
+			friends = append(friends, id)
 		}
 	})
+	sort.Ints(friends)
+	return friends
+}
+
+// Set adds friendID to memberID's roster, or removes them from it
+func Set(memberID, friendID int, friends bool) error {
+	if !friends {
+		return member(memberID).Delete(key(friendID))
+	}
+	return member(memberID).Put(key(friendID), true)
+}
+
+// IsFriend reports whether friendID is on memberID's roster
+func IsFriend(memberID, friendID int) bool {
+	if memberID == 0 || friendID == 0 {
+		return false
+	}
+	var friends bool
+	if err := member(memberID).Get(key(friendID), &friends); err != nil {
+		return false
+	}
 	return friends
 }
 
-func Set(userID, friendID string, friends bool) error {
-	return member(userID).Put(friendID, friends)
+// Mutual returns the member ids on both a's and b's rosters
+func Mutual(a, b int) []int {
+	var mutual = []int{}
+	for _, id := range Get(a) {
+		if IsFriend(b, id) {
+			mutual = append(mutual, id)
+		}
+	}
+	return mutual
+}
+
+// Followers returns the member ids who have memberID on their roster
+func Followers(memberID int) []int {
+	var followers = []int{}
+	friendKey := key(memberID)
+	store.DB.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(rosterBucket)
+		if root == nil {
+			return nil
+		}
+		return root.ForEach(func(k, v []byte) error {
+			roster := root.Bucket(k)
+			if v != nil || roster == nil {
+				return nil
+			}
+			var friends bool
+			if buf := roster.Get(friendKey); buf == nil || json.Unmarshal(buf, &friends) != nil || !friends {
+				return nil
+			}
+			if id, err := strconv.Atoi(string(k)); err == nil {
+				followers = append(followers, id)
+			}
+			return nil
+		})
+	})
+	sort.Ints(followers)
+	return followers
+}
+
+// Clear removes everyone from memberID's roster and removes memberID from everyone else's
+func Clear(memberID int) error {
+	friendKey := key(memberID)
+	return store.DB.Update(func(tx *bolt.Tx) error {
+		root := tx.Bucket(rosterBucket)
+		if root == nil {
+			return nil
+		}
+		if root.Bucket(friendKey) != nil {
+			if err := root.DeleteBucket(friendKey); err != nil {
+				return err
+			}
+		}
+		return root.ForEach(func(k, v []byte) error {
+			if roster := root.Bucket(k); v == nil && roster != nil {
+				return roster.Delete(friendKey)
+			}
+			return nil
+		})
+	})
+}
+
+// Merge moves fromID's roster to intoID and puts intoID in place of fromID on everyone else's roster. Neither
+// member ends up on their own roster. It returns how many friends moved and how many rosters were rewritten
+func Merge(fromID, intoID int) (friends, followers int, err error) {
+	fromKey, intoKey := key(fromID), key(intoID)
+	err = store.DB.Update(func(tx *bolt.Tx) error {
+		root := tx.Bucket(rosterBucket)
+		if root == nil {
+			return nil
+		}
+		if from := root.Bucket(fromKey); from != nil {
+			into, err := root.CreateBucketIfNotExists(intoKey)
+			if err != nil {
+				return err
+			}
+			err = from.ForEach(func(friendID, status []byte) error {
+				var ok bool
+				if json.Unmarshal(status, &ok) != nil || !ok || string(friendID) == string(intoKey) {
+					return nil
+				}
+				friends++
+				return into.Put(friendID, status)
+			})
+			if err != nil {
+				return err
+			}
+			if err := root.DeleteBucket(fromKey); err != nil {
+				return err
+			}
+		}
+		return root.ForEach(func(k, v []byte) error {
+			roster := root.Bucket(k)
+			if v != nil || roster == nil {
+				return nil
+			}
+			status := roster.Get(fromKey)
+			if status == nil {
+				return nil
+			}
+			if err := roster.Delete(fromKey); err != nil {
+				return err
+			}
+			var ok bool
+			if json.Unmarshal(status, &ok) != nil || !ok || string(k) == string(intoKey) {
+				return nil
+			}
+			followers++
+			return roster.Put(intoKey, status)
+		})
+	})
+	return friends, followers, err
+}
+
+// Migrate moves the legacy slack keyed roster to member ids and removes it. memberID resolves a slack
+// user id to a member id, returning 0 when there is no such member, and friends who cannot be resolved are
+// dropped. Any error from memberID aborts the migration and leaves the legacy roster in place to try again.
+// It returns how many friendships were moved and how many were dropped
+func Migrate(memberID func(slackID string) (int, error)) (moved, dropped int, err error) {
+	var legacy = map[string][]string{}
+	err = store.DB.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(legacyBucket)
+		if root == nil {
+			return nil
+		}
+		return root.ForEach(func(k, v []byte) error {
+			roster := root.Bucket(k)
+			if v != nil || roster == nil {
+				return nil
+			}
+			return roster.ForEach(func(friendID, status []byte) error {
+				var friends bool
+				if json.Unmarshal(status, &friends) == nil && friends {
+					legacy[string(k)] = append(legacy[string(k)], string(friendID))
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil || len(legacy) == 0 {
+		return 0, 0, err
+	}
+
+	var ids = map[string]int{}
+	resolve := func(slackID string) (int, error) {
+		if id, ok := ids[slackID]; ok {
+			return id, nil
+		}
+		id, err := memberID(slackID)
+		if err != nil {
+			return 0, err
+		}
+		ids[slackID] = id
+		return id, nil
+	}
+	var rosters = map[int][]int{}
+	for userID, friends := range legacy {
+		owner, err := resolve(userID)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, friendID := range friends {
+			friend, err := resolve(friendID)
+			if err != nil {
+				return 0, 0, err
+			}
+			if owner == 0 || friend == 0 || owner == friend {
+				dropped++
+				continue
+			}
+			rosters[owner] = append(rosters[owner], friend)
+			moved++
+		}
+	}
+
+	err = store.DB.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists(rosterBucket)
+		if err != nil {
+			return err
+		}
+		for owner, friends := range rosters {
+			roster, err := root.CreateBucketIfNotExists(key(owner))
+			if err != nil {
+				return err
+			}
+			for _, friend := range friends {
+				if err := roster.Put(key(friend), []byte("true")); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.DeleteBucket(legacyBucket)
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return moved, dropped, nil
 }
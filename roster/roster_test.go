@@ -0,0 +1,149 @@
+package roster
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/FederationOfFathers/dashboard/store"
+	"github.com/boltdb/bolt"
+	stow "gopkg.in/djherbis/stow.v2"
+)
+
+func openTestStore(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "roster.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.DB.DB = db
+	t.Cleanup(func() { db.Close() })
+}
+
+func TestRoster(t *testing.T) {
+	openTestStore(t)
+	for _, f := range [][2]int{{1, 2}, {1, 3}, {2, 1}, {2, 3}, {3, 4}} {
+		if err := Set(f[0], f[1], true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Set(1, 4, false); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name string
+		got  []int
+		want []int
+	}{
+		{"get", Get(1), []int{2, 3}},
+		{"mutual", Mutual(1, 2), []int{3}},
+		{"followers", Followers(3), []int{1, 2}},
+		{"one follower", Followers(1), []int{2}},
+	}
+	for _, test := range tests {
+		if !reflect.DeepEqual(test.got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, test.got, test.want)
+		}
+	}
+	if !IsFriend(2, 1) || IsFriend(3, 1) {
+		t.Errorf("IsFriend(2, 1) = %v, IsFriend(3, 1) = %v", IsFriend(2, 1), IsFriend(3, 1))
+	}
+
+	if err := Clear(3); err != nil {
+		t.Fatal(err)
+	}
+	if got := Get(3); len(got) != 0 {
+		t.Errorf("cleared roster still has %v", got)
+	}
+	if got := Followers(3); len(got) != 0 {
+		t.Errorf("cleared member still followed by %v", got)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	openTestStore(t)
+	for _, f := range [][2]int{{1, 2}, {1, 3}, {2, 1}, {3, 1}, {3, 2}, {4, 1}} {
+		if err := Set(f[0], f[1], true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	friends, followers, err := Merge(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if friends != 1 || followers != 2 {
+		t.Errorf("moved %d friends and %d followers, want 1 and 2", friends, followers)
+	}
+	var tests = []struct {
+		name string
+		got  []int
+		want []int
+	}{
+		{"merged roster", Get(1), []int{}},
+		{"into roster", Get(2), []int{3}},
+		{"followers of merged", Followers(1), []int{}},
+		{"followers of into", Followers(2), []int{3, 4}},
+	}
+	for _, test := range tests {
+		if !reflect.DeepEqual(test.got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	openTestStore(t)
+	legacy := stow.NewJSONStore(store.DB.DB, legacyBucket)
+	legacy.NewNestedStore([]byte("U1")).Put("U2", true)
+	legacy.NewNestedStore([]byte("U1")).Put("U3", false)
+	legacy.NewNestedStore([]byte("U1")).Put("UNKNOWN", true)
+	legacy.NewNestedStore([]byte("U2")).Put("U1", true)
+
+	ids := map[string]int{"U1": 10, "U2": 20, "U3": 30}
+	moved, dropped, err := Migrate(func(slackID string) (int, error) {
+		if id, ok := ids[slackID]; ok {
+			return id, nil
+		}
+		return 0, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved != 2 || dropped != 1 {
+		t.Errorf("moved %d dropped %d, want 2 and 1", moved, dropped)
+	}
+	if !IsFriend(10, 20) || !IsFriend(20, 10) || IsFriend(10, 30) {
+		t.Errorf("unexpected rosters %v %v", Get(10), Get(20))
+	}
+
+	// the legacy bucket is gone, so a second run does nothing
+	moved, dropped, err = Migrate(nil)
+	if err != nil || moved != 0 || dropped != 0 {
+		t.Errorf("second migration moved %d dropped %d: %v", moved, dropped, err)
+	}
+}
+
+func TestMigrateKeepsLegacyOnError(t *testing.T) {
+	openTestStore(t)
+	legacy := stow.NewJSONStore(store.DB.DB, legacyBucket)
+	legacy.NewNestedStore([]byte("U1")).Put("U2", true)
+
+	_, _, err := Migrate(func(slackID string) (int, error) {
+		return 0, fmt.Errorf("database unavailable")
+	})
+	if err == nil {
+		t.Fatal("expected the lookup error")
+	}
+
+	// nothing was dropped, so the friendship moves once lookups work again
+	moved, dropped, err := Migrate(func(slackID string) (int, error) {
+		return map[string]int{"U1": 10, "U2": 20}[slackID], nil
+	})
+	if err != nil || moved != 1 || dropped != 0 {
+		t.Errorf("retry moved %d dropped %d: %v", moved, dropped, err)
+	}
+	if !IsFriend(10, 20) {
+		t.Errorf("unexpected roster %v", Get(10))
+	}
+}
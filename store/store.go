@@ -32,6 +32,21 @@ func (s *Store) Friends() *stow.Store {
 	return stow.NewJSONStore(s.DB, []byte("friends"))
 }
 
+// Roster holds each member's friends in a bucket nested under their member id
+func (s *Store) Roster() *stow.Store {
+	return stow.NewJSONStore(s.DB, []byte("roster"))
+}
+
+// Milestones records which member milestones have been announced
+func (s *Store) Milestones() *stow.Store {
+	return stow.NewJSONStore(s.DB, []byte("milestones"))
+}
+
+// Trends records when the weekly games digest was last posted
+func (s *Store) Trends() *stow.Store {
+	return stow.NewJSONStore(s.DB, []byte("trends"))
+}
+
 func (s *Store) Groups() *stow.Store {
 	return stow.NewJSONStore(s.DB, []byte("groups"))
 }
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/FederationOfFathers/dashboard/messaging"
 	"go.uber.org/zap"
 	"google.golang.org/api/youtube/v3"
 )
@@ -53,6 +54,16 @@ func Owner(s *db.Stream) (*db.Member, error) {
 	return DB.MemberByID(s.MemberID)
 }
 
+// notifyFriends lets the stream owner's friends who asked to hear about it know that the owner went live
+func notifyFriends(s *db.Stream, platform string, url string) {
+	owner, err := Owner(s)
+	if err != nil {
+		Logger.Error("unable to find stream owner", zap.Int("member", s.MemberID), zap.Error(err))
+		return
+	}
+	messaging.NotifyFriends(owner, "notifyFriendStreams", fmt.Sprintf("📺 %s is live on %s: %s", owner.Name, platform, url))
+}
+
 func Add(kind, identifier, userID string) error {
 	member, err := DB.MemberByAny(userID)
 	if err != nil {
@@ -7,7 +7,9 @@ import (
 	"time"
 
 	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/FederationOfFathers/dashboard/imagecache"
 	"github.com/FederationOfFathers/dashboard/messaging"
+	"github.com/FederationOfFathers/dashboard/metrics"
 	"github.com/honeycombio/beeline-go"
 	"github.com/nicklaw5/helix"
 	"go.uber.org/zap"
@@ -57,17 +59,20 @@ func mindTwitch() {
 	twlog = Logger.Named("twitch")
 	twlog.Debug("begin minding")
 
-	updateTwitch(ctx, Streams)
+	start := time.Now()
+	err := updateTwitch(ctx, Streams)
+	metrics.StreamPolled("twitch", start, err)
 
 	twlog.Debug("twitch streams updated") //, zap.Int("numStreams", streamsCount))
 	twlog.Debug("end minding")
 }
 
-func updateTwitch(ctx context.Context, streams []*db.Stream) {
+// updateTwitch checks the twitch streams and posts messages for new ones. An error is returned when the check fails
+func updateTwitch(ctx context.Context, streams []*db.Stream) error {
 	var client = twitchClient
 	if err := ensureClientAccess(); err != nil {
 		twlog.Error("unable to verify twitch client app token", zap.Error(err))
-		return
+		return err
 	}
 
 	var userLogins []string
@@ -81,7 +86,7 @@ func updateTwitch(ctx context.Context, streams []*db.Stream) {
 
 	// if there are no Twitch users, then nothing left to do
 	if len(userLogins) == 0 {
-		return
+		return nil
 	}
 
 	// get users info and index to a map. continue on failures
@@ -101,13 +106,14 @@ func updateTwitch(ctx context.Context, streams []*db.Stream) {
 	if err != nil {
 		if err.Error() != "json: cannot unmarshal number into Go value of type string" {
 			twlog.Error("error fetching twitch stream", zap.Strings("userLogins", userLogins), zap.Error(err))
+			return err
 		}
-		return
+		return nil
 	}
 
 	if res.ErrorStatus != 0 {
 		twlog.Error("twitch response returned an error", zap.Int("errorStatus", res.ErrorStatus), zap.String("error", fmt.Sprintf("%s: %s", res.Error, res.ErrorMessage)))
-		return
+		return fmt.Errorf("twitch response returned an error %d: %s: %s", res.ErrorStatus, res.Error, res.ErrorMessage)
 	}
 
 	for _, stream := range res.Data.Streams {
@@ -142,6 +148,7 @@ func updateTwitch(ctx context.Context, streams []*db.Stream) {
 				u = user
 			}
 			sendTwitchMessage(stream, u)
+			notifyFriends(s, "Twitch", fmt.Sprintf("https://twitch.tv/%s", stream.UserName))
 		}
 
 		if err := s.Save(); err != nil {
@@ -172,7 +179,7 @@ func updateTwitch(ctx context.Context, streams []*db.Stream) {
 			}
 		}
 	}
-
+	return nil
 }
 
 func sendTwitchMessage(stream helix.Stream, user helix.User) {
@@ -196,7 +203,7 @@ func sendTwitchMessage(stream helix.Stream, user helix.User) {
 		URL:              fmt.Sprintf("https://twitch.tv/%s", stream.UserName),
 		Description:      stream.Title,
 		Timestamp:        time.Now().Format("01/02/2006 15:04 MST"),
-		ThumbnailURL:     thumbnailUrl,
+		ThumbnailURL:     imagecache.Link(thumbnailUrl, "card"),
 	})
 
 }
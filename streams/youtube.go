@@ -6,7 +6,9 @@ import (
 	"time"
 
 	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/FederationOfFathers/dashboard/imagecache"
 	"github.com/FederationOfFathers/dashboard/messaging"
+	"github.com/FederationOfFathers/dashboard/metrics"
 	"github.com/gocolly/colly/v2"
 	"go.uber.org/zap"
 	"google.golang.org/api/youtube/v3"
@@ -23,6 +25,11 @@ func mindYoutube() {
 	ytlog = Logger.Named("youtube")
 	ytlog.Debug("begin minding")
 
+	var err error
+	defer func(start time.Time) {
+		metrics.StreamPolled("youtube", start, err)
+	}(time.Now())
+
 	// indexed by channelID
 	indexedStreams := make(map[string]*db.Stream)
 	var streamsToCheck []string
@@ -51,8 +58,8 @@ func mindYoutube() {
 		return
 	}
 
-	vids := getYTStreamStatuses(streamsToCheck)
-	if vids == nil {
+	vids, err := getYTStreamStatuses(streamsToCheck)
+	if err != nil {
 		return
 	}
 
@@ -96,6 +103,9 @@ func mindYoutube() {
 		}
 
 		sendYouTubeMessage(vid, c)
+		if s, found := indexedStreams[c.Id]; found {
+			notifyFriends(s, "YouTube", fmt.Sprintf("https://youtube.com/v/%s", vid.Id))
+		}
 	}
 
 	// save all update streams
@@ -152,15 +162,15 @@ func getCanonicalURL(url string) string {
 	return canonicalUrl
 }
 
-func getYTStreamStatuses(ids []string) []*youtube.Video {
+func getYTStreamStatuses(ids []string) ([]*youtube.Video, error) {
 
 	resp, err := YouTube.Videos.List([]string{"snippet"}).Id(ids...).Do()
 	if err != nil {
 		ytlog.With(zap.Strings("ids", ids), zap.Error(err)).Error("unable to check YouTube streams")
-		return nil
+		return nil, err
 	}
 
-	return resp.Items
+	return resp.Items, nil
 
 }
 
@@ -195,6 +205,6 @@ func sendYouTubeMessage(i *youtube.Video, c *youtube.Channel) {
 		URL:              fmt.Sprintf("https://youtube.com/v/%s", i.Id),
 		Description:      i.Snippet.Title,
 		Timestamp:        time.Now().Format("01/02/2006 15:04 MST"),
-		ThumbnailURL:     thumbnailUrl,
+		ThumbnailURL:     imagecache.Link(thumbnailUrl, "card"),
 	})
 }
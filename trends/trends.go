@@ -0,0 +1,89 @@
+package trends
+
+import (
+	"time"
+
+	"github.com/FederationOfFathers/dashboard/db"
+	"github.com/FederationOfFathers/dashboard/messaging"
+	"github.com/FederationOfFathers/dashboard/store"
+	"go.uber.org/zap"
+)
+
+var DB *db.DB
+var Logger *zap.Logger
+
+// Digest turns on the weekly "what the community played" post. It needs digestChannelId in cfg-discord.yml
+var Digest bool
+
+// Hour is the hour on Monday, in UTC, after which the digest of the week before is posted
+var Hour = 12
+
+// Games is how many games are listed in each section of the digest
+var Games = 5
+
+const lastDigestKey = "lastDigest"
+
+// Mind snapshots weekly player counts every hour, and posts the digest once a week when Digest is set
+func Mind() {
+	go func() {
+		check()
+		for range time.Tick(time.Hour) {
+			check()
+		}
+	}()
+}
+
+func check() {
+	now := time.Now()
+	// the week before is snapshotted again so that games played late on Sunday are counted in it
+	for _, week := range []time.Time{db.WeekOf(now).AddDate(0, 0, -7), db.WeekOf(now)} {
+		if err := DB.SnapshotGameWeek(week); err != nil {
+			Logger.Error("unable to snapshot game players", zap.Time("week", week), zap.Error(err))
+			return
+		}
+	}
+	if !Digest {
+		return
+	}
+	var lastDigest string
+	store.DB.Trends().Get(lastDigestKey, &lastDigest)
+	week, ok := due(now, Hour, lastDigest)
+	if !ok {
+		return
+	}
+	if err := post(week); err != nil {
+		Logger.Error("unable to post weekly digest", zap.Time("week", week), zap.Error(err))
+		return
+	}
+	if err := store.DB.Trends().Put(lastDigestKey, week.Format("2006-01-02")); err != nil {
+		Logger.Error("unable to record weekly digest", zap.Error(err))
+	}
+}
+
+// due returns the week whose digest should be posted as of now, given the week the last one was for. The digest
+// of a week is due from hour on the Monday after it
+func due(now time.Time, hour int, lastDigest string) (time.Time, bool) {
+	thisWeek := db.WeekOf(now)
+	if now.Sub(thisWeek) < time.Duration(hour)*time.Hour {
+		return time.Time{}, false
+	}
+	week := thisWeek.AddDate(0, 0, -7)
+	return week, lastDigest != week.Format("2006-01-02")
+}
+
+// post sends the digest of week. A week nobody played anything in is skipped quietly
+func post(week time.Time) error {
+	trends, err := DB.GameTrends(week, Games)
+	if err != nil {
+		return err
+	}
+	if len(trends.Top) == 0 {
+		Logger.Info("no games played, skipping weekly digest", zap.Time("week", week))
+		return nil
+	}
+	if err := messaging.SendDigestMessage(trends); err != nil {
+		return err
+	}
+	Logger.Info("posted weekly digest", zap.Time("week", week), zap.Int("games", len(trends.Top)))
+	return nil
+}
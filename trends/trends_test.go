@@ -0,0 +1,32 @@
+package trends
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDue(t *testing.T) {
+	// 2026-10-19 is a Monday
+	at := func(day, hour int) time.Time {
+		return time.Date(2026, time.October, day, hour, 30, 0, 0, time.UTC)
+	}
+	var tests = []struct {
+		name       string
+		now        time.Time
+		lastDigest string
+		week       string
+		ok         bool
+	}{
+		{"monday before hour", at(19, 11), "2026-10-05", "", false},
+		{"monday after hour", at(19, 12), "2026-10-05", "2026-10-12", true},
+		{"already posted", at(19, 13), "2026-10-12", "", false},
+		{"missed monday", at(22, 3), "2026-10-05", "2026-10-12", true},
+		{"first run", at(20, 0), "", "2026-10-12", true},
+	}
+	for _, test := range tests {
+		week, ok := due(test.now, 12, test.lastDigest)
+		if ok != test.ok || (ok && week.Format("2006-01-02") != test.week) {
+			t.Errorf("%s: got %s %v, want %s %v", test.name, week.Format("2006-01-02"), ok, test.week, test.ok)
+		}
+	}
+}